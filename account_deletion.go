@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+// handlerUserDelete schedules the user's account for deletion once
+// accountDeletionGrace has passed. Every token the user holds stops working
+// straight away, and open WebSocket connections are closed; signing in again
+// before the deadline allows cancelling.
+func (cfg *apiConfig) handlerUserDelete(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	type returnVals struct {
+		ID          string `json:"id"`
+		DeleteAfter string `json:"delete_after"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.database.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't find user", err)
+		return
+	}
+
+	// A stolen access token alone shouldn't be enough to delete an account.
+	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Incorrect password", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	deleteAfter := time.Now().Add(cfg.accountDeletionGrace)
+	err = qtx.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		ID:          userID,
+		DeleteAfter: sql.NullTime{Time: deleteAfter, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	if err := qtx.RevokeAllRefreshTokens(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete account", err)
+		return
+	}
+	cfg.publishRealtimeEvent(r.Context(), realtimeNotification{
+		Type:   tokensRevokedEvent,
+		UserID: userID,
+	})
+
+	respondWithJSON(w, http.StatusAccepted, returnVals{
+		ID:          userID.String(),
+		DeleteAfter: deleteAfter.String(),
+	})
+}
+
+// handlerUserRestore cancels a scheduled deletion. Old tokens were revoked
+// when the deletion was requested, so this needs one from signing in again.
+func (cfg *apiConfig) handlerUserRestore(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	cancelled, err := cfg.database.CancelUserDeletion(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore account", err)
+		return
+	}
+	if cancelled == 0 {
+		respondWithError(w, http.StatusConflict, "Account is not scheduled for deletion", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// middlewareTokenRevocation refuses access tokens issued before their user's
+// tokens were revoked, and tokens of users that no longer exist. Handlers
+// validate tokens without the database, so this is where revocation is
+// enforced. A request may carry one token, in the Authorization header or,
+// for WebSockets, the access_token query parameter; one carrying more is
+// refused, so a handler can't end up using a token that wasn't checked.
+// Anything that isn't a valid access token, such as a refresh token, is left
+// for the handler to judge.
+func (cfg *apiConfig) middlewareTokenRevocation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens := r.URL.Query()["access_token"]
+		if token, err := auth.GetBearerToken(r.Header); err == nil {
+			tokens = append(tokens, token)
+		}
+		if len(tokens) > 1 {
+			respondWithError(w, http.StatusUnauthorized, "Send one access token", nil)
+			return
+		}
+
+		for _, token := range tokens {
+			userID, issuedAt, err := auth.ValidateJWTIssuedAt(token, cfg.secret)
+			if err != nil {
+				continue
+			}
+
+			revokedAt, err := cfg.database.GetUserTokensRevokedAt(r.Context(), userID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+					return
+				}
+				respondWithError(w, http.StatusInternalServerError, "Couldn't check token", err)
+				return
+			}
+			// Tokens only record the second they were issued, so one issued
+			// in the same second as the revocation is refused too.
+			if revokedAt.Valid && !issuedAt.After(revokedAt.Time) {
+				respondWithError(w, http.StatusUnauthorized, "Unauthorized", nil)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// runAccountDeleter deletes accounts whose grace period has passed, checking
+// every interval. Rows are claimed with SKIP LOCKED, so several servers can
+// run it at once.
+func (cfg *apiConfig) runAccountDeleter(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.deleteDueAccounts(ctx); err != nil {
+			log.Printf("Error deleting accounts: %v", err)
+		}
+	})
+}
+
+// deleteDueAccounts deletes accounts one at a time until none are due. It
+// stops at the first error and tries again next time.
+func (cfg *apiConfig) deleteDueAccounts(ctx context.Context) error {
+	for {
+		deleted, err := cfg.deleteNextDueAccount(ctx)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return nil
+		}
+	}
+}
+
+// deleteNextDueAccount deletes one account whose grace period has passed and
+// reports whether there was one. Chirps, likes, follows, uploads and
+// everything else the user owns cascade away with the user row; the counters
+// they added to other users' chirps and profiles are undone first, and
+// stream clients are told the user's chirps are gone. Blobs are removed once
+// the rows are.
+func (cfg *apiConfig) deleteNextDueAccount(ctx context.Context) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	userID, err := qtx.ClaimDueUserDeletion(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	chirps, err := qtx.ListAllChirpsByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	var removed []uuid.UUID
+	for _, chirp := range chirps {
+		// Deleted chirps have already been announced, taken off timelines
+		// and had their counters undone. Hiding does none of that to the
+		// counters, so hidden chirps are handled like the rest.
+		if chirp.DeletedAt.Valid {
+			continue
+		}
+		if err := decrementParentCounts(ctx, qtx, chirp); err != nil {
+			return false, err
+		}
+		removed = append(removed, chirp.ID)
+	}
+
+	files, err := qtx.ListMediaFilesByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	exports, err := qtx.ListDataExportsByUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if err := qtx.DecrementFollowCountsOfUser(ctx, userID); err != nil {
+		return false, err
+	}
+	if err := qtx.DecrementLikeCountsOfUser(ctx, userID); err != nil {
+		return false, err
+	}
+	if err := qtx.DecrementPollVotesOfUser(ctx, userID); err != nil {
+		return false, err
+	}
+	for _, chirpID := range removed {
+		if err := recordChirpEvent(ctx, qtx, chirpDeletedEvent, chirpID); err != nil {
+			return false, err
+		}
+	}
+	if err := qtx.DeleteUser(ctx, userID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	// The user may have signed in again during the grace period.
+	cfg.publishRealtimeEvent(ctx, realtimeNotification{
+		Type:   tokensRevokedEvent,
+		UserID: userID,
+	})
+
+	for _, chirpID := range removed {
+		if err := cfg.timelines.RemoveChirp(ctx, chirpID); err != nil {
+			log.Printf("Error removing chirp %s from timelines: %v", chirpID, err)
+		}
+	}
+	for _, file := range files {
+		cfg.deleteMediaBlobs(file.ID)
+	}
+	for _, export := range exports {
+		if !export.BlobKey.Valid {
+			continue
+		}
+		if err := cfg.blobs.Delete(ctx, export.BlobKey.String); err != nil {
+			log.Printf("Error deleting blob %s: %v", export.BlobKey.String, err)
+		}
+	}
+
+	log.Printf("Deleted account %s", userID)
+	return true, nil
+}
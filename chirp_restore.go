@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+func (cfg *apiConfig) handlerChirpsRestore(w http.ResponseWriter, r *http.Request) {
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	// Lock the row so a concurrent restore or purge can't interleave.
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), parseChirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only restore your own chirps", nil)
+		return
+	}
+
+	if !chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Chirp is not deleted", nil)
+		return
+	}
+
+	if time.Since(chirp.DeletedAt.Time) > cfg.chirpRestoreWindow {
+		respondWithError(w, http.StatusGone, "Restore window has expired", nil)
+		return
+	}
+
+	err = qtx.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:        chirp.ID,
+		DeletedAt: chirp.DeletedAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	err = incrementParentCounts(r.Context(), qtx, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+	chirp.DeletedAt = sql.NullTime{}
+
+	go cfg.fanOutChirp(context.Background(), chirp)
+
+	response, err := cfg.chirpResponseFor(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't restore chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerChirpsDeleted(w http.ResponseWriter, r *http.Request) {
+	type deletedResponse struct {
+		chirpResponse
+		DeletedAt    string `json:"deleted_at"`
+		RestoreUntil string `json:"restore_until"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	chirps, err := cfg.database.ListDeletedChirps(r.Context(), database.ListDeletedChirpsParams{
+		UserID:    userID,
+		DeletedAt: sql.NullTime{Time: time.Now().Add(-cfg.chirpRestoreWindow), Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list deleted chirps", err)
+		return
+	}
+
+	converted, err := cfg.chirpResponses(r.Context(), chirps, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list deleted chirps", err)
+		return
+	}
+
+	response := make([]deletedResponse, len(chirps))
+	for i, chirp := range chirps {
+		response[i] = deletedResponse{
+			chirpResponse: converted[i],
+			DeletedAt:     chirp.DeletedAt.Time.String(),
+			RestoreUntil:  chirp.DeletedAt.Time.Add(cfg.chirpRestoreWindow).String(),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// runChirpPurger permanently deletes chirps whose restore window has passed,
+// checking every interval. Purging removes a chirp's
+// likes, entities and attachments with it and orphans its replies, just as
+// deleting it outright used to.
+func (cfg *apiConfig) runChirpPurger(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		cutoff := sql.NullTime{Time: time.Now().Add(-cfg.chirpRestoreWindow), Valid: true}
+		purged, err := cfg.database.PurgeDeletedChirps(ctx, cutoff)
+		if err != nil {
+			log.Printf("Error purging deleted chirps: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted chirps", purged)
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/httpcache"
+)
+
+func (cfg *apiConfig) handlerChirpsUpdate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	if !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	filtered, err := cfg.cleanChirpBody(params.Body)
+	if err != nil {
+		respondWithChirpInputError(w, err, "Couldn't update chirp")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	// Lock the row so concurrent edits are recorded as separate revisions.
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), parseChirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only edit your own chirps", nil)
+		return
+	}
+
+	if !checkChirpPrecondition(w, r, chirp) {
+		return
+	}
+
+	if chirp.RechirpOfID.Valid {
+		respondWithError(w, http.StatusBadRequest, "Rechirps cannot be edited", nil)
+		return
+	}
+
+	if time.Since(chirp.CreatedAt) > cfg.chirpEditWindow {
+		respondWithError(w, http.StatusForbidden, "Edit window has expired", nil)
+		return
+	}
+
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		CreatedAt: chirp.UpdatedAt,
+		ChirpID:   chirp.ID,
+		Body:      chirp.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save chirp revision", err)
+		return
+	}
+
+	chirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		Body: filtered.Text,
+		ID:   chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	err = qtx.UpsertChirpSearchDocument(r.Context(), database.UpsertChirpSearchDocumentParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	err = saveChirpEntities(r.Context(), qtx, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	err = recordContentFlags(r.Context(), qtx, chirp.ID, filtered)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	response, err := cfg.chirpResponseFor(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update chirp", err)
+		return
+	}
+
+	// The new ETag lets the client make a further conditional edit.
+	respondWithCacheableJSON(w, r, http.StatusOK, response, chirpVersion(chirp), chirp.UpdatedAt, httpcache.Revalidate)
+}
+
+func (cfg *apiConfig) handlerChirpsHistory(w http.ResponseWriter, r *http.Request) {
+	type revisionResponse struct {
+		ID        string `json:"id"`
+		ChirpID   string `json:"chirp_id"`
+		CreatedAt string `json:"created_at"`
+		Body      string `json:"body"`
+	}
+
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	chirp, err := cfg.database.GetChirp(r.Context(), parseChirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	revisions, err := cfg.database.ListChirpRevisions(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirp history", err)
+		return
+	}
+
+	// Earlier versions come first; the current body is always last.
+	response := make([]revisionResponse, 0, len(revisions)+1)
+	for _, revision := range revisions {
+		response = append(response, revisionResponse{
+			ID:        revision.ID.String(),
+			ChirpID:   revision.ChirpID.String(),
+			CreatedAt: revision.CreatedAt.String(),
+			Body:      revision.Body,
+		})
+	}
+	response = append(response, revisionResponse{
+		ID:        chirp.ID.String(),
+		ChirpID:   chirp.ID.String(),
+		CreatedAt: chirp.UpdatedAt.String(),
+		Body:      chirp.Body,
+	})
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/entities"
+	"github.com/mjayio/server/internal/stream"
+)
+
+const (
+	chirpCreatedEvent = "chirp.created"
+	chirpDeletedEvent = "chirp.deleted"
+
+	// chirpEventsChannel is the Postgres channel new chirp events are
+	// announced on.
+	chirpEventsChannel = "chirp_events"
+	// streamReplayBatchSize is how many missed events are loaded at a time
+	// for a client resuming with Last-Event-ID.
+	streamReplayBatchSize = 100
+	// streamReplayLimit is how many of the most recent events a resuming
+	// client is replayed at most. Anything older is skipped.
+	streamReplayLimit = 1000
+	// streamRetry is how long clients wait before reconnecting, in
+	// milliseconds.
+	streamRetry = 3000
+)
+
+// recordChirpEvent adds an event for the chirp to the stream. q should be
+// bound to the transaction that makes the change, so the event is announced
+// exactly when the change commits. Recording waits for any other transaction
+// that has recorded an event to end, so that IDs follow commit order and
+// resuming after an ID can't miss an event; call it as late in the
+// transaction as possible.
+func recordChirpEvent(ctx context.Context, q *database.Queries, eventType string, chirpID uuid.UUID) error {
+	return q.CreateChirpEvent(ctx, database.CreateChirpEventParams{
+		Type:    eventType,
+		ChirpID: chirpID,
+	})
+}
+
+// streamEvent prepares a recorded event for subscribers. New chirps carry the
+// chirp as anyone signed out would see it, and deletions just its ID and
+// author. ok is false for events nobody signed out may see, such as a new
+// chirp that has since been hidden or deleted.
+func (cfg *apiConfig) streamEvent(ctx context.Context, event database.ChirpEvent) (stream.Event, bool, error) {
+	var payload any
+	switch event.Type {
+	case chirpCreatedEvent:
+		chirp, err := cfg.database.GetChirp(ctx, event.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return stream.Event{}, false, nil
+		}
+		if err != nil {
+			return stream.Event{}, false, err
+		}
+		if !(chirpVisibility{}).canSee(chirp) {
+			return stream.Event{}, false, nil
+		}
+		payload, err = cfg.chirpResponseFor(ctx, chirp, uuid.NullUUID{})
+		if err != nil {
+			return stream.Event{}, false, err
+		}
+	case chirpDeletedEvent:
+		payload = struct {
+			ID     string `json:"id"`
+			UserID string `json:"user_id"`
+		}{event.ChirpID.String(), event.UserID.String()}
+	default:
+		return stream.Event{}, false, nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return stream.Event{}, false, err
+	}
+	threadID := event.ChirpID
+	if event.RootID.Valid {
+		threadID = event.RootID.UUID
+	}
+	return stream.Event{
+		ID:       event.ID,
+		Type:     event.Type,
+		AuthorID: event.UserID,
+		Hashtags: event.Hashtags,
+		Mentions: event.MentionedUserIds,
+		ThreadID: threadID,
+		Data:     data,
+	}, true, nil
+}
+
+// runChirpStream listens for chirp events and ephemeral events announced by
+// any server instance and publishes them to this instance's subscribers.
+// Chirp events older than retention are pruned every hour; clients can only
+// resume within it.
+func (cfg *apiConfig) runChirpStream(ctx context.Context, dbURL string, retention time.Duration) {
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Chirp stream listener: %v", err)
+		}
+	})
+	defer listener.Close()
+	for _, channel := range []string{chirpEventsChannel, realtimeEventsChannel} {
+		if err := listener.Listen(channel); err != nil {
+			log.Printf("Error listening for %s: %v", channel, err)
+			return
+		}
+	}
+
+	lastID, err := cfg.database.GetLatestChirpEventID(ctx)
+	if err != nil {
+		log.Printf("Error loading latest chirp event: %v", err)
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-listener.Notify:
+			// A nil notification means the connection was re-established,
+			// and anything announced while it was down was missed.
+			if notification == nil {
+				lastID = cfg.publishChirpEventsAfter(ctx, lastID)
+				continue
+			}
+			if notification.Channel == realtimeEventsChannel {
+				cfg.receiveRealtimeEvent(notification.Extra)
+				continue
+			}
+			id, err := strconv.ParseInt(notification.Extra, 10, 64)
+			if err != nil {
+				log.Printf("Invalid chirp event notification %q", notification.Extra)
+				continue
+			}
+			event, err := cfg.database.GetChirpEvent(ctx, id)
+			if err != nil {
+				log.Printf("Error loading chirp event %d: %v", id, err)
+				continue
+			}
+			cfg.publishChirpEvent(ctx, event)
+			lastID = max(lastID, id)
+		case <-ping.C:
+			if err := listener.Ping(); err != nil {
+				log.Printf("Chirp stream listener: %v", err)
+			}
+		case <-prune.C:
+			if _, err := cfg.database.DeleteChirpEventsBefore(ctx, time.Now().Add(-retention)); err != nil {
+				log.Printf("Error pruning chirp events: %v", err)
+			}
+		}
+	}
+}
+
+// publishChirpEventsAfter publishes every recorded event after afterID and
+// returns the ID of the last one.
+func (cfg *apiConfig) publishChirpEventsAfter(ctx context.Context, afterID int64) int64 {
+	for {
+		events, err := cfg.database.ListChirpEventsAfter(ctx, database.ListChirpEventsAfterParams{
+			AfterID:   afterID,
+			BatchSize: streamReplayBatchSize,
+		})
+		if err != nil {
+			log.Printf("Error catching up on chirp events: %v", err)
+			return afterID
+		}
+		for _, event := range events {
+			cfg.publishChirpEvent(ctx, event)
+			afterID = event.ID
+		}
+		if len(events) < streamReplayBatchSize {
+			return afterID
+		}
+	}
+}
+
+func (cfg *apiConfig) publishChirpEvent(ctx context.Context, event database.ChirpEvent) {
+	prepared, ok, err := cfg.streamEvent(ctx, event)
+	if err != nil {
+		log.Printf("Error preparing chirp event %d: %v", event.ID, err)
+		return
+	}
+	if ok {
+		cfg.chirpStream.Publish(prepared)
+	}
+}
+
+// parseStreamFilter reads the author_id and hashtag query parameters, each of
+// which may be repeated.
+func parseStreamFilter(r *http.Request) (stream.Filter, error) {
+	filter := stream.Filter{Types: []string{chirpCreatedEvent, chirpDeletedEvent}}
+	query := r.URL.Query()
+	for _, value := range query["author_id"] {
+		authorID, err := uuid.Parse(value)
+		if err != nil {
+			return stream.Filter{}, err
+		}
+		filter.Authors = append(filter.Authors, authorID)
+	}
+	for _, value := range query["hashtag"] {
+		if tag := entities.NormalizeHashtag(value); tag != "" {
+			filter.Hashtags = append(filter.Hashtags, tag)
+		}
+	}
+	return filter, nil
+}
+
+func (cfg *apiConfig) handlerChirpStream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseStreamFilter(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+		return
+	}
+
+	// Subscribing before replaying means nothing announced during the replay
+	// is missed; events the replay already covered are skipped below.
+	sub := cfg.chirpStream.Subscribe(filter, int(cfg.streamBuffer))
+	defer sub.Close()
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	afterID, resuming := stream.ParseLastEventID(lastEventID)
+	var lastID int64
+	if resuming {
+		latestID, err := cfg.database.GetLatestChirpEventID(r.Context())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load missed events", err)
+			return
+		}
+		lastID = max(afterID, latestID-streamReplayLimit)
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", stream.ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(write func() error) bool {
+		if err := write(); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+	if !send(func() error { return stream.WriteRetry(w, streamRetry) }) {
+		return
+	}
+
+	// Missed events are written as they are loaded rather than collected
+	// first. The response has started, so a failure just ends it and the
+	// client resumes from the last event it got.
+	for resuming {
+		events, err := cfg.database.ListChirpEventsAfter(r.Context(), database.ListChirpEventsAfterParams{
+			AfterID:   lastID,
+			BatchSize: streamReplayBatchSize,
+		})
+		if err != nil {
+			log.Printf("Error loading missed chirp events: %v", err)
+			return
+		}
+		for _, event := range events {
+			lastID = event.ID
+			prepared, ok, err := cfg.streamEvent(r.Context(), event)
+			if err != nil {
+				log.Printf("Error preparing chirp event %d: %v", event.ID, err)
+				return
+			}
+			if !ok || !filter.Match(prepared) {
+				continue
+			}
+			if !send(func() error { return stream.WriteEvent(w, prepared) }) {
+				return
+			}
+		}
+		if len(events) < streamReplayBatchSize {
+			break
+		}
+	}
+
+	heartbeat := time.NewTicker(cfg.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !send(func() error { return stream.WriteComment(w, "heartbeat") }) {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// The client fell too far behind. Closing the connection
+				// makes it reconnect and resume from its last event.
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			if !send(func() error { return stream.WriteEvent(w, event) }) {
+				return
+			}
+		}
+	}
+}
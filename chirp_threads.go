@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+)
+
+func (cfg *apiConfig) handlerChirpsThread(w http.ResponseWriter, r *http.Request) {
+	type replyResponse struct {
+		chirpResponse
+		Depth int32 `json:"depth"`
+	}
+
+	type returnVals struct {
+		Chirp      chirpResponse   `json:"chirp"`
+		Ancestors  []chirpResponse `json:"ancestors"`
+		Replies    []replyResponse `json:"replies"`
+		NextOffset *int32          `json:"next_offset"`
+	}
+
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	chirp, err := cfg.database.GetChirp(r.Context(), parseChirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load thread", err)
+		return
+	}
+	if !visibility.canSee(chirp) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	ancestors, err := cfg.database.ListChirpAncestors(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load thread", err)
+		return
+	}
+
+	// Fetch one extra reply to find out whether there is another page.
+	descendants, err := cfg.database.ListChirpDescendants(r.Context(), database.ListChirpDescendantsParams{
+		ParentID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Limit:    limit + 1,
+		Offset:   offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load thread", err)
+		return
+	}
+
+	var nextOffset *int32
+	if int32(len(descendants)) > limit {
+		descendants = descendants[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	// Hidden and deleted chirps drop out of the thread, but their visible
+	// replies stay so the conversation doesn't lose unrelated branches.
+	ancestors = visibility.filter(ancestors)
+	descendants = slices.DeleteFunc(descendants, func(reply database.ListChirpDescendantsRow) bool {
+		return !visibility.canSee(database.Chirp{UserID: reply.UserID, HiddenAt: reply.HiddenAt, DeletedAt: reply.DeletedAt})
+	})
+
+	// Build every chirp in the thread in one batch so per-viewer state is
+	// looked up once: the chirp itself, then ancestors, then replies.
+	chirps := make([]database.Chirp, 0, 1+len(ancestors)+len(descendants))
+	chirps = append(chirps, chirp)
+	chirps = append(chirps, ancestors...)
+	for _, reply := range descendants {
+		chirps = append(chirps, database.Chirp{
+			ID:           reply.ID,
+			CreatedAt:    reply.CreatedAt,
+			UpdatedAt:    reply.UpdatedAt,
+			UserID:       reply.UserID,
+			Body:         reply.Body,
+			ParentID:     reply.ParentID,
+			RootID:       reply.RootID,
+			ReplyCount:   reply.ReplyCount,
+			LikeCount:    reply.LikeCount,
+			RechirpOfID:  reply.RechirpOfID,
+			QuoteOfID:    reply.QuoteOfID,
+			RechirpCount: reply.RechirpCount,
+			QuoteCount:   reply.QuoteCount,
+			HiddenAt:     reply.HiddenAt,
+			DeletedAt:    reply.DeletedAt,
+		})
+	}
+	converted, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load thread", err)
+		return
+	}
+
+	response := returnVals{
+		Chirp:      converted[0],
+		Ancestors:  converted[1 : 1+len(ancestors)],
+		Replies:    make([]replyResponse, len(descendants)),
+		NextOffset: nextOffset,
+	}
+	for i, reply := range descendants {
+		response.Replies[i] = replyResponse{
+			chirpResponse: converted[1+len(ancestors)+i],
+			Depth:         reply.Depth,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// parseLimitOffset reads the limit and offset query parameters, applying
+// defaultLimit when limit is absent and capping it at maxLimit.
+func parseLimitOffset(r *http.Request, defaultLimit, maxLimit int32) (int32, int32, error) {
+	limit := defaultLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || parsed < 1 {
+			return 0, 0, fmt.Errorf("invalid limit %q", value)
+		}
+		limit = min(int32(parsed), maxLimit)
+	}
+
+	var offset int32
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", value)
+		}
+		offset = int32(parsed)
+	}
+
+	return limit, offset, nil
+}
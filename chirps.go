@@ -1,27 +1,518 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"regexp"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/mjayio/server/internal/auth"
 	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/filter"
+	"github.com/mjayio/server/internal/text"
 )
 
-func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
+type chirpResponse struct {
+	ID           string            `json:"id"`
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
+	Body         string            `json:"body"`
+	UserID       string            `json:"user_id"`
+	ParentID     *string           `json:"parent_id"`
+	RootID       *string           `json:"root_id"`
+	ReplyCount   int32             `json:"reply_count"`
+	LikeCount    int32             `json:"like_count"`
+	LikedByMe    *bool             `json:"liked_by_me,omitempty"`
+	RechirpOfID  *string           `json:"rechirp_of_id"`
+	QuoteOfID    *string           `json:"quote_of_id"`
+	RechirpCount int32             `json:"rechirp_count"`
+	QuoteCount   int32             `json:"quote_count"`
+	Hidden       bool              `json:"hidden,omitempty"`
+	Entities     []entityResponse  `json:"entities"`
+	Media        []mediaResponse   `json:"media"`
+	Card         *linkCardResponse `json:"card,omitempty"`
+	Poll         *pollResponse     `json:"poll,omitempty"`
+	Rechirped    *chirpResponse    `json:"rechirped_chirp,omitempty"`
+	Quoted       *chirpResponse    `json:"quoted_chirp,omitempty"`
+}
+
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	return chirpResponse{
+		ID:           chirp.ID.String(),
+		CreatedAt:    chirp.CreatedAt.String(),
+		UpdatedAt:    chirp.UpdatedAt.String(),
+		Body:         chirp.Body,
+		UserID:       chirp.UserID.String(),
+		ParentID:     nullUUIDString(chirp.ParentID),
+		RootID:       nullUUIDString(chirp.RootID),
+		ReplyCount:   chirp.ReplyCount,
+		LikeCount:    chirp.LikeCount,
+		RechirpOfID:  nullUUIDString(chirp.RechirpOfID),
+		QuoteOfID:    nullUUIDString(chirp.QuoteOfID),
+		RechirpCount: chirp.RechirpCount,
+		QuoteCount:   chirp.QuoteCount,
+		Hidden:       chirp.HiddenAt.Valid,
+		Entities:     []entityResponse{},
+		Media:        []mediaResponse{},
+	}
+}
+
+// chirpResponses converts chirps for a response. Rechirped and quoted chirps
+// are embedded one level deep; a quote whose original has been deleted or
+// hidden by a moderator keeps its quote_of_id but has no quoted_chirp. When viewerID is set, the viewer's
+// own state (such as liked_by_me) is filled in with batched lookups.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, chirps []database.Chirp, viewerID uuid.NullUUID) ([]chirpResponse, error) {
+	var refIDs []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.RechirpOfID.Valid {
+			refIDs = append(refIDs, chirp.RechirpOfID.UUID)
+		}
+		if chirp.QuoteOfID.Valid {
+			refIDs = append(refIDs, chirp.QuoteOfID.UUID)
+		}
+	}
+
+	// Referenced chirps are converted together with the page itself so the
+	// per-viewer lookups below cover both in a single query.
+	all := chirps
+	if len(refIDs) > 0 {
+		referenced, err := cfg.database.GetChirpsByIDs(ctx, refIDs)
+		if err != nil {
+			return nil, err
+		}
+		all = append(append([]database.Chirp{}, chirps...), referenced...)
+	}
+
+	ids := make([]uuid.UUID, len(all))
+	for i, chirp := range all {
+		ids[i] = chirp.ID
+	}
+
+	chirpEntities, err := cfg.chirpEntities(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	chirpMedia, err := cfg.chirpMedia(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	chirpCards, err := cfg.chirpCards(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	chirpPolls, err := cfg.chirpPolls(ctx, ids, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]chirpResponse, len(all))
+	for i, chirp := range all {
+		response[i] = newChirpResponse(chirp)
+		if found, ok := chirpEntities[chirp.ID]; ok {
+			response[i].Entities = found
+		}
+		if found, ok := chirpMedia[chirp.ID]; ok {
+			response[i].Media = found
+		}
+		response[i].Card = chirpCards[chirp.ID]
+		response[i].Poll = chirpPolls[chirp.ID]
+	}
+
+	if viewerID.Valid && len(all) > 0 {
+		likedIDs, err := cfg.database.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+			UserID:   viewerID.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		liked := make(map[uuid.UUID]bool, len(likedIDs))
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+
+		for i := range response {
+			likedByMe := liked[all[i].ID]
+			response[i].LikedByMe = &likedByMe
+		}
+	}
+
+	embedded := make(map[uuid.UUID]chirpResponse, len(all)-len(chirps))
+	for i := len(chirps); i < len(all); i++ {
+		if all[i].DeletedAt.Valid || all[i].HiddenAt.Valid && (!viewerID.Valid || viewerID.UUID != all[i].UserID) {
+			continue
+		}
+		embedded[all[i].ID] = response[i]
+	}
+	for i, chirp := range chirps {
+		if ref, ok := embedded[chirp.RechirpOfID.UUID]; ok && chirp.RechirpOfID.Valid {
+			response[i].Rechirped = &ref
+		}
+		if ref, ok := embedded[chirp.QuoteOfID.UUID]; ok && chirp.QuoteOfID.Valid {
+			response[i].Quoted = &ref
+		}
+	}
+	return response[:len(chirps)], nil
+}
+
+var errChirpDeleted = errors.New("chirp has been deleted")
+
+// getOriginalChirp loads a chirp, following a rechirp to the chirp it
+// reposts. Replies and quotes always point at the original. It returns
+// errChirpDeleted if either chirp is awaiting purge.
+func getOriginalChirp(ctx context.Context, q *database.Queries, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := q.GetChirp(ctx, id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.RechirpOfID.Valid {
+		chirp, err = q.GetChirp(ctx, chirp.RechirpOfID.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	if chirp.DeletedAt.Valid {
+		return database.Chirp{}, errChirpDeleted
+	}
+	return chirp, nil
+}
+
+// incrementParentCounts adds to the counters of the chirps a chirp
+// references. It is the inverse of decrementParentCounts.
+func incrementParentCounts(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.ParentID.Valid {
+		if err := q.IncrementReplyCount(ctx, chirp.ParentID.UUID); err != nil {
+			return err
+		}
+	}
+	if chirp.RechirpOfID.Valid {
+		if err := q.IncrementRechirpCount(ctx, chirp.RechirpOfID.UUID); err != nil {
+			return err
+		}
+	}
+	if chirp.QuoteOfID.Valid {
+		if err := q.IncrementQuoteCount(ctx, chirp.QuoteOfID.UUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decrementParentCounts undoes the counters a chirp added to the chirps it
+// references when it was created.
+func decrementParentCounts(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if chirp.ParentID.Valid {
+		if err := q.DecrementReplyCount(ctx, chirp.ParentID.UUID); err != nil {
+			return err
+		}
+	}
+	if chirp.RechirpOfID.Valid {
+		if err := q.DecrementRechirpCount(ctx, chirp.RechirpOfID.UUID); err != nil {
+			return err
+		}
+	}
+	if chirp.QuoteOfID.Valid {
+		if err := q.DecrementQuoteCount(ctx, chirp.QuoteOfID.UUID); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	type returnVals struct {
-		ID        string `json:"id"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
-		Body      string `json:"body"`
-		UserID    string `json:"user_id"`
+// chirpResponseFor is chirpResponses for a single chirp.
+func (cfg *apiConfig) chirpResponseFor(ctx context.Context, chirp database.Chirp, viewerID uuid.NullUUID) (chirpResponse, error) {
+	response, err := cfg.chirpResponses(ctx, []database.Chirp{chirp}, viewerID)
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return response[0], nil
+}
+
+// viewerID returns the authenticated user for endpoints where signing in is
+// optional. A request without an Authorization header has no viewer, but a
+// header carrying a bad token is still an error.
+func (cfg *apiConfig) viewerID(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		return uuid.NullUUID{}, err
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+func nullUUIDString(id uuid.NullUUID) *string {
+	if !id.Valid {
+		return nil
+	}
+	s := id.UUID.String()
+	return &s
+}
+
+const (
+	// maxChirpLength is counted in user-perceived characters, with each URL
+	// counting as text.URLWeight.
+	maxChirpLength = 140
+	// maxChirpBytes caps the stored size of a chirp, which stacked combining
+	// marks could otherwise make huge without adding to its length.
+	maxChirpBytes = 4096
+)
+
+var (
+	errChirpTooLong   = errors.New("chirp is too long")
+	errChirpRejected  = errors.New("chirp contains a blocked word")
+	errTooManyMedia   = fmt.Errorf("a chirp can have at most %d attachments", maxChirpMedia)
+	errParentNotFound = errors.New("parent chirp not found")
+	errParentDeleted  = errors.New("parent chirp has been deleted")
+	errQuoteNotFound  = errors.New("quoted chirp not found")
+	errQuoteDeleted   = errors.New("quoted chirp has been deleted")
+	errInvalidMedia   = errors.New("invalid media ID")
+	errMediaAttached  = errors.New("media is already attached to a chirp")
+)
+
+// cleanChirpBody normalizes the body, runs it through the content filter and
+// enforces the length limit both before and after filtering. It is run on every new chirp and again
+// whenever a chirp is edited. The returned result's Text is the body to store.
+func (cfg *apiConfig) cleanChirpBody(body string) (filter.Result, error) {
+	processed := text.Process(body)
+	if processed.Length > maxChirpLength || len(processed.Text) > maxChirpBytes {
+		return filter.Result{}, errChirpTooLong
+	}
+
+	result := cfg.contentFilter.Load().Apply(processed.Text)
+	if result.Rejected {
+		return filter.Result{}, errChirpRejected
+	}
+
+	// Replacements can be longer than the words they censor.
+	if result.Text != processed.Text {
+		filtered := text.Process(result.Text)
+		if filtered.Length > maxChirpLength || len(filtered.Text) > maxChirpBytes {
+			return filter.Result{}, errChirpTooLong
+		}
+	}
+	return result, nil
+}
+
+// respondWithChirpInputError reports an error from cleanChirpBody,
+// checkChirpInput or createChirp. Anything that isn't a problem with the
+// input is reported as a server error with the fallback message.
+func respondWithChirpInputError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, errChirpRejected):
+		respondWithError(w, http.StatusBadRequest, "Chirp contains a blocked word", err)
+	case errors.Is(err, errChirpTooLong):
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long", err)
+	case errors.Is(err, errTooManyMedia):
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d attachments", maxChirpMedia), err)
+	case errors.Is(err, errParentNotFound):
+		respondWithError(w, http.StatusNotFound, "Parent chirp not found", err)
+	case errors.Is(err, errParentDeleted):
+		respondWithError(w, http.StatusGone, "Parent chirp has been deleted", err)
+	case errors.Is(err, errQuoteNotFound):
+		respondWithError(w, http.StatusNotFound, "Quoted chirp not found", err)
+	case errors.Is(err, errQuoteDeleted):
+		respondWithError(w, http.StatusGone, "Quoted chirp has been deleted", err)
+	case errors.Is(err, errInvalidMedia):
+		respondWithError(w, http.StatusBadRequest, "Invalid media ID", err)
+	case errors.Is(err, errMediaAttached):
+		respondWithError(w, http.StatusConflict, "Media is already attached to a chirp", err)
+	default:
+		respondWithError(w, http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// isChirpInputError reports whether err is one of the errors that
+// respondWithChirpInputError turns into a client error.
+func isChirpInputError(err error) bool {
+	for _, target := range []error{
+		errChirpTooLong, errChirpRejected, errTooManyMedia,
+		errParentNotFound, errParentDeleted, errQuoteNotFound, errQuoteDeleted,
+		errInvalidMedia, errMediaAttached,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// chirpInput is what a user supplies for a new chirp.
+type chirpInput struct {
+	Body      string      `json:"body"`
+	ParentID  *uuid.UUID  `json:"parent_id"`
+	QuoteOfID *uuid.UUID  `json:"quote_of_id"`
+	MediaIDs  []uuid.UUID `json:"media_ids"`
+}
+
+// getReferencedChirp loads the chirp a new chirp replies to or quotes,
+// translating a missing or deleted chirp into the given errors.
+func getReferencedChirp(ctx context.Context, q *database.Queries, id uuid.UUID, notFound, deleted error) (database.Chirp, error) {
+	chirp, err := getOriginalChirp(ctx, q, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Chirp{}, notFound
+	}
+	if errors.Is(err, errChirpDeleted) {
+		return database.Chirp{}, deleted
+	}
+	return chirp, err
+}
+
+// checkChirpInput validates input without creating anything, for chirps
+// that will be created later. createChirp checks everything again.
+func (cfg *apiConfig) checkChirpInput(ctx context.Context, q *database.Queries, userID uuid.UUID, input chirpInput) error {
+	if _, err := cfg.cleanChirpBody(input.Body); err != nil {
+		return err
+	}
+
+	if len(input.MediaIDs) > maxChirpMedia {
+		return errTooManyMedia
+	}
+
+	if input.ParentID != nil {
+		if _, err := getReferencedChirp(ctx, q, *input.ParentID, errParentNotFound, errParentDeleted); err != nil {
+			return err
+		}
+	}
+
+	if input.QuoteOfID != nil {
+		if _, err := getReferencedChirp(ctx, q, *input.QuoteOfID, errQuoteNotFound, errQuoteDeleted); err != nil {
+			return err
+		}
+	}
+
+	for _, mediaID := range input.MediaIDs {
+		mediaFile, err := q.GetMediaFile(ctx, mediaID)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && mediaFile.UserID != userID {
+			return errInvalidMedia
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createChirp validates input and creates the chirp with q, which should be
+// bound to a transaction the caller commits. Fanning the chirp out to
+// timelines is left to the caller once the transaction has committed.
+func (cfg *apiConfig) createChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, input chirpInput) (database.Chirp, error) {
+	filtered, err := cfg.cleanChirpBody(input.Body)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if len(input.MediaIDs) > maxChirpMedia {
+		return database.Chirp{}, errTooManyMedia
+	}
+
+	createParams := database.CreateChirpParams{
+		UserID: userID,
+		Body:   filtered.Text,
+	}
+
+	if input.ParentID != nil {
+		parent, err := getReferencedChirp(ctx, q, *input.ParentID, errParentNotFound, errParentDeleted)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+
+		root := parent.RootID
+		if !root.Valid {
+			root = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		}
+		createParams.ParentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		createParams.RootID = root
+
+		if err := q.IncrementReplyCount(ctx, parent.ID); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if input.QuoteOfID != nil {
+		quoted, err := getReferencedChirp(ctx, q, *input.QuoteOfID, errQuoteNotFound, errQuoteDeleted)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		createParams.QuoteOfID = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+
+		if err := q.IncrementQuoteCount(ctx, quoted.ID); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	chirp, err := q.CreateChirp(ctx, createParams)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = q.UpsertChirpSearchDocument(ctx, database.UpsertChirpSearchDocumentParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if err := saveChirpEntities(ctx, q, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+
+	if err := recordContentFlags(ctx, q, chirp.ID, filtered); err != nil {
+		return database.Chirp{}, err
+	}
+
+	for i, mediaID := range input.MediaIDs {
+		mediaFile, err := q.GetMediaFile(ctx, mediaID)
+		if errors.Is(err, sql.ErrNoRows) || err == nil && mediaFile.UserID != userID {
+			return database.Chirp{}, errInvalidMedia
+		}
+		if err != nil {
+			return database.Chirp{}, err
+		}
+
+		err = q.CreateChirpAttachment(ctx, database.CreateChirpAttachmentParams{
+			ChirpID:  chirp.ID,
+			MediaID:  mediaID,
+			Position: int32(i),
+		})
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return database.Chirp{}, errMediaAttached
+			}
+			return database.Chirp{}, err
+		}
+	}
+
+	if err := recordChirpEvent(ctx, q, chirpCreatedEvent, chirp.ID); err != nil {
+		return database.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		chirpInput
+		PublishAt *time.Time `json:"publish_at"`
+		Poll      *pollInput `json:"poll"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
@@ -36,6 +527,10 @@ func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err = decoder.Decode(&params)
@@ -44,64 +539,84 @@ func (cfg *apiConfig) handlerChirpsCreate(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	var pollOptions []string
+	var pollClosesAt time.Time
+	if params.Poll != nil {
+		// Scheduled chirps are stored without a poll, so rather than lose it
+		// the request is refused.
+		if params.PublishAt != nil {
+			respondWithError(w, http.StatusBadRequest, "Scheduled chirps can't include a poll", nil)
+			return
+		}
+		var ok bool
+		pollOptions, pollClosesAt, ok = cfg.cleanPollInput(w, *params.Poll)
+		if !ok {
+			return
+		}
+	}
+
+	if params.PublishAt != nil {
+		cfg.scheduleChirp(w, r, userID, params.chirpInput, *params.PublishAt)
 		return
 	}
 
-	wordsToReplace := []string{"kerfuffle", "sharbert", "fornax"}
-	for _, word := range wordsToReplace {
-		pattern := `(?i)\b` + regexp.QuoteMeta(word) + `\b`
-		regex := regexp.MustCompile(pattern)
-		params.Body = regex.ReplaceAllString(params.Body, "****")
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
 
-	chirp, err := cfg.database.CreateChirp(r.Context(), database.CreateChirpParams{
-		UserID: userID,
-		Body:   params.Body,
-	})
+	chirp, err := cfg.createChirp(r.Context(), qtx, userID, params.chirpInput)
+	if err != nil {
+		if !isChirpInputError(err) {
+			log.Printf("Error creating chirp: %v", err)
+		}
+		respondWithChirpInputError(w, err, "Couldn't create chirp")
+		return
+	}
 
+	if params.Poll != nil {
+		err := createPoll(r.Context(), qtx, chirp.ID, pollOptions, pollClosesAt)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
+		return
+	}
+
+	go cfg.fanOutChirp(context.Background(), chirp)
+	go cfg.federateChirp(context.Background(), chirp)
+
+	response, err := cfg.chirpResponseFor(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
 	if err != nil {
-		log.Printf("Error creating chirp: %v", err)
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp", err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, returnVals{
-		ID:        chirp.ID.String(),
-		CreatedAt: chirp.CreatedAt.String(),
-		UpdatedAt: chirp.UpdatedAt.String(),
-		Body:      chirp.Body,
-		UserID:    chirp.UserID.String(),
-	})
+	respondWithJSON(w, http.StatusCreated, response)
 }
 
-func (cfg *apiConfig) handlerChirpsListAuthor(w http.ResponseWriter, r *http.Request, authorID uuid.UUID, sort string) {
-	chirps, err := cfg.database.ListChirpsByAuthor(r.Context(), authorID)
+func (cfg *apiConfig) handlerChirpsListAuthor(w http.ResponseWriter, r *http.Request, authorID uuid.UUID, sort string, viewerID uuid.NullUUID, visibility chirpVisibility) {
+	chirps, err := cfg.database.ListChirpsByAuthor(r.Context(), database.ListChirpsByAuthorParams{
+		UserID:        authorID,
+		ViewerID:      viewerID,
+		IncludeHidden: visibility.moderator,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
 		return
 	}
 
-	type chirpResponse struct {
-		ID        string `json:"id"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
-		Body      string `json:"body"`
-		UserID    string `json:"user_id"`
-	}
-
-	response := make([]chirpResponse, len(chirps))
-
-	for i, chirp := range chirps {
-		response[i] = chirpResponse{
-			ID:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			UserID:    chirp.UserID.String(),
-		}
+	response, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
+		return
 	}
 
 	if sort == "desc" {
@@ -110,7 +625,7 @@ func (cfg *apiConfig) handlerChirpsListAuthor(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	respondWithCacheableJSON(w, r, http.StatusOK, response, "", time.Time{}, cachePolicy(viewerID, chirpListMaxAge))
 }
 
 func (cfg *apiConfig) handlerChirpsList(w http.ResponseWriter, r *http.Request) {
@@ -120,6 +635,18 @@ func (cfg *apiConfig) handlerChirpsList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
+		return
+	}
+
 	authorID := r.URL.Query().Get("author_id")
 	if authorID != "" {
 		parseAuthorID, err := uuid.Parse(authorID)
@@ -127,33 +654,23 @@ func (cfg *apiConfig) handlerChirpsList(w http.ResponseWriter, r *http.Request)
 			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
 			return
 		}
-		cfg.handlerChirpsListAuthor(w, r, parseAuthorID, sort)
+		cfg.handlerChirpsListAuthor(w, r, parseAuthorID, sort, viewerID, visibility)
 		return
 	}
 
-	chirps, err := cfg.database.ListChirps(r.Context())
+	chirps, err := cfg.database.ListChirps(r.Context(), database.ListChirpsParams{
+		ViewerID:      viewerID,
+		IncludeHidden: visibility.moderator,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
 		return
 	}
 
-	type chirpResponse struct {
-		ID        string `json:"id"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
-		Body      string `json:"body"`
-		UserID    string `json:"user_id"`
-	}
-
-	response := make([]chirpResponse, len(chirps))
-	for i, chirp := range chirps {
-		response[i] = chirpResponse{
-			ID:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			UserID:    chirp.UserID.String(),
-		}
+	response, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list chirps", err)
+		return
 	}
 
 	if sort == "desc" {
@@ -162,7 +679,7 @@ func (cfg *apiConfig) handlerChirpsList(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	respondWithCacheableJSON(w, r, http.StatusOK, response, "", time.Time{}, cachePolicy(viewerID, chirpListMaxAge))
 }
 
 func (cfg *apiConfig) handlerChirpsRead(w http.ResponseWriter, r *http.Request) {
@@ -178,29 +695,40 @@ func (cfg *apiConfig) handlerChirpsRead(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
 	chirp, err := cfg.database.GetChirp(r.Context(), parseChirpID)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
 
-	type chirpResponse struct {
-		ID        string `json:"id"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
-		Body      string `json:"body"`
-		UserID    string `json:"user_id"`
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+	if !visibility.canSee(chirp) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
 	}
 
-	response := chirpResponse{
-		ID:        chirp.ID.String(),
-		CreatedAt: chirp.CreatedAt.String(),
-		UpdatedAt: chirp.UpdatedAt.String(),
-		Body:      chirp.Body,
-		UserID:    chirp.UserID.String(),
+	response, err := cfg.chirpResponseFor(r.Context(), chirp, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	respondWithCacheableJSON(w, r, http.StatusOK, response, chirpVersion(chirp), chirp.UpdatedAt, cachePolicy(viewerID, chirpMaxAge))
 }
 
 func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request) {
@@ -234,16 +762,61 @@ func (cfg *apiConfig) handlerChirpsDelete(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
 	if chirp.UserID.String() != userID.String() {
 		respondWithError(w, http.StatusForbidden, "You can only delete your own chirps", nil)
 		return
 	}
 
-	err = cfg.database.DeleteChirp(r.Context(), parseChirpID)
+	if !checkChirpPrecondition(w, r, chirp) {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	if err := recordChirpEvent(r.Context(), qtx, chirpDeletedEvent, chirp.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+
+	// Rechirps have nothing worth restoring, so they go straight away.
+	// Anything else, along with its rechirps, is kept for the restore window.
+	if chirp.RechirpOfID.Valid {
+		err = qtx.DeleteChirp(r.Context(), parseChirpID)
+	} else {
+		err = qtx.SoftDeleteChirp(r.Context(), parseChirpID)
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
 		return
 	}
 
+	err = decrementParentCounts(r.Context(), qtx, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete chirp", err)
+		return
+	}
+
+	err = cfg.timelines.RemoveChirp(r.Context(), chirp.ID)
+	if err != nil {
+		log.Printf("Error removing chirp %s from timelines: %v", chirp.ID, err)
+	}
+	go cfg.federateChirpDeletion(context.Background(), chirp)
+
 	w.WriteHeader(http.StatusNoContent)
 }
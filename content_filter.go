@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/filter"
+)
+
+type filterWordResponse struct {
+	ID          string        `json:"id"`
+	Word        string        `json:"word"`
+	Action      filter.Action `json:"action"`
+	Replacement string        `json:"replacement"`
+	CreatedAt   string        `json:"created_at"`
+	UpdatedAt   string        `json:"updated_at"`
+}
+
+func newFilterWordResponse(word database.FilterWord) filterWordResponse {
+	return filterWordResponse{
+		ID:          word.ID.String(),
+		Word:        word.Word,
+		Action:      filter.Action(word.Action),
+		Replacement: word.Replacement,
+		CreatedAt:   word.CreatedAt.String(),
+		UpdatedAt:   word.UpdatedAt.String(),
+	}
+}
+
+// loadContentFilter rebuilds the content filter from the word list. Requests
+// already running keep the filter they started with.
+func (cfg *apiConfig) loadContentFilter(ctx context.Context) error {
+	words, err := cfg.database.ListFilterWords(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]filter.Rule, len(words))
+	for i, word := range words {
+		rules[i] = filter.Rule{
+			Word:        word.Word,
+			Action:      filter.Action(word.Action),
+			Replacement: word.Replacement,
+		}
+	}
+	cfg.contentFilter.Store(filter.New(rules))
+	return nil
+}
+
+// runContentFilterReloader reloads the word list every interval, so changes
+// made through another server instance take effect here too.
+func (cfg *apiConfig) runContentFilterReloader(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.loadContentFilter(ctx); err != nil {
+			log.Printf("Error reloading content filter: %v", err)
+		}
+	})
+}
+
+// recordContentFlags queues a chirp for review if its body matched any flag
+// words.
+func recordContentFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, result filter.Result) error {
+	if !result.Flagged {
+		return nil
+	}
+
+	var words []string
+	for _, match := range result.Matches {
+		if match.Rule.Action == filter.Flag {
+			words = append(words, match.Rule.Word)
+		}
+	}
+	return q.CreateContentFlag(ctx, database.CreateContentFlagParams{
+		ChirpID: chirpID,
+		Words:   words,
+	})
+}
+
+// filterWordParams validates a word list entry from a request body.
+func filterWordParams(r *http.Request) (database.CreateFilterWordParams, error) {
+	type parameters struct {
+		Word        string        `json:"word"`
+		Action      filter.Action `json:"action"`
+		Replacement *string       `json:"replacement"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	if err := decoder.Decode(&params); err != nil {
+		return database.CreateFilterWordParams{}, err
+	}
+
+	word := filter.Normalize(params.Word)
+	if word == "" {
+		return database.CreateFilterWordParams{}, errors.New("word must contain letters or digits")
+	}
+	if !params.Action.Valid() {
+		return database.CreateFilterWordParams{}, errors.New("action must be replace, reject or flag")
+	}
+
+	replacement := filter.DefaultReplacement
+	if params.Replacement != nil {
+		replacement = *params.Replacement
+	}
+
+	return database.CreateFilterWordParams{
+		Word:        word,
+		Action:      string(params.Action),
+		Replacement: replacement,
+	}, nil
+}
+
+func (cfg *apiConfig) handlerFilterWordsList(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	words, err := cfg.database.ListFilterWords(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve filter words", err)
+		return
+	}
+
+	response := make([]filterWordResponse, len(words))
+	for i, word := range words {
+		response[i] = newFilterWordResponse(word)
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerFilterWordsCreate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	params, err := filterWordParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter word", err)
+		return
+	}
+
+	word, err := cfg.database.CreateFilterWord(r.Context(), params)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, http.StatusConflict, "Word is already listed", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create filter word", err)
+		return
+	}
+
+	if err := cfg.loadContentFilter(r.Context()); err != nil {
+		log.Printf("Error reloading content filter: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, newFilterWordResponse(word))
+}
+
+func (cfg *apiConfig) handlerFilterWordsUpdate(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	wordID, err := uuid.Parse(r.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid word ID", err)
+		return
+	}
+
+	params, err := filterWordParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid filter word", err)
+		return
+	}
+
+	word, err := cfg.database.UpdateFilterWord(r.Context(), database.UpdateFilterWordParams{
+		Word:        params.Word,
+		Action:      params.Action,
+		Replacement: params.Replacement,
+		ID:          wordID,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, http.StatusConflict, "Word is already listed", err)
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Filter word not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update filter word", err)
+		return
+	}
+
+	if err := cfg.loadContentFilter(r.Context()); err != nil {
+		log.Printf("Error reloading content filter: %v", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, newFilterWordResponse(word))
+}
+
+func (cfg *apiConfig) handlerFilterWordsDelete(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireRole(w, r, roleAdmin); !ok {
+		return
+	}
+
+	wordID, err := uuid.Parse(r.PathValue("wordID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid word ID", err)
+		return
+	}
+
+	deleted, err := cfg.database.DeleteFilterWord(r.Context(), wordID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete filter word", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Filter word not found", nil)
+		return
+	}
+
+	if err := cfg.loadContentFilter(r.Context()); err != nil {
+		log.Printf("Error reloading content filter: %v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerContentFlagsList(w http.ResponseWriter, r *http.Request) {
+	type flagResponse struct {
+		ID        string        `json:"id"`
+		Words     []string      `json:"words"`
+		CreatedAt string        `json:"created_at"`
+		Chirp     chirpResponse `json:"chirp"`
+	}
+
+	type returnVals struct {
+		Flags      []flagResponse `json:"flags"`
+		NextOffset *int32         `json:"next_offset"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	flags, err := cfg.database.ListOpenContentFlags(r.Context(), database.ListOpenContentFlagsParams{
+		Limit:  limit + 1,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve content flags", err)
+		return
+	}
+
+	var nextOffset *int32
+	if int32(len(flags)) > limit {
+		flags = flags[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	ids := make([]uuid.UUID, len(flags))
+	for i, flag := range flags {
+		ids[i] = flag.ChirpID
+	}
+	chirps, err := cfg.database.GetChirpsByIDs(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve content flags", err)
+		return
+	}
+	chirpResponses, err := cfg.chirpResponses(r.Context(), chirps, uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve content flags", err)
+		return
+	}
+	byID := make(map[string]chirpResponse, len(chirpResponses))
+	for _, chirp := range chirpResponses {
+		byID[chirp.ID] = chirp
+	}
+
+	response := returnVals{Flags: []flagResponse{}, NextOffset: nextOffset}
+	for _, flag := range flags {
+		response.Flags = append(response.Flags, flagResponse{
+			ID:        flag.ID.String(),
+			Words:     flag.Words,
+			CreatedAt: flag.CreatedAt.String(),
+			Chirp:     byID[flag.ChirpID.String()],
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerContentFlagsResolve(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	flagID, err := uuid.Parse(r.PathValue("flagID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid flag ID", err)
+		return
+	}
+
+	resolved, err := cfg.database.ResolveContentFlag(r.Context(), database.ResolveContentFlagParams{
+		ResolvedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ID:         flagID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't resolve content flag", err)
+		return
+	}
+	if resolved == 0 {
+		respondWithError(w, http.StatusNotFound, "Open content flag not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
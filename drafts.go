@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+type draftResponse struct {
+	ID        string   `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+	Body      string   `json:"body"`
+	ParentID  *string  `json:"parent_id"`
+	QuoteOfID *string  `json:"quote_of_id"`
+	MediaIDs  []string `json:"media_ids"`
+}
+
+func newDraftResponse(draft database.Draft) draftResponse {
+	mediaIDs := make([]string, len(draft.MediaIds))
+	for i, id := range draft.MediaIds {
+		mediaIDs[i] = id.String()
+	}
+	return draftResponse{
+		ID:        draft.ID.String(),
+		CreatedAt: draft.CreatedAt.String(),
+		UpdatedAt: draft.UpdatedAt.String(),
+		Body:      draft.Body,
+		ParentID:  nullUUIDString(draft.ParentID),
+		QuoteOfID: nullUUIDString(draft.QuoteOfID),
+		MediaIDs:  mediaIDs,
+	}
+}
+
+// checkDraftInput applies the few limits a draft has before it is published.
+// On failure it writes the error response and returns false.
+func checkDraftInput(w http.ResponseWriter, input chirpInput) bool {
+	// Drafts are only held to the chirp length limit when they are published,
+	// so they are just capped at the most a chirp could ever store.
+	if len(input.Body) > maxChirpBytes {
+		respondWithError(w, http.StatusBadRequest, "Draft is too long", nil)
+		return false
+	}
+	if len(input.MediaIDs) > maxChirpMedia {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d attachments", maxChirpMedia), nil)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handlerDraftsCreate(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := chirpInput{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !checkDraftInput(w, params) {
+		return
+	}
+
+	mediaIDs := params.MediaIDs
+	if mediaIDs == nil {
+		mediaIDs = []uuid.UUID{}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	if err := qtx.LockUserForDrafts(r.Context(), userID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft", err)
+		return
+	}
+
+	draft, err := qtx.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:    userID,
+		Body:      params.Body,
+		ParentID:  uuidPtrToNull(params.ParentID),
+		QuoteOfID: uuidPtrToNull(params.QuoteOfID),
+		MediaIds:  mediaIDs,
+		MaxDrafts: int64(cfg.maxDrafts),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusConflict, fmt.Sprintf("You can have at most %d drafts", cfg.maxDrafts), err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newDraftResponse(draft))
+}
+
+func (cfg *apiConfig) handlerDraftsList(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	drafts, err := cfg.database.ListDrafts(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list drafts", err)
+		return
+	}
+
+	response := make([]draftResponse, len(drafts))
+	for i, draft := range drafts {
+		response[i] = newDraftResponse(draft)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerDraftsGet(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	// Drafts are looked up by owner as well as ID, so other users' drafts
+	// are indistinguishable from missing ones.
+	draft, err := cfg.database.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Draft not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+func (cfg *apiConfig) handlerDraftsUpdate(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := chirpInput{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !checkDraftInput(w, params) {
+		return
+	}
+
+	mediaIDs := params.MediaIDs
+	if mediaIDs == nil {
+		mediaIDs = []uuid.UUID{}
+	}
+
+	draft, err := cfg.database.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:        draftID,
+		UserID:    userID,
+		Body:      params.Body,
+		ParentID:  uuidPtrToNull(params.ParentID),
+		QuoteOfID: uuidPtrToNull(params.QuoteOfID),
+		MediaIds:  mediaIDs,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Draft not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newDraftResponse(draft))
+}
+
+func (cfg *apiConfig) handlerDraftsDelete(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	deleted, err := cfg.database.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete draft", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Draft not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDraftsPublish turns a draft into a chirp with the same checks and
+// filtering as POST /api/chirps. The draft is removed in the same
+// transaction, so it can't be published twice.
+func (cfg *apiConfig) handlerDraftsPublish(w http.ResponseWriter, r *http.Request) {
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	if !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	draft, err := qtx.GetDraftForUpdate(r.Context(), database.GetDraftForUpdateParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Draft not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+
+	chirp, err := cfg.createChirp(r.Context(), qtx, userID, chirpInput{
+		Body:      draft.Body,
+		ParentID:  nullUUIDPtr(draft.ParentID),
+		QuoteOfID: nullUUIDPtr(draft.QuoteOfID),
+		MediaIDs:  draft.MediaIds,
+	})
+	if err != nil {
+		if !isChirpInputError(err) {
+			log.Printf("Error publishing draft %s: %v", draft.ID, err)
+		}
+		respondWithChirpInputError(w, err, "Couldn't publish draft")
+		return
+	}
+
+	if _, err := qtx.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: draft.ID, UserID: userID}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+
+	go cfg.fanOutChirp(context.Background(), chirp)
+	go cfg.federateChirp(context.Background(), chirp)
+
+	response, err := cfg.chirpResponseFor(r.Context(), chirp, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
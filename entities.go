@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/cursor"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/entities"
+)
+
+type entityResponse struct {
+	Type   entities.Kind `json:"type"`
+	Text   string        `json:"text"`
+	UserID *string       `json:"user_id,omitempty"`
+	Start  int32         `json:"start"`
+	End    int32         `json:"end"`
+}
+
+// saveChirpEntities replaces a chirp's hashtags, mentions and URLs with the
+// ones found in its current body. Mentions of handles that don't belong to anyone
+// are left as plain text.
+func saveChirpEntities(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := q.DeleteChirpURLs(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	found := entities.Extract(chirp.Body)
+
+	var handles []string
+	for _, entity := range found {
+		if entity.Kind == entities.Mention {
+			handles = append(handles, entity.Text)
+		}
+	}
+	userIDs := map[string]uuid.UUID{}
+	if len(handles) > 0 {
+		users, err := q.ListUsersByHandles(ctx, handles)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			userIDs[user.Handle.String] = user.ID
+		}
+	}
+
+	for _, entity := range found {
+		switch entity.Kind {
+		case entities.Hashtag:
+			err := q.CreateChirpHashtag(ctx, database.CreateChirpHashtagParams{
+				ChirpID:     chirp.ID,
+				Tag:         entity.Text,
+				StartOffset: int32(entity.Start),
+				EndOffset:   int32(entity.End),
+			})
+			if err != nil {
+				return err
+			}
+		case entities.Mention:
+			userID, ok := userIDs[entity.Text]
+			if !ok {
+				continue
+			}
+			err := q.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+				ChirpID:     chirp.ID,
+				UserID:      userID,
+				Handle:      entity.Text,
+				StartOffset: int32(entity.Start),
+				EndOffset:   int32(entity.End),
+			})
+			if err != nil {
+				return err
+			}
+		case entities.URL:
+			err := q.CreateChirpURL(ctx, database.CreateChirpURLParams{
+				ChirpID:     chirp.ID,
+				Url:         entity.Text,
+				StartOffset: int32(entity.Start),
+				EndOffset:   int32(entity.End),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chirpEntities loads the entities of several chirps at once, keyed by chirp
+// ID and ordered by position in the body.
+func (cfg *apiConfig) chirpEntities(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]entityResponse, error) {
+	result := make(map[uuid.UUID][]entityResponse, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	hashtags, err := cfg.database.ListHashtagsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, hashtag := range hashtags {
+		result[hashtag.ChirpID] = append(result[hashtag.ChirpID], entityResponse{
+			Type:  entities.Hashtag,
+			Text:  hashtag.Tag,
+			Start: hashtag.StartOffset,
+			End:   hashtag.EndOffset,
+		})
+	}
+
+	mentions, err := cfg.database.ListMentionsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, mention := range mentions {
+		userID := mention.UserID.String()
+		result[mention.ChirpID] = append(result[mention.ChirpID], entityResponse{
+			Type:   entities.Mention,
+			Text:   mention.Handle,
+			UserID: &userID,
+			Start:  mention.StartOffset,
+			End:    mention.EndOffset,
+		})
+	}
+
+	urls, err := cfg.database.ListURLsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, url := range urls {
+		result[url.ChirpID] = append(result[url.ChirpID], entityResponse{
+			Type:  entities.URL,
+			Text:  url.Url,
+			Start: url.StartOffset,
+			End:   url.EndOffset,
+		})
+	}
+
+	for _, list := range result {
+		sort.Slice(list, func(i, j int) bool { return list[i].Start < list[j].Start })
+	}
+	return result, nil
+}
+
+func (cfg *apiConfig) handlerHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	type returnVals struct {
+		Chirps     []chirpResponse `json:"chirps"`
+		NextCursor *string         `json:"next_cursor"`
+	}
+
+	tag := entities.NormalizeHashtag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag", nil)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	limit, _, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	beforeCreatedAt, beforeID, err := parseChirpCursor(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+
+	chirps, err := cfg.database.ListChirpsByHashtag(r.Context(), database.ListChirpsByHashtagParams{
+		Tag:             tag,
+		BeforeCreatedAt: beforeCreatedAt,
+		BeforeID:        beforeID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	chirps, nextCursor := chirpPage(chirps, limit)
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+	chirps = visibility.filter(chirps)
+
+	response, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Chirps:     response,
+		NextCursor: nextCursor,
+	})
+}
+
+func (cfg *apiConfig) handlerUserMentions(w http.ResponseWriter, r *http.Request) {
+	type returnVals struct {
+		Chirps     []chirpResponse `json:"chirps"`
+		NextCursor *string         `json:"next_cursor"`
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	limit, _, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	beforeCreatedAt, beforeID, err := parseChirpCursor(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+		return
+	}
+
+	chirps, err := cfg.database.ListChirpsMentioningUser(r.Context(), database.ListChirpsMentioningUserParams{
+		UserID:          userID,
+		BeforeCreatedAt: beforeCreatedAt,
+		BeforeID:        beforeID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	chirps, nextCursor := chirpPage(chirps, limit)
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+	chirps = visibility.filter(chirps)
+
+	response, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Chirps:     response,
+		NextCursor: nextCursor,
+	})
+}
+
+// parseChirpCursor reads the optional cursor parameter of a newest-first
+// chirp listing.
+func parseChirpCursor(r *http.Request) (sql.NullTime, uuid.NullUUID, error) {
+	value := r.URL.Query().Get("cursor")
+	if value == "" {
+		return sql.NullTime{}, uuid.NullUUID{}, nil
+	}
+
+	createdAt, id, err := cursor.Decode(value)
+	if err != nil {
+		return sql.NullTime{}, uuid.NullUUID{}, err
+	}
+	return sql.NullTime{Time: createdAt, Valid: true}, uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// chirpPage trims a listing fetched with one extra row down to limit and
+// returns the cursor for the next page, if there is one.
+func chirpPage(chirps []database.Chirp, limit int32) ([]database.Chirp, *string) {
+	if int32(len(chirps)) <= limit {
+		return chirps, nil
+	}
+	chirps = chirps[:limit]
+	last := chirps[len(chirps)-1]
+	next := cursor.Encode(last.CreatedAt, last.ID)
+	return chirps, &next
+}
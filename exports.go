@@ -0,0 +1,548 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/media"
+	"github.com/mjayio/server/internal/signedurl"
+)
+
+const (
+	exportReady = "ready"
+
+	// exportBatchSize is how many exports one worker builds per claim.
+	// Archives can be large, so it is kept small.
+	exportBatchSize = 2
+	// exportLease is how long a claimed export stays claimed. A worker that
+	// dies mid-build leaves it to be picked up again once the lease runs out.
+	exportLease = 30 * time.Minute
+	// exportChirpBatchSize is how many chirps are loaded and converted at a
+	// time while writing chirps.json.
+	exportChirpBatchSize = 200
+)
+
+type dataExportResponse struct {
+	ID          string  `json:"id"`
+	Status      string  `json:"status"`
+	CreatedAt   string  `json:"created_at"`
+	CompletedAt *string `json:"completed_at"`
+	ExpiresAt   *string `json:"expires_at"`
+	SizeBytes   *int64  `json:"size_bytes"`
+	Error       *string `json:"error"`
+	DownloadURL string  `json:"download_url,omitempty"`
+}
+
+// newDataExportResponse describes an export. Ready exports get a download
+// link signed for exportLinkTTL, so a leaked link stops working soon after.
+func (cfg *apiConfig) newDataExportResponse(r *http.Request, export database.DataExport) dataExportResponse {
+	resp := dataExportResponse{
+		ID:          export.ID.String(),
+		Status:      export.Status,
+		CreatedAt:   export.CreatedAt.String(),
+		CompletedAt: nullTimeString(export.CompletedAt),
+		ExpiresAt:   nullTimeString(export.ExpiresAt),
+		Error:       nullStringPtr(export.Error),
+	}
+	if export.SizeBytes.Valid {
+		resp.SizeBytes = &export.SizeBytes.Int64
+	}
+	if export.Status == exportReady {
+		path := "/api/exports/" + export.ID.String() + "/download"
+		resp.DownloadURL = cfg.absoluteURL(r, signedurl.Sign([]byte(cfg.secret), path, time.Now().Add(cfg.exportLinkTTL)))
+	}
+	return resp
+}
+
+func exportKey(id uuid.UUID) string {
+	return "exports/" + id.String() + ".zip"
+}
+
+func dataExportExpired(export database.DataExport) bool {
+	return export.ExpiresAt.Valid && !export.ExpiresAt.Time.After(time.Now())
+}
+
+// handlerDataExportCreate queues an export of everything the user has
+// posted. Asking again while one is still being built returns that one.
+func (cfg *apiConfig) handlerDataExportCreate(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	export, err := cfg.database.CreateDataExport(r.Context(), database.CreateDataExportParams{
+		ID:     uuid.New(),
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// One is already pending, and it is the latest.
+		export, err = cfg.database.GetLatestDataExport(r.Context(), userID)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create export", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, cfg.newDataExportResponse(r, export))
+}
+
+// handlerDataExportGet reports on the user's latest export, with a fresh
+// download link once it is ready.
+func (cfg *apiConfig) handlerDataExportGet(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	export, err := cfg.database.GetLatestDataExport(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Export not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve export", err)
+		return
+	}
+	if dataExportExpired(export) {
+		respondWithError(w, http.StatusNotFound, "Export not found", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, cfg.newDataExportResponse(r, export))
+}
+
+// handlerDataExportDownload streams a finished archive. The signed link is
+// the only credential, so it can be opened straight from a browser.
+func (cfg *apiConfig) handlerDataExportDownload(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid export ID", err)
+		return
+	}
+
+	err = signedurl.Verify([]byte(cfg.secret), r.URL.Path, r.URL.Query(), time.Now())
+	if err != nil {
+		if errors.Is(err, signedurl.ErrExpired) {
+			respondWithError(w, http.StatusGone, "Download link has expired", err)
+			return
+		}
+		respondWithError(w, http.StatusForbidden, "Invalid download link", err)
+		return
+	}
+
+	export, err := cfg.database.GetDataExport(r.Context(), exportID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Export not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve export", err)
+		return
+	}
+	if export.Status != exportReady || dataExportExpired(export) {
+		respondWithError(w, http.StatusNotFound, "Export not found", nil)
+		return
+	}
+
+	blob, err := cfg.blobs.Get(r.Context(), export.BlobKey.String)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Export not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve export", err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export-`+export.CreatedAt.Format("2006-01-02")+`.zip"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	if export.SizeBytes.Valid {
+		w.Header().Set("Content-Length", strconv.FormatInt(export.SizeBytes.Int64, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// runDataExporter removes expired exports and builds pending ones every
+// interval.
+func (cfg *apiConfig) runDataExporter(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.purgeDataExports(ctx); err != nil {
+			log.Printf("Error removing expired exports: %v", err)
+		}
+		if err := cfg.buildPendingDataExports(ctx); err != nil {
+			log.Printf("Error building exports: %v", err)
+		}
+	})
+}
+
+func (cfg *apiConfig) purgeDataExports(ctx context.Context) error {
+	exports, err := cfg.database.ListExpiredDataExports(ctx)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if export.BlobKey.Valid {
+			if err := cfg.blobs.Delete(ctx, export.BlobKey.String); err != nil {
+				log.Printf("Error deleting blob %s: %v", export.BlobKey.String, err)
+				continue
+			}
+		}
+		if err := cfg.database.DeleteDataExport(ctx, export.ID); err != nil {
+			log.Printf("Error removing export %s: %v", export.ID, err)
+		}
+	}
+	return nil
+}
+
+// buildPendingDataExports builds pending exports a batch at a time until
+// none are left. Rows are claimed with SKIP LOCKED, so several servers can
+// run it at once without building anything twice.
+func (cfg *apiConfig) buildPendingDataExports(ctx context.Context) error {
+	for {
+		exports, err := cfg.database.ClaimDataExports(ctx, database.ClaimDataExportsParams{
+			LeaseUntil: sql.NullTime{Time: time.Now().Add(exportLease), Valid: true},
+			BatchSize:  exportBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, export := range exports {
+			cfg.buildDataExport(ctx, export)
+		}
+
+		if len(exports) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// buildDataExport writes an export's archive to the blob store and settles
+// its row. Failed exports are kept until they expire so the user can see
+// what happened, and can then ask for another.
+func (cfg *apiConfig) buildDataExport(ctx context.Context, export database.DataExport) {
+	key := exportKey(export.ID)
+	size, err := cfg.writeDataExportBlob(ctx, key, export.UserID)
+	expiresAt := sql.NullTime{Time: time.Now().Add(cfg.exportTTL), Valid: true}
+	if err != nil {
+		log.Printf("Error building export %s: %v", export.ID, err)
+		err = cfg.database.FailDataExport(ctx, database.FailDataExportParams{
+			ID:        export.ID,
+			ExpiresAt: expiresAt,
+			Error:     sql.NullString{String: err.Error(), Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error failing export %s: %v", export.ID, err)
+		}
+		return
+	}
+
+	err = cfg.database.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		ID:        export.ID,
+		ExpiresAt: expiresAt,
+		BlobKey:   sql.NullString{String: key, Valid: true},
+		SizeBytes: sql.NullInt64{Int64: size, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error completing export %s: %v", export.ID, err)
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeDataExportBlob streams a user's archive into the blob store as it is
+// built, so it never has to fit in memory, and returns its size.
+func (cfg *apiConfig) writeDataExportBlob(ctx context.Context, key string, userID uuid.UUID) (int64, error) {
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw}
+	go func() {
+		zw := zip.NewWriter(counter)
+		err := cfg.writeDataExport(ctx, zw, userID)
+		if err == nil {
+			err = zw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	if err := cfg.blobs.Put(ctx, key, pr); err != nil {
+		// Unblocks the writer if the store gave up part way.
+		pr.CloseWithError(err)
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+type exportedUser struct {
+	ID             string  `json:"id"`
+	Email          string  `json:"email"`
+	Handle         *string `json:"handle"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+	IsChirpyRed    bool    `json:"is_chirpy_red"`
+	Role           string  `json:"role"`
+	FollowerCount  int32   `json:"follower_count"`
+	FollowingCount int32   `json:"following_count"`
+}
+
+type exportedChirp struct {
+	chirpResponse
+	DeletedAt *string `json:"deleted_at"`
+}
+
+type exportedLike struct {
+	ChirpID string `json:"chirp_id"`
+	LikedAt string `json:"liked_at"`
+}
+
+type exportedFollow struct {
+	UserID     string `json:"user_id"`
+	FollowedAt string `json:"followed_at"`
+}
+
+type exportedMedia struct {
+	mediaResponse
+	SizeBytes int32  `json:"size_bytes"`
+	File      string `json:"file,omitempty"`
+}
+
+// writeDataExport writes everything a user has put into Chirpy to zw:
+// their profile, every chirp including deleted and hidden ones, likes,
+// follows in both directions and the originals of their uploads.
+func (cfg *apiConfig) writeDataExport(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	user, err := cfg.database.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = writeExportJSON(zw, "profile.json", exportedUser{
+		ID:             user.ID.String(),
+		Email:          user.Email,
+		Handle:         nullStringPtr(user.Handle),
+		CreatedAt:      user.CreatedAt.String(),
+		UpdatedAt:      user.UpdatedAt.String(),
+		IsChirpyRed:    user.IsChirpyRed,
+		Role:           user.Role,
+		FollowerCount:  user.FollowerCount,
+		FollowingCount: user.FollowingCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.writeExportChirps(ctx, zw, userID); err != nil {
+		return err
+	}
+
+	likes, err := cfg.database.ListLikesByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exportedLikes := make([]exportedLike, len(likes))
+	for i, like := range likes {
+		exportedLikes[i] = exportedLike{
+			ChirpID: like.ChirpID.String(),
+			LikedAt: like.CreatedAt.String(),
+		}
+	}
+	if err := writeExportJSON(zw, "likes.json", exportedLikes); err != nil {
+		return err
+	}
+
+	follows, err := cfg.database.ListFollowsOfUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	following := []exportedFollow{}
+	followers := []exportedFollow{}
+	for _, follow := range follows {
+		if follow.FollowerID == userID {
+			following = append(following, exportedFollow{follow.FolloweeID.String(), follow.CreatedAt.String()})
+		} else {
+			followers = append(followers, exportedFollow{follow.FollowerID.String(), follow.CreatedAt.String()})
+		}
+	}
+	err = writeExportJSON(zw, "follows.json", struct {
+		Following []exportedFollow `json:"following"`
+		Followers []exportedFollow `json:"followers"`
+	}{following, followers})
+	if err != nil {
+		return err
+	}
+
+	files, err := cfg.database.ListMediaFilesByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	exportedFiles := make([]exportedMedia, len(files))
+	for i, file := range files {
+		exportedFiles[i] = exportedMedia{
+			mediaResponse: newMediaResponse(file),
+			SizeBytes:     file.SizeBytes,
+		}
+		name := "media/" + file.ID.String() + mediaExtension(file.ContentType)
+		ok, err := cfg.writeExportBlob(ctx, zw, name, mediaKey(file.ID), file.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if ok {
+			exportedFiles[i].File = name
+		}
+	}
+	return writeExportJSON(zw, "media.json", exportedFiles)
+}
+
+// writeExportChirps writes chirps.json a page at a time, so a prolific
+// user's chirps never have to be held in memory at once. The output is the
+// same as writeExportJSON would give for the whole list.
+func (cfg *apiConfig) writeExportChirps(ctx context.Context, zw *zip.Writer, userID uuid.UUID) error {
+	w, err := createExportFile(zw, "chirps.json")
+	if err != nil {
+		return err
+	}
+
+	viewer := uuid.NullUUID{UUID: userID, Valid: true}
+	params := database.ListAllChirpsByUserAfterParams{
+		UserID:   userID,
+		PageSize: exportChirpBatchSize,
+	}
+	written := 0
+	for {
+		chirps, err := cfg.database.ListAllChirpsByUserAfter(ctx, params)
+		if err != nil {
+			return err
+		}
+		if len(chirps) == 0 {
+			break
+		}
+		responses, err := cfg.chirpResponses(ctx, chirps, viewer)
+		if err != nil {
+			return err
+		}
+		for i, resp := range responses {
+			data, err := json.MarshalIndent(exportedChirp{
+				chirpResponse: resp,
+				DeletedAt:     nullTimeString(chirps[i].DeletedAt),
+			}, "  ", "  ")
+			if err != nil {
+				return err
+			}
+			separator := ",\n  "
+			if written == 0 {
+				separator = "[\n  "
+			}
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			written++
+		}
+
+		if len(chirps) < exportChirpBatchSize {
+			break
+		}
+		last := chirps[len(chirps)-1]
+		params.AfterCreatedAt = sql.NullTime{Time: last.CreatedAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: last.ID, Valid: true}
+	}
+
+	end := "\n]\n"
+	if written == 0 {
+		end = "[]\n"
+	}
+	_, err = io.WriteString(w, end)
+	return err
+}
+
+func createExportFile(zw *zip.Writer, name string) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+}
+
+func writeExportJSON(zw *zip.Writer, name string, v any) error {
+	w, err := createExportFile(zw, name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeExportBlob copies a blob into the archive, reporting false if it has
+// gone missing. Uploads are already compressed, so they are stored as is.
+func (cfg *apiConfig) writeExportBlob(ctx context.Context, zw *zip.Writer, name, key string, modified time.Time) (bool, error) {
+	blob, err := cfg.blobs.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer blob.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: modified,
+	})
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(w, blob)
+	return err == nil, err
+}
+
+func mediaExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/activitypub"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/entities"
+)
+
+const (
+	// outboxSize is how many of a user's newest chirps their outbox lists.
+	outboxSize = 20
+	// deliveryBatchSize is how many queued deliveries are claimed at once,
+	// and deliveryConcurrency how many of them are sent at the same time.
+	deliveryBatchSize   = 20
+	deliveryConcurrency = 4
+	// deliveryLease is how long a claimed delivery is left alone before
+	// another server may retry it.
+	deliveryLease = 5 * time.Minute
+	// maxDeliveryAttempts is how many times a delivery is tried, with the
+	// wait between attempts doubling from a minute up to maxDeliveryBackoff.
+	maxDeliveryAttempts = 10
+	maxDeliveryBackoff  = 12 * time.Hour
+)
+
+// actorURI returns the ActivityPub ID of a local user. IDs are permanent, so
+// they are built from BASE_URL rather than whatever host a request used.
+func (cfg *apiConfig) actorURI(userID uuid.UUID) string {
+	return strings.TrimSuffix(cfg.baseURL, "/") + "/ap/users/" + userID.String()
+}
+
+// noteURI returns the ActivityPub ID of a chirp.
+func (cfg *apiConfig) noteURI(chirpID uuid.UUID) string {
+	return strings.TrimSuffix(cfg.baseURL, "/") + "/ap/chirps/" + chirpID.String()
+}
+
+func (cfg *apiConfig) sharedInboxURI() string {
+	return strings.TrimSuffix(cfg.baseURL, "/") + "/ap/inbox"
+}
+
+// localID returns the UUID in a URI made by actorURI or noteURI, depending on
+// prefix. It reports false for URIs on other servers.
+func (cfg *apiConfig) localID(uri, prefix string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(uri, strings.TrimSuffix(cfg.baseURL, "/")+prefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(rest)
+	return id, err == nil
+}
+
+// federationDomain is the domain in the account names of local users, as in
+// alice@chirpy.example.
+func (cfg *apiConfig) federationDomain() string {
+	base, err := url.Parse(cfg.baseURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(base.Host)
+}
+
+// federatedHandle returns the handle of a user other servers can see. Actors
+// need a username, so users without a handle aren't federated.
+func (cfg *apiConfig) federatedHandle(ctx context.Context, userID uuid.UUID) (string, bool, error) {
+	users, err := cfg.database.ListUsersByIDs(ctx, []uuid.UUID{userID})
+	if err != nil {
+		return "", false, err
+	}
+	if len(users) == 0 || !users[0].Handle.Valid {
+		return "", false, nil
+	}
+	return users[0].Handle.String, true, nil
+}
+
+// actorKey returns the key a user signs federated requests with, creating it
+// the first time it is needed.
+func (cfg *apiConfig) actorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	key, err := cfg.database.GetActorKey(ctx, userID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return key, err
+	}
+
+	private, err := activitypub.GenerateKey()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	privatePEM, err := activitypub.EncodePrivateKey(private)
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	publicPEM, err := activitypub.EncodePublicKey(&private.PublicKey)
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+
+	// When two requests race to create the key, the first one wins and both
+	// read it back.
+	err = cfg.database.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userID,
+		PublicKeyPem:  publicPEM,
+		PrivateKeyPem: privatePEM,
+	})
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	return cfg.database.GetActorKey(ctx, userID)
+}
+
+func respondWithActivityJSON(w http.ResponseWriter, code int, contentType string, payload interface{}) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) handlerWebFinger(w http.ResponseWriter, r *http.Request) {
+	handle, domain, err := activitypub.ParseAcct(r.URL.Query().Get("resource"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid resource", err)
+		return
+	}
+	if domain != cfg.federationDomain() {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	users, err := cfg.database.ListUsersByHandles(r.Context(), []string{entities.NormalizeHandle(handle)})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return
+	}
+	if len(users) == 0 {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	actor := cfg.actorURI(users[0].ID)
+	respondWithActivityJSON(w, http.StatusOK, activitypub.WebFingerContentType, activitypub.JRD{
+		Subject: "acct:" + users[0].Handle.String + "@" + domain,
+		Aliases: []string{actor},
+		Links: []activitypub.Link{
+			{Rel: "self", Type: activitypub.ContentType, Href: actor},
+		},
+	})
+}
+
+func (cfg *apiConfig) handlerActor(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	handle, ok, err := cfg.federatedHandle(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	key, err := cfg.actorKey(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load actor key", err)
+		return
+	}
+
+	id := cfg.actorURI(userID)
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, activitypub.Actor{
+		Context:           []string{activitypub.ActivityStreamsContext, activitypub.SecurityContext},
+		ID:                id,
+		Type:              "Person",
+		PreferredUsername: handle,
+		Name:              handle,
+		Inbox:             id + "/inbox",
+		Outbox:            id + "/outbox",
+		Followers:         id + "/followers",
+		Endpoints:         &activitypub.Endpoints{SharedInbox: cfg.sharedInboxURI()},
+		PublicKey: activitypub.PublicKey{
+			ID:           id + "#main-key",
+			Owner:        id,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	})
+}
+
+// chirpNote returns a chirp as an ActivityPub note, addressed to everyone
+// and copied to the author's followers.
+func (cfg *apiConfig) chirpNote(chirp database.Chirp) activitypub.Note {
+	actor := cfg.actorURI(chirp.UserID)
+	note := activitypub.Note{
+		ID:           cfg.noteURI(chirp.ID),
+		Type:         "Note",
+		AttributedTo: actor,
+		Content:      activitypub.HTMLContent(chirp.Body),
+		Published:    chirp.CreatedAt.UTC().Format(time.RFC3339),
+		URL:          strings.TrimSuffix(cfg.baseURL, "/") + "/api/chirps/" + chirp.ID.String(),
+		To:           activitypub.Audience{activitypub.Public},
+		Cc:           activitypub.Audience{actor + "/followers"},
+	}
+	if chirp.UpdatedAt.After(chirp.CreatedAt) {
+		note.Updated = chirp.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	if chirp.ParentID.Valid {
+		note.InReplyTo = cfg.noteURI(chirp.ParentID.UUID)
+	}
+	return note
+}
+
+// createActivity returns the Create activity that publishes a chirp.
+func (cfg *apiConfig) createActivity(chirp database.Chirp) (activitypub.Activity, error) {
+	note := cfg.chirpNote(chirp)
+	activity, err := activitypub.NewActivity(note.ID+"/activity", "Create", note.AttributedTo, note)
+	if err != nil {
+		return activitypub.Activity{}, err
+	}
+	activity.To = note.To
+	activity.Cc = note.Cc
+	activity.Published = note.Published
+	return activity, nil
+}
+
+func (cfg *apiConfig) handlerOutbox(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	_, ok, err := cfg.federatedHandle(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	chirps, err := cfg.database.ListChirpsByAuthor(r.Context(), database.ListChirpsByAuthorParams{
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build outbox", err)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build outbox", err)
+		return
+	}
+	chirps = slices.DeleteFunc(visibility.filter(chirps), func(chirp database.Chirp) bool {
+		return chirp.RechirpOfID.Valid
+	})
+	slices.Reverse(chirps)
+
+	items := make([]activitypub.Activity, 0, min(len(chirps), outboxSize))
+	for _, chirp := range chirps[:min(len(chirps), outboxSize)] {
+		activity, err := cfg.createActivity(chirp)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't build outbox", err)
+			return
+		}
+		activity.Context = nil
+		items = append(items, activity)
+	}
+
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:      activitypub.ActivityStreamsContext,
+		ID:           cfg.actorURI(userID) + "/outbox",
+		Type:         "OrderedCollection",
+		TotalItems:   len(chirps),
+		OrderedItems: items,
+	})
+}
+
+// handlerFollowersCollection publishes how many followers a user has, here
+// and on other servers, but not who they are.
+func (cfg *apiConfig) handlerFollowersCollection(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	_, ok, err := cfg.federatedHandle(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up user", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "User not found", nil)
+		return
+	}
+
+	counts, err := cfg.database.GetFollowCounts(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count followers", err)
+		return
+	}
+	remote, err := cfg.database.CountRemoteFollowers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't count followers", err)
+		return
+	}
+
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, activitypub.OrderedCollection{
+		Context:    activitypub.ActivityStreamsContext,
+		ID:         cfg.actorURI(userID) + "/followers",
+		Type:       "OrderedCollection",
+		TotalItems: int(counts.FollowerCount) + int(remote),
+	})
+}
+
+func (cfg *apiConfig) handlerNote(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	chirp, err := cfg.database.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && chirp.RechirpOfID.Valid {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+	_, federated, err := cfg.federatedHandle(r.Context(), chirp.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+	if !visibility.canSee(chirp) || !federated {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	note := cfg.chirpNote(chirp)
+	note.Context = activitypub.ActivityStreamsContext
+	respondWithActivityJSON(w, http.StatusOK, activitypub.ContentType, note)
+}
+
+// federateChirp sends a new chirp to the author's followers on other
+// servers. Rechirps aren't federated.
+func (cfg *apiConfig) federateChirp(ctx context.Context, chirp database.Chirp) {
+	if cfg.federation == nil || chirp.RechirpOfID.Valid {
+		return
+	}
+
+	activity, err := cfg.createActivity(chirp)
+	if err != nil {
+		log.Printf("Error federating chirp %s: %v", chirp.ID, err)
+		return
+	}
+	cfg.deliverToFollowers(ctx, chirp.UserID, activity)
+}
+
+// federateChirpDeletion tells the author's followers on other servers that a
+// chirp is gone. Restoring it later doesn't bring it back there.
+func (cfg *apiConfig) federateChirpDeletion(ctx context.Context, chirp database.Chirp) {
+	if cfg.federation == nil || chirp.RechirpOfID.Valid {
+		return
+	}
+
+	note := cfg.noteURI(chirp.ID)
+	activity, err := activitypub.NewActivity(note+"#delete", "Delete", cfg.actorURI(chirp.UserID), map[string]string{
+		"id":   note,
+		"type": "Tombstone",
+	})
+	if err != nil {
+		log.Printf("Error federating deletion of chirp %s: %v", chirp.ID, err)
+		return
+	}
+	activity.To = activitypub.Audience{activitypub.Public}
+	cfg.deliverToFollowers(ctx, chirp.UserID, activity)
+}
+
+// deliverToFollowers queues an activity for every server with followers of
+// the user.
+func (cfg *apiConfig) deliverToFollowers(ctx context.Context, userID uuid.UUID, activity activitypub.Activity) {
+	inboxes, err := cfg.database.ListRemoteFollowerInboxes(ctx, userID)
+	if err != nil {
+		log.Printf("Error listing inboxes for %s: %v", activity.ID, err)
+		return
+	}
+	if len(inboxes) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(activity)
+	if err != nil {
+		log.Printf("Error encoding %s: %v", activity.ID, err)
+		return
+	}
+
+	for _, inbox := range inboxes {
+		err := cfg.database.CreateFederationDelivery(ctx, database.CreateFederationDeliveryParams{
+			UserID:   userID,
+			Inbox:    inbox,
+			Activity: string(payload),
+		})
+		if err != nil {
+			log.Printf("Error queueing %s for %s: %v", activity.ID, inbox, err)
+		}
+	}
+}
+
+// runFederationDelivery sends queued activities that are due every
+// interval. Rows are claimed with SKIP LOCKED, so several servers can run it
+// at once without delivering anything twice.
+func (cfg *apiConfig) runFederationDelivery(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.deliverQueuedActivities(ctx); err != nil {
+			log.Printf("Error delivering activities: %v", err)
+		}
+	})
+}
+
+// deliverQueuedActivities delivers due activities a batch at a time until
+// none are left.
+func (cfg *apiConfig) deliverQueuedActivities(ctx context.Context) error {
+	for {
+		deliveries, err := cfg.database.ClaimFederationDeliveries(ctx, database.ClaimFederationDeliveriesParams{
+			LeaseUntil: time.Now().Add(deliveryLease),
+			BatchSize:  deliveryBatchSize,
+		})
+		if err != nil {
+			return err
+		}
+
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, deliveryConcurrency)
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				cfg.deliverActivity(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < deliveryBatchSize {
+			return nil
+		}
+	}
+}
+
+// sendDelivery sends one queued activity, signed by the user it is from.
+func (cfg *apiConfig) sendDelivery(ctx context.Context, delivery database.FederationDelivery) error {
+	key, err := cfg.actorKey(ctx, delivery.UserID)
+	if err != nil {
+		return err
+	}
+	private, err := activitypub.ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return err
+	}
+	keyID := cfg.actorURI(delivery.UserID) + "#main-key"
+	return cfg.federation.Deliver(ctx, delivery.Inbox, []byte(delivery.Activity), keyID, private)
+}
+
+// deliverActivity sends a queued activity and settles its row. Failures are
+// retried with exponential backoff, except when the inbox refused the
+// activity outright or it has run out of attempts.
+func (cfg *apiConfig) deliverActivity(ctx context.Context, delivery database.FederationDelivery) {
+	err := cfg.sendDelivery(ctx, delivery)
+	if err == nil {
+		if err := cfg.database.DeleteFederationDelivery(ctx, delivery.ID); err != nil {
+			log.Printf("Error removing delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	var statusErr *activitypub.StatusError
+	if errors.As(err, &statusErr) && statusErr.Permanent() || delivery.Attempts+1 >= maxDeliveryAttempts {
+		log.Printf("Giving up delivering to %s: %v", delivery.Inbox, err)
+		if err := cfg.database.DeleteFederationDelivery(ctx, delivery.ID); err != nil {
+			log.Printf("Error removing delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	backoff := min(time.Minute<<delivery.Attempts, maxDeliveryBackoff)
+	err = cfg.database.RetryFederationDelivery(ctx, database.RetryFederationDeliveryParams{
+		ID:            delivery.ID,
+		NextAttemptAt: time.Now().Add(backoff),
+		LastError:     sql.NullString{String: err.Error(), Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error rescheduling delivery %s: %v", delivery.ID, err)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/activitypub"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/text"
+)
+
+const (
+	// maxInboxBytes is the largest activity an inbox accepts.
+	maxInboxBytes = 1 << 20
+	// remoteActorTTL is how long a remote actor's key is trusted before it is
+	// fetched again.
+	remoteActorTTL = 24 * time.Hour
+)
+
+type remoteReplyResponse struct {
+	ID          string  `json:"id"`
+	Actor       string  `json:"actor"`
+	Username    *string `json:"username"`
+	Content     string  `json:"content"`
+	PublishedAt string  `json:"published_at"`
+}
+
+// handlerInbox receives activities from other servers, at each user's inbox
+// and at the shared inbox alike. Every request must be signed by the actor
+// it is from. Activities that don't concern anything here are accepted and
+// ignored, as other servers expect.
+func (cfg *apiConfig) handlerInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInboxBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read activity", err)
+		return
+	}
+	if len(body) > maxInboxBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Activity is too large", nil)
+		return
+	}
+
+	sig, err := activitypub.ParseSignature(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid signature", err)
+		return
+	}
+
+	actor, err := cfg.signingActor(r.Context(), r, body, sig)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid signature", err)
+		return
+	}
+
+	var activity activitypub.Activity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid activity", err)
+		return
+	}
+	if activity.Actor != actor.ID {
+		respondWithError(w, http.StatusForbidden, "Activity isn't from the signer", nil)
+		return
+	}
+
+	switch activity.Type {
+	case "Follow":
+		err = cfg.receiveFollow(r.Context(), actor, activity, body)
+	case "Undo":
+		err = cfg.receiveUndo(r.Context(), actor, activity)
+	case "Like":
+		err = cfg.receiveLike(r.Context(), actor, activity)
+	case "Create":
+		err = cfg.receiveCreate(r.Context(), actor, activity)
+	case "Delete":
+		err = cfg.receiveDelete(r.Context(), actor, activity)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't process activity", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// signingActor checks sig against the key it names and returns the remote
+// actor that owns the key. Cached actors are used while they are fresh; a
+// stale actor, or one whose cached key doesn't match in case the key was
+// rotated, is fetched again.
+func (cfg *apiConfig) signingActor(ctx context.Context, r *http.Request, body []byte, sig *activitypub.Signature) (database.RemoteActor, error) {
+	cached, err := cfg.database.GetRemoteActorByKeyID(ctx, sig.KeyID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.RemoteActor{}, err
+	}
+	if err == nil && time.Since(cached.FetchedAt) < remoteActorTTL {
+		if verifySignature(r, body, sig, cached) == nil {
+			return cached, nil
+		}
+	}
+
+	actor, err := cfg.fetchRemoteActor(ctx, sig.KeyID)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	if err := verifySignature(r, body, sig, actor); err != nil {
+		return database.RemoteActor{}, err
+	}
+	return actor, nil
+}
+
+func verifySignature(r *http.Request, body []byte, sig *activitypub.Signature, actor database.RemoteActor) error {
+	key, err := activitypub.ParsePublicKey(actor.PublicKeyPem)
+	if err != nil {
+		return err
+	}
+	return sig.Verify(r, body, key)
+}
+
+// fetchRemoteActor fetches the actor that owns keyID and caches it.
+func (cfg *apiConfig) fetchRemoteActor(ctx context.Context, keyID string) (database.RemoteActor, error) {
+	actor, err := cfg.federation.FetchActor(ctx, keyID)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	if actor.PublicKey.ID != keyID {
+		return database.RemoteActor{}, fmt.Errorf("key %s doesn't belong to %s", keyID, actor.ID)
+	}
+
+	var sharedInbox string
+	if actor.Endpoints != nil {
+		sharedInbox = actor.Endpoints.SharedInbox
+	}
+	params := database.UpsertRemoteActorParams{
+		ID:           actor.ID,
+		Username:     optionalString(actor.PreferredUsername),
+		Inbox:        actor.Inbox,
+		SharedInbox:  optionalString(sharedInbox),
+		KeyID:        actor.PublicKey.ID,
+		PublicKeyPem: actor.PublicKey.PublicKeyPem,
+	}
+	if err := cfg.database.UpsertRemoteActor(ctx, params); err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	return database.RemoteActor{
+		ID:           params.ID,
+		FetchedAt:    time.Now(),
+		Username:     params.Username,
+		Inbox:        params.Inbox,
+		SharedInbox:  params.SharedInbox,
+		KeyID:        params.KeyID,
+		PublicKeyPem: params.PublicKeyPem,
+	}, nil
+}
+
+// receiveFollow records a follow of a local user and queues the Accept,
+// which embeds the Follow as it was received.
+func (cfg *apiConfig) receiveFollow(ctx context.Context, actor database.RemoteActor, activity activitypub.Activity, body []byte) error {
+	userID, ok := cfg.localID(activity.ObjectID(), "/ap/users/")
+	if !ok || activity.ID == "" {
+		return nil
+	}
+	_, federated, err := cfg.federatedHandle(ctx, userID)
+	if err != nil || !federated {
+		return err
+	}
+
+	local := cfg.actorURI(userID)
+	accept, err := activitypub.NewActivity(local+"#accepts/"+uuid.NewString(), "Accept", local, json.RawMessage(body))
+	if err != nil {
+		return err
+	}
+	accept.To = activitypub.Audience{actor.ID}
+	payload, err := json.Marshal(accept)
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	err = qtx.CreateRemoteFollow(ctx, database.CreateRemoteFollowParams{
+		UserID:     userID,
+		ActorID:    actor.ID,
+		ActivityID: activity.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CreateFederationDelivery(ctx, database.CreateFederationDeliveryParams{
+		UserID:   userID,
+		Inbox:    actor.Inbox,
+		Activity: string(payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receiveUndo reverses an earlier Follow or Like, found by its activity ID.
+func (cfg *apiConfig) receiveUndo(ctx context.Context, actor database.RemoteActor, activity activitypub.Activity) error {
+	undone := activity.ObjectID()
+	if undone == "" {
+		return nil
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	_, err = qtx.DeleteRemoteFollow(ctx, database.DeleteRemoteFollowParams{
+		ActorID:    actor.ID,
+		ActivityID: undone,
+	})
+	if err != nil {
+		return err
+	}
+
+	chirpID, err := qtx.DeleteRemoteLike(ctx, database.DeleteRemoteLikeParams{
+		ActorID:    actor.ID,
+		ActivityID: undone,
+	})
+	if err == nil {
+		_, err = qtx.DecrementLikeCount(ctx, chirpID)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receiveLike records a like of a local chirp. Remote likes count towards
+// like_count.
+func (cfg *apiConfig) receiveLike(ctx context.Context, actor database.RemoteActor, activity activitypub.Activity) error {
+	chirpID, ok := cfg.localID(activity.ObjectID(), "/ap/chirps/")
+	if !ok || activity.ID == "" {
+		return nil
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	chirp, err := qtx.GetChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && chirp.DeletedAt.Valid {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	inserted, err := qtx.CreateRemoteLike(ctx, database.CreateRemoteLikeParams{
+		ChirpID:    chirp.ID,
+		ActorID:    actor.ID,
+		ActivityID: activity.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if inserted > 0 {
+		if _, err := qtx.IncrementLikeCount(ctx, chirp.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// receiveCreate stores a note that replies to a local chirp. Other notes
+// aren't kept. The note's HTML is reduced to plain text and goes through the
+// content filter like a chirp.
+func (cfg *apiConfig) receiveCreate(ctx context.Context, actor database.RemoteActor, activity activitypub.Activity) error {
+	if activity.ObjectType() != "Note" {
+		return nil
+	}
+	var note activitypub.Note
+	if err := json.Unmarshal(activity.Object, &note); err != nil {
+		return nil
+	}
+	// A server can only publish notes by its own actors, under its own URIs.
+	if note.AttributedTo != actor.ID || !sameHost(note.ID, actor.ID) {
+		return nil
+	}
+
+	chirpID, ok := cfg.localID(note.InReplyTo, "/ap/chirps/")
+	if !ok {
+		return nil
+	}
+	chirp, err := cfg.database.GetChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && chirp.DeletedAt.Valid {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	content := text.Normalize(activitypub.PlainText(note.Content))
+	if len(content) > maxChirpBytes {
+		content = strings.ToValidUTF8(content[:maxChirpBytes], "")
+	}
+	filtered := cfg.contentFilter.Load().Apply(content)
+	if filtered.Rejected || filtered.Text == "" {
+		return nil
+	}
+
+	published, err := time.Parse(time.RFC3339, note.Published)
+	if err != nil {
+		published = time.Now()
+	}
+
+	return cfg.database.CreateRemoteReply(ctx, database.CreateRemoteReplyParams{
+		ID:          note.ID,
+		ChirpID:     chirp.ID,
+		ActorID:     actor.ID,
+		Content:     filtered.Text,
+		PublishedAt: published,
+	})
+}
+
+// receiveDelete removes a stored reply when its author deletes it.
+func (cfg *apiConfig) receiveDelete(ctx context.Context, actor database.RemoteActor, activity activitypub.Activity) error {
+	_, err := cfg.database.DeleteRemoteReply(ctx, database.DeleteRemoteReplyParams{
+		ID:      activity.ObjectID(),
+		ActorID: actor.ID,
+	})
+	return err
+}
+
+func sameHost(a, b string) bool {
+	first, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	second, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return first.Host != "" && strings.EqualFold(first.Host, second.Host)
+}
+
+func (cfg *apiConfig) handlerChirpsRemoteReplies(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	chirp, err := cfg.database.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list replies", err)
+		return
+	}
+	if !visibility.canSee(chirp) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	replies, err := cfg.database.ListRemoteReplies(r.Context(), database.ListRemoteRepliesParams{
+		ChirpID: chirp.ID,
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list replies", err)
+		return
+	}
+
+	response := make([]remoteReplyResponse, len(replies))
+	for i, reply := range replies {
+		response[i] = remoteReplyResponse{
+			ID:          reply.ID,
+			Actor:       reply.ActorID,
+			Username:    nullStringPtr(reply.Username),
+			Content:     reply.Content,
+			PublishedAt: reply.PublishedAt.String(),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/entities"
+	"github.com/mjayio/server/internal/feed"
+	"github.com/mjayio/server/internal/httpcache"
+)
+
+const (
+	// maxFeedEntries is how many of the newest chirps a feed lists.
+	maxFeedEntries = 50
+	// feedMaxAge is how long a feed can be reused without checking back.
+	// Feed readers poll, so this mostly spares the database.
+	feedMaxAge = 5 * time.Minute
+)
+
+type feedFormat struct {
+	contentType string
+	render      func(feed.Feed) ([]byte, error)
+}
+
+var (
+	atomFeed = feedFormat{contentType: "application/atom+xml; charset=utf-8", render: feed.Atom}
+	rssFeed  = feedFormat{contentType: "application/rss+xml; charset=utf-8", render: feed.RSS}
+)
+
+// absoluteURL turns a path into a URL, using BASE_URL when it is set and the
+// request's host otherwise. Feed readers need absolute links.
+func (cfg *apiConfig) absoluteURL(r *http.Request, path string) string {
+	base := cfg.baseURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// authorName is how a user is credited in feeds. Email addresses are never
+// published, so users without a handle are credited by ID.
+func authorName(id uuid.UUID, handle string) string {
+	if handle == "" {
+		return "user " + id.String()
+	}
+	return "@" + handle
+}
+
+// feedEntries turns chirps into feed entries, crediting them to the names in
+// authors. Rechirps are left out because they have no text of their own.
+// Along with the entries it returns when the newest of them was last edited
+// and a version that changes whenever an entry is added, removed or edited.
+func (cfg *apiConfig) feedEntries(r *http.Request, chirps []database.Chirp, authors map[uuid.UUID]string) ([]feed.Entry, time.Time, string) {
+	var entries []feed.Entry
+	var updated time.Time
+	var version strings.Builder
+	for _, chirp := range chirps {
+		if chirp.RechirpOfID.Valid {
+			continue
+		}
+		entries = append(entries, feed.Entry{
+			ID:        "urn:uuid:" + chirp.ID.String(),
+			Link:      cfg.absoluteURL(r, "/api/chirps/"+chirp.ID.String()),
+			Author:    authors[chirp.UserID],
+			Content:   chirp.Body,
+			Published: chirp.CreatedAt,
+			Updated:   chirp.UpdatedAt,
+		})
+		if chirp.UpdatedAt.After(updated) {
+			updated = chirp.UpdatedAt
+		}
+		version.WriteString(chirp.ID.String() + ",")
+	}
+	version.WriteString(updated.UTC().Format(time.RFC3339Nano))
+	return entries, updated, httpcache.ETag([]byte(version.String()))
+}
+
+// feedAuthors looks up the names to credit the authors of chirps with.
+func (cfg *apiConfig) feedAuthors(r *http.Request, chirps []database.Chirp) (map[uuid.UUID]string, error) {
+	var ids []uuid.UUID
+	for _, chirp := range chirps {
+		if !slices.Contains(ids, chirp.UserID) {
+			ids = append(ids, chirp.UserID)
+		}
+	}
+
+	users, err := cfg.database.ListUsersByIDs(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+	authors := make(map[uuid.UUID]string, len(users))
+	for _, user := range users {
+		authors[user.ID] = authorName(user.ID, user.Handle.String)
+	}
+	return authors, nil
+}
+
+// respondWithFeed renders f and sends it with the validators for conditional
+// GETs, using version from feedEntries as the ETag. Feeds are the same for
+// everyone, so any cache may keep them.
+func respondWithFeed(w http.ResponseWriter, r *http.Request, format feedFormat, f feed.Feed, version string) {
+	body, err := format.render(f)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't render feed", err)
+		return
+	}
+	respondWithCacheable(w, r, http.StatusOK, format.contentType, body, version, f.Updated, httpcache.Public(feedMaxAge))
+}
+
+func (cfg *apiConfig) handlerUserFeedAtom(w http.ResponseWriter, r *http.Request) {
+	cfg.serveUserFeed(w, r, atomFeed)
+}
+
+func (cfg *apiConfig) handlerUserFeedRSS(w http.ResponseWriter, r *http.Request) {
+	cfg.serveUserFeed(w, r, rssFeed)
+}
+
+// serveUserFeed sends the newest chirps of one user, as anyone signed out
+// would see them.
+func (cfg *apiConfig) serveUserFeed(w http.ResponseWriter, r *http.Request, format feedFormat) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := cfg.database.GetUser(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build feed", err)
+		return
+	}
+	name := authorName(userID, user.Handle.String)
+
+	chirps, err := cfg.database.ListLatestChirpsByAuthor(r.Context(), database.ListLatestChirpsByAuthorParams{
+		UserID:   userID,
+		PageSize: maxFeedEntries,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build feed", err)
+		return
+	}
+
+	entries, updated, version := cfg.feedEntries(r, chirps, map[uuid.UUID]string{userID: name})
+	// A user who hasn't chirped yet has had the same empty feed since
+	// signing up.
+	if updated.IsZero() {
+		updated = user.CreatedAt
+	}
+	respondWithFeed(w, r, format, feed.Feed{
+		ID:          "urn:uuid:" + userID.String(),
+		Title:       "Chirps by " + name,
+		Description: "The latest chirps by " + name,
+		Link:        cfg.absoluteURL(r, "/api/chirps?author_id="+userID.String()+"&sort=desc"),
+		SelfLink:    cfg.absoluteURL(r, r.URL.Path),
+		Updated:     updated,
+		Entries:     entries,
+	}, version)
+}
+
+func (cfg *apiConfig) handlerHashtagFeedAtom(w http.ResponseWriter, r *http.Request) {
+	cfg.serveHashtagFeed(w, r, atomFeed)
+}
+
+func (cfg *apiConfig) handlerHashtagFeedRSS(w http.ResponseWriter, r *http.Request) {
+	cfg.serveHashtagFeed(w, r, rssFeed)
+}
+
+// serveHashtagFeed sends the newest chirps with a hashtag, as anyone signed
+// out would see them.
+func (cfg *apiConfig) serveHashtagFeed(w http.ResponseWriter, r *http.Request, format feedFormat) {
+	tag := entities.NormalizeHashtag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid hashtag", nil)
+		return
+	}
+
+	chirps, err := cfg.database.ListChirpsByHashtag(r.Context(), database.ListChirpsByHashtagParams{
+		Tag:      tag,
+		PageSize: maxFeedEntries,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build feed", err)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), uuid.NullUUID{})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build feed", err)
+		return
+	}
+	chirps = visibility.filter(chirps)
+
+	authors, err := cfg.feedAuthors(r, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't build feed", err)
+		return
+	}
+
+	entries, updated, version := cfg.feedEntries(r, chirps, authors)
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	respondWithFeed(w, r, format, feed.Feed{
+		ID:          cfg.absoluteURL(r, "/api/hashtags/"+tag+"/chirps"),
+		Title:       "#" + tag,
+		Description: "The latest chirps tagged #" + tag,
+		Link:        cfg.absoluteURL(r, "/api/hashtags/"+tag+"/chirps"),
+		SelfLink:    cfg.absoluteURL(r, r.URL.Path),
+		Updated:     updated,
+		Entries:     entries,
+	}, version)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+type followResponse struct {
+	UserID        string `json:"user_id"`
+	Following     bool   `json:"following"`
+	FollowerCount int32  `json:"follower_count"`
+}
+
+type followListResponse struct {
+	Count      int32              `json:"count"`
+	Users      []followedUserInfo `json:"users"`
+	NextOffset *int32             `json:"next_offset"`
+}
+
+type followedUserInfo struct {
+	ID         string `json:"id"`
+	FollowedAt string `json:"followed_at"`
+}
+
+func (cfg *apiConfig) handlerUserFollow(w http.ResponseWriter, r *http.Request) {
+	cfg.setFollow(w, r, true)
+}
+
+func (cfg *apiConfig) handlerUserUnfollow(w http.ResponseWriter, r *http.Request) {
+	cfg.setFollow(w, r, false)
+}
+
+// setFollow makes the caller follow or unfollow the user in the path. Like
+// liking, both directions are idempotent.
+func (cfg *apiConfig) setFollow(w http.ResponseWriter, r *http.Request, follow bool) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update follow", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	// The follower counts only move when a follows row was actually inserted
+	// or deleted, in the same transaction as that change.
+	if follow {
+		inserted, err := qtx.CreateFollow(r.Context(), database.CreateFollowParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+			return
+		}
+		if inserted > 0 {
+			err = qtx.IncrementFollowCounts(r.Context(), database.IncrementFollowCountsParams{
+				FolloweeID: followeeID,
+				FollowerID: userID,
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't follow user", err)
+				return
+			}
+		}
+	} else {
+		deleted, err := qtx.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+			return
+		}
+		if deleted > 0 {
+			err = qtx.DecrementFollowCounts(r.Context(), database.DecrementFollowCountsParams{
+				FolloweeID: followeeID,
+				FollowerID: userID,
+			})
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow user", err)
+				return
+			}
+		}
+	}
+
+	counts, err := qtx.GetFollowCounts(r.Context(), followeeID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update follow", err)
+		return
+	}
+
+	if follow {
+		cfg.clearTimelinesAfterFollow(r.Context(), userID, followeeID, counts.FollowerCount)
+	} else {
+		err = cfg.timelines.RemoveAuthor(r.Context(), userID, followeeID)
+		if err != nil {
+			log.Printf("Error removing %s from timeline of %s: %v", followeeID, userID, err)
+		}
+		cfg.clearTimelinesAfterUnfollow(r.Context(), followeeID, counts.FollowerCount)
+	}
+
+	respondWithJSON(w, http.StatusOK, followResponse{
+		UserID:        followeeID.String(),
+		Following:     follow,
+		FollowerCount: counts.FollowerCount,
+	})
+}
+
+// clearTimelinesAfterFollow empties the follower's cached timeline, which
+// holds none of the followee's earlier chirps, so it is rebuilt from the
+// chirps table until it fills up again. Chirps by authors over the fan-out
+// threshold are merged in on every read, so following them needs nothing.
+func (cfg *apiConfig) clearTimelinesAfterFollow(ctx context.Context, followerID, followeeID uuid.UUID, followerCount int32) {
+	if followerCount > cfg.timelineFanoutThreshold {
+		return
+	}
+	err := cfg.timelines.Clear(ctx, []uuid.UUID{followerID})
+	if err != nil {
+		log.Printf("Error clearing timeline of %s after following %s: %v", followerID, followeeID, err)
+	}
+}
+
+// clearTimelinesAfterUnfollow empties the cached timelines of every follower
+// when an unfollow brings an author back down to the fan-out threshold. Their
+// chirps from while they were over it were never fanned out, and are no
+// longer merged in on read.
+func (cfg *apiConfig) clearTimelinesAfterUnfollow(ctx context.Context, followeeID uuid.UUID, followerCount int32) {
+	if followerCount != cfg.timelineFanoutThreshold {
+		return
+	}
+	followers, err := cfg.database.ListFollowerIDs(ctx, followeeID)
+	if err == nil {
+		err = cfg.timelines.Clear(ctx, followers)
+	}
+	if err != nil {
+		log.Printf("Error clearing timelines of followers of %s: %v", followeeID, err)
+	}
+}
+
+func (cfg *apiConfig) handlerUserFollowers(w http.ResponseWriter, r *http.Request) {
+	parseUserID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	counts, err := cfg.database.GetFollowCounts(r.Context(), parseUserID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	count := counts.FollowerCount
+
+	followers, err := cfg.database.ListFollowers(r.Context(), database.ListFollowersParams{
+		FolloweeID: parseUserID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list followers", err)
+		return
+	}
+
+	users := make([]followedUserInfo, len(followers))
+	for i, follower := range followers {
+		users[i] = followedUserInfo{
+			ID:         follower.FollowerID.String(),
+			FollowedAt: follower.CreatedAt.String(),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, followListResponse{
+		Count:      count,
+		Users:      users,
+		NextOffset: nextOffset(count, limit, offset),
+	})
+}
+
+func (cfg *apiConfig) handlerUserFollowing(w http.ResponseWriter, r *http.Request) {
+	parseUserID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	counts, err := cfg.database.GetFollowCounts(r.Context(), parseUserID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found", err)
+		return
+	}
+	count := counts.FollowingCount
+
+	following, err := cfg.database.ListFollowing(r.Context(), database.ListFollowingParams{
+		FollowerID: parseUserID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list followed users", err)
+		return
+	}
+
+	users := make([]followedUserInfo, len(following))
+	for i, followee := range following {
+		users[i] = followedUserInfo{
+			ID:         followee.FolloweeID.String(),
+			FollowedAt: followee.CreatedAt.String(),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, followListResponse{
+		Count:      count,
+		Users:      users,
+		NextOffset: nextOffset(count, limit, offset),
+	})
+}
+
+func nextOffset(total, limit, offset int32) *int32 {
+	if int64(offset)+int64(limit) >= int64(total) {
+		return nil
+	}
+	next := offset + limit
+	return &next
+}
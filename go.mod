@@ -3,10 +3,15 @@ module github.com/mjayio/server
 go 1.23.5
 
 require (
-	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/pressly/goose/v3 v3.24.1 // indirect
-	golang.org/x/crypto v0.35.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.2.1
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	github.com/rivo/uniseg v0.4.7
+	golang.org/x/crypto v0.35.0
+	golang.org/x/net v0.35.0
+	golang.org/x/text v0.22.0
 )
+
+require github.com/pressly/goose/v3 v3.24.1 // indirect
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/httpcache"
+)
+
+// How long anonymous responses may be reused by any cache without checking
+// back. Signed-in responses are always revalidated.
+const (
+	chirpMaxAge     = 30 * time.Second
+	chirpListMaxAge = 5 * time.Second
+)
+
+// cachePolicy picks the Cache-Control policy for a response. What a signed-in
+// user sees depends on who they are, through liked_by_me, poll votes and
+// hidden chirps, so only anonymous responses can be shared.
+func cachePolicy(viewerID uuid.NullUUID, maxAge time.Duration) string {
+	if viewerID.Valid {
+		return httpcache.Revalidate
+	}
+	return httpcache.Public(maxAge)
+}
+
+// respondWithCacheableJSON responds like respondWithJSON, but supports
+// conditional requests through respondWithCacheable. The ETag hashes the
+// body, and also names version when it is set; see chirpVersion.
+func respondWithCacheableJSON(w http.ResponseWriter, r *http.Request, code int, payload interface{}, version string, lastModified time.Time, cacheControl string) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	etag := ""
+	if version != "" {
+		etag = httpcache.VersionedETag(version, dat)
+	}
+	respondWithCacheable(w, r, code, "application/json", dat, etag, lastModified, cacheControl)
+}
+
+// respondWithCacheable tags body with a strong ETag, and Last-Modified when
+// it is set, and answers a GET whose validators still match with 304 Not
+// Modified instead of the body. An empty etag is derived from the body.
+func respondWithCacheable(w http.ResponseWriter, r *http.Request, code int, contentType string, body []byte, etag string, lastModified time.Time, cacheControl string) {
+	if etag == "" {
+		etag = httpcache.ETag(body)
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Add("Vary", "Authorization")
+	httpcache.SetValidators(w, etag, lastModified)
+
+	if httpcache.NotModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+// chirpVersion returns the version of a chirp: a hash of its ID and when it
+// was last edited. Chirp ETags carry it next to the body hash, so a cached
+// copy goes stale when counters or the viewer's state change, but only an
+// edit fails an If-Match.
+func chirpVersion(chirp database.Chirp) string {
+	sum := sha256.Sum256([]byte(chirp.ID.String() + "@" + chirp.UpdatedAt.UTC().Format(time.RFC3339Nano)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkChirpPrecondition enforces If-Match on a change to chirp, so a client
+// only changes the version it last saw. On failure it writes the error
+// response and returns false.
+func checkChirpPrecondition(w http.ResponseWriter, r *http.Request, chirp database.Chirp) bool {
+	if !httpcache.MatchesVersion(r, chirpVersion(chirp)) {
+		respondWithError(w, http.StatusPreconditionFailed, "Chirp has changed", nil)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+// Package activitypub implements the parts of ActivityPub that let users be
+// followed from other servers in the fediverse: the JSON-LD vocabulary,
+// WebFinger, RSA keys, HTTP Signatures and a client for fetching actors and
+// delivering activities.
+//
+// Only the compact JSON form used by Mastodon and compatible servers is
+// understood; documents are never expanded as JSON-LD.
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// ContentType is the media type of ActivityPub documents.
+	ContentType = "application/activity+json"
+	// Accept is the Accept header for fetching ActivityPub documents, which
+	// servers also publish under the JSON-LD media type.
+	Accept = `application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`
+
+	// ActivityStreamsContext is the JSON-LD context of every document.
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	// SecurityContext defines the publicKey property of actors.
+	SecurityContext = "https://w3id.org/security/v1"
+	// Public is the collection that addresses an object to everyone.
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+)
+
+// Actor is a user, as other servers see them.
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	URL               string     `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+}
+
+// Endpoints lists an actor's server-wide endpoints.
+type Endpoints struct {
+	// SharedInbox accepts activities for every actor on the server, so one
+	// delivery reaches all of its followers there.
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// PublicKey is the key an actor's requests are signed with.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// DeliveryInbox returns where to deliver activities for the actor, preferring
+// the shared inbox.
+func (a Actor) DeliveryInbox() string {
+	if a.Endpoints != nil && a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+// Activity is something an actor did, such as Create, Follow or Undo. Object
+// is kept raw because it may be a URI or an embedded object.
+type Activity struct {
+	Context   any             `json:"@context,omitempty"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Actor     string          `json:"actor"`
+	Object    json.RawMessage `json:"object"`
+	To        Audience        `json:"to,omitempty"`
+	Cc        Audience        `json:"cc,omitempty"`
+	Published string          `json:"published,omitempty"`
+}
+
+// NewActivity returns an activity whose object is object, which may be a URI
+// string, an embedded document or an activity received earlier.
+func NewActivity(id, activityType, actor string, object any) (Activity, error) {
+	raw, ok := object.(json.RawMessage)
+	if !ok {
+		var err error
+		raw, err = json.Marshal(object)
+		if err != nil {
+			return Activity{}, err
+		}
+	}
+	return Activity{
+		Context: ActivityStreamsContext,
+		ID:      id,
+		Type:    activityType,
+		Actor:   actor,
+		Object:  raw,
+	}, nil
+}
+
+// ObjectID returns the ID of the activity's object, whether it was given as a
+// URI or embedded. It is empty when there is no object.
+func (a Activity) ObjectID() string {
+	var id string
+	if json.Unmarshal(a.Object, &id) == nil {
+		return id
+	}
+	var object struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(a.Object, &object) == nil {
+		return object.ID
+	}
+	return ""
+}
+
+// ObjectType returns the type of an embedded object, or "" when the object
+// is only a URI.
+func (a Activity) ObjectType() string {
+	var object struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(a.Object, &object) == nil {
+		return object.Type
+	}
+	return ""
+}
+
+// Note is a post. Chirps are published as notes.
+type Note struct {
+	Context      any      `json:"@context,omitempty"`
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	AttributedTo string   `json:"attributedTo"`
+	InReplyTo    string   `json:"inReplyTo,omitempty"`
+	Content      string   `json:"content"`
+	Published    string   `json:"published,omitempty"`
+	Updated      string   `json:"updated,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	To           Audience `json:"to,omitempty"`
+	Cc           Audience `json:"cc,omitempty"`
+}
+
+// OrderedCollection is a list such as an outbox or followers collection.
+type OrderedCollection struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	TotalItems   int        `json:"totalItems"`
+	OrderedItems []Activity `json:"orderedItems,omitempty"`
+}
+
+// Audience is a to or cc list. Servers send a single recipient as a plain
+// string, so both forms are accepted.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("activitypub: audience must be a string or a list of strings")
+	}
+	*a = list
+	return nil
+}
+
+// PlainText converts a note's HTML content to plain text. Line and paragraph
+// breaks become newlines, scripts and styles are dropped along with all
+// markup, so the result is safe to show anywhere.
+func PlainText(content string) string {
+	var b strings.Builder
+	skipping := false
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return strings.TrimSpace(b.String())
+		case html.TextToken:
+			if !skipping {
+				b.Write(tokenizer.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch name, _ := tokenizer.TagName(); string(name) {
+			case "br":
+				b.WriteString("\n")
+			case "script", "style":
+				skipping = true
+			}
+		case html.EndTagToken:
+			switch name, _ := tokenizer.TagName(); string(name) {
+			case "p":
+				b.WriteString("\n\n")
+			case "script", "style":
+				skipping = false
+			}
+		}
+	}
+}
+
+// HTMLContent converts plain text to note content: escaped, with newlines as
+// line breaks, in a single paragraph.
+func HTMLContent(text string) string {
+	escaped := html.EscapeString(text)
+	return "<p>" + strings.ReplaceAll(escaped, "\n", "<br>") + "</p>"
+}
@@ -0,0 +1,324 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testKey is shared by the tests because generating RSA keys is slow.
+var testKey = func() *rsa.PrivateKey {
+	key, err := GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// newTestClient returns a client that may connect to the loopback interface,
+// where the stand-in instances listen.
+func newTestClient() *Client {
+	c := NewClient(time.Second, "test")
+	c.allow = func(addr netip.Addr) bool { return addr.IsLoopback() }
+	return c
+}
+
+// instance is a stand-in for another fediverse server with one actor. Its
+// inbox verifies signatures the way a real server would, fetching the
+// sender's actor for the key, and records what it accepts.
+type instance struct {
+	server *httptest.Server
+	actor  Actor
+	key    *rsa.PrivateKey
+
+	mu       sync.Mutex
+	received []Activity
+}
+
+func newInstance(t *testing.T) *instance {
+	t.Helper()
+	in := &instance{key: testKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(in.actor)
+	})
+	mux.HandleFunc("POST /inbox", in.handleInbox)
+	in.server = httptest.NewServer(mux)
+	t.Cleanup(in.server.Close)
+
+	publicKey, err := EncodePublicKey(&in.key.PublicKey)
+	if err != nil {
+		t.Fatalf("EncodePublicKey: %v", err)
+	}
+	id := in.server.URL + "/users/alice"
+	in.actor = Actor{
+		Context:           []string{ActivityStreamsContext, SecurityContext},
+		ID:                id,
+		Type:              "Person",
+		PreferredUsername: "alice",
+		Inbox:             id + "/inbox",
+		Endpoints:         &Endpoints{SharedInbox: in.server.URL + "/inbox"},
+		PublicKey:         PublicKey{ID: id + "#main-key", Owner: id, PublicKeyPem: publicKey},
+	}
+	return in
+}
+
+func (in *instance) handleInbox(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	sig, err := ParseSignature(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	sender, err := newTestClient().FetchActor(r.Context(), sig.KeyID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	key, err := ParsePublicKey(sender.PublicKey.PublicKeyPem)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := sig.Verify(r, body, key); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var activity Activity
+	if err := json.Unmarshal(body, &activity); err != nil || activity.Actor != sender.ID {
+		http.Error(w, "bad activity", http.StatusBadRequest)
+		return
+	}
+	in.mu.Lock()
+	in.received = append(in.received, activity)
+	in.mu.Unlock()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestDeliverBetweenInstances(t *testing.T) {
+	sender := newInstance(t)
+	recipient := newInstance(t)
+
+	activity, err := NewActivity(sender.actor.ID+"#follow", "Follow", sender.actor.ID, recipient.actor.ID)
+	if err != nil {
+		t.Fatalf("NewActivity: %v", err)
+	}
+	body, _ := json.Marshal(activity)
+
+	err = newTestClient().Deliver(context.Background(), recipient.actor.DeliveryInbox(), body, sender.actor.PublicKey.ID, sender.key)
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if len(recipient.received) != 1 {
+		t.Fatalf("recipient received %d activities, want 1", len(recipient.received))
+	}
+	got := recipient.received[0]
+	if got.Type != "Follow" || got.ObjectID() != recipient.actor.ID {
+		t.Errorf("received %s of %q", got.Type, got.ObjectID())
+	}
+}
+
+func TestDeliverRejectedForWrongKey(t *testing.T) {
+	sender := newInstance(t)
+	recipient := newInstance(t)
+
+	otherKey, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	activity, _ := NewActivity(sender.actor.ID+"#like", "Like", sender.actor.ID, "https://example.com/notes/1")
+	body, _ := json.Marshal(activity)
+
+	err = newTestClient().Deliver(context.Background(), recipient.actor.DeliveryInbox(), body, sender.actor.PublicKey.ID, otherKey)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Deliver with the wrong key = %v, want 401", err)
+	}
+	if !statusErr.Permanent() {
+		t.Error("401 should be permanent")
+	}
+}
+
+func TestFetchActor(t *testing.T) {
+	in := newInstance(t)
+
+	actor, err := newTestClient().FetchActor(context.Background(), in.actor.PublicKey.ID)
+	if err != nil {
+		t.Fatalf("FetchActor: %v", err)
+	}
+	if actor.ID != in.actor.ID || actor.DeliveryInbox() != in.server.URL+"/inbox" {
+		t.Errorf("FetchActor = %+v", actor)
+	}
+
+	in.actor.ID = "https://elsewhere.example/users/alice"
+	_, err = newTestClient().FetchActor(context.Background(), in.server.URL+"/users/alice")
+	if !errors.Is(err, ErrActorMismatch) {
+		t.Errorf("FetchActor of an actor claiming another ID = %v, want ErrActorMismatch", err)
+	}
+}
+
+func TestFetchActorRefusesPrivateAddresses(t *testing.T) {
+	in := newInstance(t)
+
+	_, err := NewClient(time.Second, "test").FetchActor(context.Background(), in.actor.ID)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("FetchActor of a loopback server = %v, want ErrBlockedAddress", err)
+	}
+
+	_, err = newTestClient().FetchActor(context.Background(), "file:///etc/passwd")
+	if !errors.Is(err, ErrUnsupportedURL) {
+		t.Errorf("FetchActor of a file URL = %v, want ErrUnsupportedURL", err)
+	}
+}
+
+func signedRequest(t *testing.T, body []byte) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "https://chirpy.example/ap/inbox", bytes.NewReader(body))
+	if err := Sign(r, "https://remote.example/users/bob#main-key", testKey, body); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return r
+}
+
+func TestSignatureVerify(t *testing.T) {
+	body := []byte(`{"type":"Follow"}`)
+
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request) []byte
+		ok     bool
+	}{
+		{"untouched", func(r *http.Request) []byte { return body }, true},
+		{"changed body", func(r *http.Request) []byte { return []byte(`{"type":"Undo"}`) }, false},
+		{"changed path", func(r *http.Request) []byte {
+			r.URL.Path = "/ap/users/someone/inbox"
+			return body
+		}, false},
+		{"changed host", func(r *http.Request) []byte {
+			r.Host = "other.example"
+			return body
+		}, false},
+		{"old date", func(r *http.Request) []byte {
+			r.Header.Set("Date", time.Now().Add(-2*MaxClockSkew).UTC().Format(http.TimeFormat))
+			return body
+		}, false},
+		{"digest not signed", func(r *http.Request) []byte {
+			header := r.Header.Get("Signature")
+			r.Header.Set("Signature", strings.Replace(header, " digest", "", 1))
+			return body
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(t, body)
+			received := tt.tamper(r)
+
+			sig, err := ParseSignature(r)
+			if err != nil {
+				t.Fatalf("ParseSignature: %v", err)
+			}
+			if sig.KeyID != "https://remote.example/users/bob#main-key" {
+				t.Errorf("KeyID = %q", sig.KeyID)
+			}
+			err = sig.Verify(r, received, &testKey.PublicKey)
+			if tt.ok && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestParseSignatureMissing(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/ap/inbox", nil)
+	if _, err := ParseSignature(r); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("ParseSignature = %v, want ErrNoSignature", err)
+	}
+}
+
+func TestKeysRoundTrip(t *testing.T) {
+	private, err := EncodePrivateKey(testKey)
+	if err != nil {
+		t.Fatalf("EncodePrivateKey: %v", err)
+	}
+	parsed, err := ParsePrivateKey(private)
+	if err != nil || !parsed.Equal(testKey) {
+		t.Fatalf("ParsePrivateKey = %v", err)
+	}
+
+	public, err := EncodePublicKey(&testKey.PublicKey)
+	if err != nil {
+		t.Fatalf("EncodePublicKey: %v", err)
+	}
+	parsedPublic, err := ParsePublicKey(public)
+	if err != nil || !parsedPublic.Equal(&testKey.PublicKey) {
+		t.Fatalf("ParsePublicKey = %v", err)
+	}
+
+	if _, err := ParsePublicKey("not a key"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("ParsePublicKey of garbage = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestActivityObject(t *testing.T) {
+	var byURI, embedded Activity
+	json.Unmarshal([]byte(`{"type":"Like","object":"https://chirpy.example/ap/chirps/1"}`), &byURI)
+	json.Unmarshal([]byte(`{"type":"Undo","object":{"id":"https://remote.example/likes/1","type":"Like"},"to":"https://www.w3.org/ns/activitystreams#Public"}`), &embedded)
+
+	if byURI.ObjectID() != "https://chirpy.example/ap/chirps/1" || byURI.ObjectType() != "" {
+		t.Errorf("object given by URI = %q, %q", byURI.ObjectID(), byURI.ObjectType())
+	}
+	if embedded.ObjectID() != "https://remote.example/likes/1" || embedded.ObjectType() != "Like" {
+		t.Errorf("embedded object = %q, %q", embedded.ObjectID(), embedded.ObjectType())
+	}
+	if len(embedded.To) != 1 || embedded.To[0] != Public {
+		t.Errorf("single recipient decoded as %v", embedded.To)
+	}
+}
+
+func TestParseAcct(t *testing.T) {
+	tests := []struct {
+		resource, user, domain string
+		ok                     bool
+	}{
+		{"acct:alice@Chirpy.Example", "alice", "chirpy.example", true},
+		{"@alice@chirpy.example", "alice", "chirpy.example", true},
+		{"alice@chirpy.example", "alice", "chirpy.example", true},
+		{"acct:alice", "", "", false},
+		{"acct:@chirpy.example", "", "", false},
+		{"acct:alice@a@b", "", "", false},
+	}
+	for _, tt := range tests {
+		user, domain, err := ParseAcct(tt.resource)
+		if tt.ok != (err == nil) || user != tt.user || domain != tt.domain {
+			t.Errorf("ParseAcct(%q) = %q, %q, %v", tt.resource, user, domain, err)
+		}
+	}
+}
+
+func TestContentConversion(t *testing.T) {
+	text := PlainText(`<p>Hello <a href="https://x.example"><span>@bob</span></a><br>line two</p><p>para &amp; two<script>x</script></p>`)
+	if want := "Hello @bob\nline two\n\npara & two"; text != want {
+		t.Errorf("PlainText = %q, want %q", text, want)
+	}
+
+	if got := HTMLContent("a <b>\nc"); got != "<p>a &lt;b&gt;<br>c</p>" {
+		t.Errorf("HTMLContent = %q", got)
+	}
+}
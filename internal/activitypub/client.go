@@ -0,0 +1,174 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjayio/server/internal/unfurl"
+)
+
+const (
+	// maxDocumentBytes is the most of a fetched document that is read.
+	maxDocumentBytes = 1 << 20
+	// maxRedirects is how many redirects a fetch follows.
+	maxRedirects = 3
+)
+
+var (
+	// ErrBlockedAddress is returned when a URI resolves to an address that
+	// isn't on the public internet. The check is the one link previews use.
+	ErrBlockedAddress = unfurl.ErrBlockedAddress
+	// ErrUnsupportedURL is returned for URIs that aren't http or https.
+	ErrUnsupportedURL = errors.New("activitypub: unsupported URL")
+	// ErrActorMismatch is returned when a fetched actor's ID isn't the URI
+	// it was fetched from, which would let one server speak for another.
+	ErrActorMismatch = errors.New("activitypub: actor ID doesn't match its URI")
+)
+
+// StatusError is returned when a server answers with an unsuccessful status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("activitypub: %s returned %d", e.URL, e.StatusCode)
+}
+
+// Permanent reports whether retrying the request is pointless: the server
+// refused it outright, rather than being overloaded or unavailable.
+func (e *StatusError) Permanent() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500 &&
+		e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
+
+// Client talks to other servers. Like link previews, the URIs it is given
+// come from outside, so it refuses to connect to non-public addresses. It is
+// safe for concurrent use.
+type Client struct {
+	client    *http.Client
+	userAgent string
+	// allow reports whether an address may be connected to. Tests relax it
+	// to reach stand-in servers on the loopback interface.
+	allow func(netip.Addr) bool
+}
+
+// NewClient returns a Client whose requests give up after timeout.
+func NewClient(timeout time.Duration, userAgent string) *Client {
+	c := &Client{
+		userAgent: userAgent,
+		allow:     unfurl.IsPublic,
+	}
+
+	transport := unfurl.NewTransport(timeout, func(addr netip.Addr) bool {
+		return c.allow(addr)
+	})
+	transport.MaxIdleConnsPerHost = 4
+	transport.IdleConnTimeout = 90 * time.Second
+
+	c.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("activitypub: too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// FetchActor fetches the actor at uri. A fragment, as in the keyId of a
+// signature, is dropped first. The actor's ID must be the URI it was
+// fetched from, and it must have an inbox and a public key it owns.
+func (c *Client) FetchActor(ctx context.Context, uri string) (Actor, error) {
+	uri, _, _ = strings.Cut(uri, "#")
+	target, err := parseURL(uri)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return Actor{}, err
+	}
+	req.Header.Set("Accept", Accept)
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Actor{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Actor{}, &StatusError{URL: uri, StatusCode: resp.StatusCode}
+	}
+
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentBytes)).Decode(&actor); err != nil {
+		return Actor{}, fmt.Errorf("activitypub: decoding actor %s: %w", uri, err)
+	}
+	if actor.ID != uri || actor.PublicKey.Owner != actor.ID {
+		return Actor{}, ErrActorMismatch
+	}
+	if actor.Inbox == "" || actor.PublicKey.PublicKeyPem == "" {
+		return Actor{}, fmt.Errorf("activitypub: actor %s has no inbox or public key", uri)
+	}
+	return actor, nil
+}
+
+// Deliver posts an activity to an inbox, signed with key.
+func (c *Client) Deliver(ctx context.Context, inbox string, activity []byte, keyID string, key *rsa.PrivateKey) error {
+	target, err := parseURL(inbox)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(activity))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", c.userAgent)
+	if err := Sign(req, keyID, key, activity); err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDocumentBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{URL: inbox, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func parseURL(rawURL string) (*url.URL, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Host == "" {
+		return nil, ErrUnsupportedURL
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, ErrUnsupportedURL
+	}
+	return target, nil
+}
@@ -0,0 +1,196 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how far a signed request's Date may be from the current
+// time. Older requests are refused so captured ones can't be replayed later.
+const MaxClockSkew = time.Hour
+
+var (
+	// ErrNoSignature is returned for requests without a Signature header.
+	ErrNoSignature = errors.New("activitypub: request is not signed")
+	// ErrInvalidSignature is returned when a signature is malformed, doesn't
+	// cover what it must, or doesn't match.
+	ErrInvalidSignature = errors.New("activitypub: invalid signature")
+)
+
+// Sign signs r with key following the draft-cavage HTTP Signatures scheme
+// that fediverse servers use. It sets Date and Host, and for a request with
+// a body, a Digest of body, and covers all of them in the signature. body
+// must be what r will send.
+func Sign(r *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	if r.Header.Get("Date") == "" {
+		r.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	if r.Host == "" {
+		r.Host = r.URL.Host
+	}
+
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		r.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	hash := sha256.Sum256([]byte(signingString(r, headers)))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return err
+	}
+
+	r.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// Signature is a parsed Signature header.
+type Signature struct {
+	// KeyID names the key that made the signature. For fediverse servers it
+	// is the URI of the actor's publicKey, usually the actor with a fragment.
+	KeyID     string
+	Algorithm string
+	Headers   []string
+	Value     []byte
+}
+
+// ParseSignature reads the Signature header of r, so the key can be looked
+// up by KeyID before the signature is verified.
+func ParseSignature(r *http.Request) (*Signature, error) {
+	header := r.Header.Get("Signature")
+	if header == "" {
+		return nil, ErrNoSignature
+	}
+
+	params := map[string]string{}
+	for _, part := range splitParams(header) {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed parameter %q", ErrInvalidSignature, part)
+		}
+		params[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	sig := &Signature{
+		KeyID:     params["keyId"],
+		Algorithm: params["algorithm"],
+		// Without a headers parameter only Date is signed.
+		Headers: []string{"date"},
+	}
+	if sig.KeyID == "" {
+		return nil, fmt.Errorf("%w: missing keyId", ErrInvalidSignature)
+	}
+	if headers := params["headers"]; headers != "" {
+		sig.Headers = strings.Fields(strings.ToLower(headers))
+	}
+
+	value, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil || len(value) == 0 {
+		return nil, fmt.Errorf("%w: malformed signature value", ErrInvalidSignature)
+	}
+	sig.Value = value
+	return sig, nil
+}
+
+// Verify checks that s is a valid signature of r by key. The signature must
+// cover the request target, Host and Date, and for a request with a body,
+// a Digest that matches body. Date must be within MaxClockSkew of now.
+func (s *Signature) Verify(r *http.Request, body []byte, key *rsa.PublicKey) error {
+	switch s.Algorithm {
+	case "", "rsa-sha256", "hs2019":
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, s.Algorithm)
+	}
+
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, header := range required {
+		if !slices.Contains(s.Headers, header) {
+			return fmt.Errorf("%w: %s is not signed", ErrInvalidSignature, header)
+		}
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("%w: invalid Date", ErrInvalidSignature)
+	}
+	if skew := time.Since(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return fmt.Errorf("%w: Date is too far from the current time", ErrInvalidSignature)
+	}
+
+	if len(body) > 0 && !matchesDigest(r.Header.Get("Digest"), body) {
+		return fmt.Errorf("%w: Digest doesn't match the body", ErrInvalidSignature)
+	}
+
+	hash := sha256.Sum256([]byte(signingString(r, s.Headers)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], s.Value); err != nil {
+		return fmt.Errorf("%w: signature doesn't match", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// signingString builds the string that is signed from the named headers of
+// r, in order.
+func signingString(r *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, header := range headers {
+		var value string
+		switch header {
+		case "(request-target)":
+			value = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			value = r.Host
+		default:
+			value = strings.Join(r.Header.Values(header), ", ")
+		}
+		lines[i] = header + ": " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// matchesDigest reports whether a Digest header, which may list several
+// algorithms, has a SHA-256 entry that matches body.
+func matchesDigest(header string, body []byte) bool {
+	want := strings.TrimPrefix(digest(body), "SHA-256=")
+	for _, entry := range strings.Split(header, ",") {
+		algorithm, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && strings.EqualFold(algorithm, "SHA-256") && value == want {
+			return true
+		}
+	}
+	return false
+}
+
+// splitParams splits a Signature header at the commas between parameters,
+// ignoring commas inside quoted values.
+func splitParams(header string) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i, c := range header {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, header[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, header[start:])
+}
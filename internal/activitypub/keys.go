@@ -0,0 +1,79 @@
+package activitypub
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// keyBits is the size of generated keys, the size other servers expect.
+const keyBits = 2048
+
+// ErrInvalidKey is returned for PEM data that doesn't hold an RSA key.
+var ErrInvalidKey = errors.New("activitypub: invalid RSA key")
+
+// GenerateKey returns a new key for signing an actor's requests.
+func GenerateKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, keyBits)
+}
+
+// EncodePrivateKey returns key as a PKCS #8 PEM block.
+func EncodePrivateKey(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// EncodePublicKey returns key as a PKIX PEM block, the form actors publish.
+func EncodePublicKey(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// ParsePrivateKey reads a PKCS #8 or PKCS #1 PEM private key.
+func ParsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey reads a PKIX or PKCS #1 PEM public key, as found in an
+// actor's publicKeyPem.
+func ParsePublicKey(data string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, ErrInvalidKey
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	return rsaKey, nil
+}
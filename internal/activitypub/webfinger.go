@@ -0,0 +1,42 @@
+package activitypub
+
+import (
+	"errors"
+	"strings"
+)
+
+// WebFingerContentType is the media type of WebFinger responses.
+const WebFingerContentType = "application/jrd+json"
+
+// ErrInvalidResource is returned for WebFinger resources that aren't
+// acct: URIs.
+var ErrInvalidResource = errors.New("activitypub: invalid WebFinger resource")
+
+// JRD is a WebFinger response, which points from an account name to the
+// actor's URI.
+type JRD struct {
+	Subject string   `json:"subject"`
+	Aliases []string `json:"aliases,omitempty"`
+	Links   []Link   `json:"links"`
+}
+
+// Link is one of a JRD's links.
+type Link struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+// ParseAcct splits a WebFinger resource such as "acct:alice@example.com" into
+// its user and domain. The acct: scheme and a leading @ are optional, as
+// clients send "alice@example.com" and "@alice@example.com" too. The domain
+// is lower-cased.
+func ParseAcct(resource string) (user, domain string, err error) {
+	resource = strings.TrimPrefix(resource, "acct:")
+	resource = strings.TrimPrefix(resource, "@")
+	user, domain, ok := strings.Cut(resource, "@")
+	if !ok || user == "" || domain == "" || strings.Contains(domain, "@") {
+		return "", "", ErrInvalidResource
+	}
+	return user, strings.ToLower(domain), nil
+}
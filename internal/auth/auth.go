@@ -41,28 +41,60 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	userID, _, err := ValidateJWTExpiry(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ValidateJWTExpiry is ValidateJWT that also returns when the token expires,
+// for connections that must end when their token does.
+func ValidateJWTExpiry(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	userID, claims, err := validateJWTClaims(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	return userID, claims.ExpiresAt.Time, nil
+}
+
+// ValidateJWTIssuedAt is ValidateJWT that also returns when the token was
+// issued, for refusing tokens issued before they were revoked.
+func ValidateJWTIssuedAt(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	userID, claims, err := validateJWTClaims(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+	if claims.IssuedAt == nil {
+		return uuid.Nil, time.Time{}, fmt.Errorf("token has no issue time")
+	}
+	return userID, claims.IssuedAt.Time, nil
+}
+
+func validateJWTClaims(tokenString, tokenSecret string) (uuid.UUID, *jwt.RegisteredClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, nil, err
 	}
 
 	if !token.Valid {
-		return uuid.Nil, fmt.Errorf("invalid token")
+		return uuid.Nil, nil, fmt.Errorf("invalid token")
 	}
 
 	claims, ok := token.Claims.(*jwt.RegisteredClaims)
 	if !ok {
-		return uuid.Nil, fmt.Errorf("invalid token claims")
+		return uuid.Nil, nil, fmt.Errorf("invalid token claims")
+	}
+
+	if claims.ExpiresAt == nil {
+		return uuid.Nil, nil, fmt.Errorf("token has no expiry")
 	}
 
 	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID in token: %w", err)
+		return uuid.Nil, nil, fmt.Errorf("invalid user ID in token: %w", err)
 	}
 
-	return userID, nil
+	return userID, claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -94,3 +94,51 @@ func TestValidateJWT(t *testing.T) {
 		t.Error("ValidateJWT should have failed for invalid token")
 	}
 }
+
+func TestValidateJWTExpiry(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "testsecret"
+
+	before := time.Now().Add(time.Hour).Truncate(time.Second)
+	jwtToken, err := MakeJWT(userID, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	validatedUserID, expiresAt, err := ValidateJWTExpiry(jwtToken, tokenSecret)
+	if err != nil {
+		t.Fatalf("ValidateJWTExpiry failed: %v", err)
+	}
+	if validatedUserID != userID {
+		t.Errorf("ValidateJWTExpiry returned wrong user ID: got %v, want %v", validatedUserID, userID)
+	}
+	if expiresAt.Before(before) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("ValidateJWTExpiry returned expiry %v, want about an hour from now", expiresAt)
+	}
+}
+
+func TestValidateJWTIssuedAt(t *testing.T) {
+	userID := uuid.New()
+	tokenSecret := "testsecret"
+
+	before := time.Now().Truncate(time.Second)
+	jwtToken, err := MakeJWT(userID, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed: %v", err)
+	}
+
+	validatedUserID, issuedAt, err := ValidateJWTIssuedAt(jwtToken, tokenSecret)
+	if err != nil {
+		t.Fatalf("ValidateJWTIssuedAt failed: %v", err)
+	}
+	if validatedUserID != userID {
+		t.Errorf("ValidateJWTIssuedAt returned wrong user ID: got %v, want %v", validatedUserID, userID)
+	}
+	if issuedAt.Before(before) || issuedAt.After(time.Now()) {
+		t.Errorf("ValidateJWTIssuedAt returned issue time %v, want about now", issuedAt)
+	}
+
+	if _, _, err := ValidateJWTIssuedAt(jwtToken, "wrongsecret"); err == nil {
+		t.Error("ValidateJWTIssuedAt accepted a token signed with another secret")
+	}
+}
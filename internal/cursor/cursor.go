@@ -0,0 +1,43 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Encode builds an opaque pagination cursor pointing just past the row with
+// the given creation time and ID. Pages are ordered newest first, and the ID
+// breaks ties between rows created in the same microsecond.
+func Encode(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode reverses Encode.
+func Decode(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	createdAtPart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor time: %w", err)
+	}
+
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+
+	return createdAt, id, nil
+}
@@ -0,0 +1,52 @@
+package cursor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC)
+	id := uuid.New()
+
+	cursor := Encode(createdAt, id)
+	if cursor == "" {
+		t.Fatal("Encode should not return an empty string")
+	}
+
+	decodedAt, decodedID, err := Decode(cursor)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !decodedAt.Equal(createdAt) {
+		t.Errorf("Decode returned wrong time: got %v, want %v", decodedAt, createdAt)
+	}
+	if decodedID != id {
+		t.Errorf("Decode returned wrong ID: got %v, want %v", decodedID, id)
+	}
+}
+
+func TestEncodeNormalizesTimeZone(t *testing.T) {
+	id := uuid.New()
+	createdAt := time.Date(2025, 3, 14, 10, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	if Encode(createdAt, id) != Encode(createdAt.UTC(), id) {
+		t.Error("Encode should produce the same cursor for the same instant")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"not base64!",
+		Encode(time.Now(), uuid.New())[:10],
+		"bm8tc2VwYXJhdG9y", // "no-separator"
+	}
+	for _, cursor := range tests {
+		if _, _, err := Decode(cursor); err == nil {
+			t.Errorf("Decode(%q) should have failed", cursor)
+		}
+	}
+}
@@ -7,24 +7,36 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, user_id, body)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
-RETURNING id, created_at, updated_at, user_id, body
+INSERT INTO chirps (id, created_at, updated_at, user_id, body, parent_id, root_id, rechirp_of_id, quote_of_id)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6)
+RETURNING id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at
 `
 
 type CreateChirpParams struct {
-	UserID uuid.UUID
-	Body   string
+	UserID      uuid.UUID
+	Body        string
+	ParentID    uuid.NullUUID
+	RootID      uuid.NullUUID
+	RechirpOfID uuid.NullUUID
+	QuoteOfID   uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.UserID,
+		arg.Body,
+		arg.ParentID,
+		arg.RootID,
+		arg.RechirpOfID,
+		arg.QuoteOfID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -32,6 +44,16 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.Body,
+		&i.ParentID,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.HiddenAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -63,9 +85,9 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 }
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password, token)
-VALUES ($1, NOW(), NOW(), $2, $3, $4)
-RETURNING id, created_at, updated_at, email, hashed_password, token, is_chirpy_red
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, token, handle)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5)
+RETURNING id, created_at, updated_at, email, hashed_password, token, is_chirpy_red, follower_count, following_count, handle, role, suspended_until, delete_after, tokens_revoked_at
 `
 
 type CreateUserParams struct {
@@ -73,6 +95,7 @@ type CreateUserParams struct {
 	Email          string
 	HashedPassword string
 	Token          string
+	Handle         sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -81,6 +104,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.Email,
 		arg.HashedPassword,
 		arg.Token,
+		arg.Handle,
 	)
 	var i User
 	err := row.Scan(
@@ -91,6 +115,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.HashedPassword,
 		&i.Token,
 		&i.IsChirpyRed,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
+		&i.TokensRevokedAt,
 	)
 	return i, err
 }
@@ -114,7 +145,7 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, user_id, body FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -126,12 +157,22 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.Body,
+		&i.ParentID,
+		&i.RootID,
+		&i.ReplyCount,
+		&i.LikeCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.HiddenAt,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, token, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, token, is_chirpy_red, follower_count, following_count, handle, role, suspended_until, delete_after, tokens_revoked_at FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -145,12 +186,19 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.HashedPassword,
 		&i.Token,
 		&i.IsChirpyRed,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
+		&i.TokensRevokedAt,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT u.id, u.created_at, u.updated_at, u.email, u.hashed_password, u.token, u.is_chirpy_red FROM users u
+SELECT u.id, u.created_at, u.updated_at, u.email, u.hashed_password, u.token, u.is_chirpy_red, u.follower_count, u.following_count, u.handle, u.role, u.suspended_until, u.delete_after, u.tokens_revoked_at FROM users u
 JOIN refresh_tokens rt ON u.id = rt.user_id
 WHERE rt.token = $1
 AND rt.revoked_at IS NULL
@@ -169,17 +217,31 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.HashedPassword,
 		&i.Token,
 		&i.IsChirpyRed,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
+		&i.TokensRevokedAt,
 	)
 	return i, err
 }
 
 const listChirps = `-- name: ListChirps :many
-SELECT id, created_at, updated_at, user_id, body FROM chirps
+SELECT id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at FROM chirps
+WHERE deleted_at IS NULL
+AND (hidden_at IS NULL OR user_id = $1 OR $2::bool)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) ListChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, listChirps)
+type ListChirpsParams struct {
+	ViewerID      uuid.NullUUID
+	IncludeHidden bool
+}
+
+func (q *Queries) ListChirps(ctx context.Context, arg ListChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirps, arg.ViewerID, arg.IncludeHidden)
 	if err != nil {
 		return nil, err
 	}
@@ -193,6 +255,16 @@ func (q *Queries) ListChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.Body,
+			&i.ParentID,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.HiddenAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -208,12 +280,74 @@ func (q *Queries) ListChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const listChirpsByAuthor = `-- name: ListChirpsByAuthor :many
-SELECT id, created_at, updated_at, user_id, body FROM chirps WHERE user_id = $1
+SELECT id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at FROM chirps WHERE user_id = $1 AND deleted_at IS NULL
+AND (hidden_at IS NULL OR user_id = $2 OR $3::bool)
 Order by created_at ASC
 `
 
-func (q *Queries) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, listChirpsByAuthor, userID)
+type ListChirpsByAuthorParams struct {
+	UserID        uuid.UUID
+	ViewerID      uuid.NullUUID
+	IncludeHidden bool
+}
+
+func (q *Queries) ListChirpsByAuthor(ctx context.Context, arg ListChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthor, arg.UserID, arg.ViewerID, arg.IncludeHidden)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.ParentID,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.HiddenAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listLatestChirpsByAuthor = `-- name: ListLatestChirpsByAuthor :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at FROM chirps
+WHERE user_id = $1
+AND deleted_at IS NULL
+AND hidden_at IS NULL
+AND rechirp_of_id IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`
+
+type ListLatestChirpsByAuthorParams struct {
+	UserID   uuid.UUID
+	PageSize int32
+}
+
+// Lists a user's newest chirps that anyone can see, leaving out rechirps.
+func (q *Queries) ListLatestChirpsByAuthor(ctx context.Context, arg ListLatestChirpsByAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listLatestChirpsByAuthor, arg.UserID, arg.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -227,6 +361,16 @@ func (q *Queries) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]C
 			&i.UpdatedAt,
 			&i.UserID,
 			&i.Body,
+			&i.ParentID,
+			&i.RootID,
+			&i.ReplyCount,
+			&i.LikeCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.HiddenAt,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -245,7 +389,7 @@ const makeChirpyRed = `-- name: MakeChirpyRed :exec
 UPDATE users
 SET is_chirpy_red = TRUE
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, token, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, token, is_chirpy_red, follower_count, following_count, handle, role, suspended_until, delete_after, tokens_revoked_at
 `
 
 func (q *Queries) MakeChirpyRed(ctx context.Context, id uuid.UUID) error {
@@ -268,7 +412,7 @@ const updateUserEmailPassword = `-- name: UpdateUserEmailPassword :one
 UPDATE users
 SET email = $1, hashed_password = $2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, token, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, token, is_chirpy_red, follower_count, following_count, handle, role, suspended_until, delete_after, tokens_revoked_at
 `
 
 type UpdateUserEmailPasswordParams struct {
@@ -288,6 +432,13 @@ func (q *Queries) UpdateUserEmailPassword(ctx context.Context, arg UpdateUserEma
 		&i.HashedPassword,
 		&i.Token,
 		&i.IsChirpyRed,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.Handle,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.DeleteAfter,
+		&i.TokensRevokedAt,
 	)
 	return i, err
 }
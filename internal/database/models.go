@@ -11,12 +11,199 @@ import (
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID        uuid.UUID
+	CreatedAt     time.Time
+	PublicKeyPem  string
+	PrivateKeyPem string
+}
+
 type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	UserID       uuid.UUID
+	Body         string
+	ParentID     uuid.NullUUID
+	RootID       uuid.NullUUID
+	ReplyCount   int32
+	LikeCount    int32
+	RechirpOfID  uuid.NullUUID
+	QuoteOfID    uuid.NullUUID
+	RechirpCount int32
+	QuoteCount   int32
+	HiddenAt     sql.NullTime
+	DeletedAt    sql.NullTime
+}
+
+type ChirpAttachment struct {
+	ChirpID  uuid.UUID
+	MediaID  uuid.UUID
+	Position int32
+}
+
+type ChirpEvent struct {
+	ID               int64
+	CreatedAt        time.Time
+	Type             string
+	ChirpID          uuid.UUID
+	UserID           uuid.UUID
+	Hashtags         []string
+	RootID           uuid.NullUUID
+	MentionedUserIds []uuid.UUID
+}
+
+type ChirpHashtag struct {
+	ChirpID     uuid.UUID
+	Tag         string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	Handle      string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	Body      string
+}
+
+type ChirpSearch struct {
+	ChirpID  uuid.UUID
+	Document interface{}
+}
+
+type ChirpUrl struct {
+	ChirpID     uuid.UUID
+	Url         string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ContentFlag struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Words      []string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+	ResolvedBy uuid.NullUUID
+}
+
+type DataExport struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	Status       string
+	ClaimedUntil sql.NullTime
+	CompletedAt  sql.NullTime
+	ExpiresAt    sql.NullTime
+	BlobKey      sql.NullString
+	SizeBytes    sql.NullInt64
+	Error        sql.NullString
+}
+
+type Draft struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	Body      string
+	ParentID  uuid.NullUUID
+	QuoteOfID uuid.NullUUID
+	MediaIds  []uuid.UUID
+}
+
+type FederationDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	Inbox         string
+	Activity      string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+}
+
+type FilterWord struct {
+	ID          uuid.UUID
+	Word        string
+	Action      string
+	Replacement string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Like struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type LinkPreview struct {
+	Url         string
+	FetchedAt   time.Time
+	ResolvedUrl sql.NullString
+	Title       sql.NullString
+	Description sql.NullString
+	ImageUrl    sql.NullString
+	SiteName    sql.NullString
+	Error       sql.NullString
+}
+
+type MediaFile struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UserID               uuid.UUID
+	ContentType          string
+	SizeBytes            int32
+	Width                int32
+	Height               int32
+	ThumbnailContentType string
+}
+
+type ModerationAction struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ModeratorID    uuid.NullUUID
+	ReportID       uuid.NullUUID
+	Action         string
+	TargetUserID   uuid.UUID
+	TargetChirpID  uuid.NullUUID
+	Note           string
+	SuspendedUntil sql.NullTime
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ChirpID   uuid.UUID
+	Position  int32
+	Text      string
+	VoteCount int32
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Position  int32
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,12 +215,99 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RemoteActor struct {
+	ID           string
+	FetchedAt    time.Time
+	Username     sql.NullString
+	Inbox        string
+	SharedInbox  sql.NullString
+	KeyID        string
+	PublicKeyPem string
+}
+
+type RemoteFollow struct {
+	UserID     uuid.UUID
+	ActorID    string
+	ActivityID string
+	CreatedAt  time.Time
+}
+
+type RemoteLike struct {
+	ChirpID    uuid.UUID
+	ActorID    string
+	ActivityID string
+	CreatedAt  time.Time
+}
+
+type RemoteReply struct {
+	ID          string
+	ChirpID     uuid.UUID
+	ActorID     string
+	Content     string
+	PublishedAt time.Time
+	ReceivedAt  time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	ReporterID uuid.UUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	ChirpBody  sql.NullString
+	Reason     string
+	Details    string
+	Status     string
+	ResolvedAt sql.NullTime
+	ResolvedBy uuid.NullUUID
+	Resolution sql.NullString
+}
+
+type ScheduledChirp struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UserID        uuid.UUID
+	Body          string
+	ParentID      uuid.NullUUID
+	QuoteOfID     uuid.NullUUID
+	MediaIds      []uuid.UUID
+	PublishAt     time.Time
+	FailedAt      sql.NullTime
+	Failure       sql.NullString
+	Attempts      int32
+	NextAttemptAt time.Time
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type Trend struct {
+	WindowName    string
+	Kind          string
+	Rank          int32
+	Item          string
+	Score         float64
+	ActivityCount int32
+	ComputedAt    time.Time
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	Token          string
-	IsChirpyRed    bool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	Token           string
+	IsChirpyRed     bool
+	FollowerCount   int32
+	FollowingCount  int32
+	Handle          sql.NullString
+	Role            string
+	SuspendedUntil  sql.NullTime
+	DeleteAfter     sql.NullTime
+	TokensRevokedAt sql.NullTime
 }
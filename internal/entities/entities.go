@@ -0,0 +1,165 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind identifies the type of an entity.
+type Kind string
+
+const (
+	Hashtag Kind = "hashtag"
+	Mention Kind = "mention"
+	URL     Kind = "url"
+)
+
+// MaxHandleLength is the longest handle a user can register or mention.
+const MaxHandleLength = 15
+
+// maxHashtagLength bounds hashtags so a run of letters cannot produce an
+// unbounded index key.
+const maxHashtagLength = 100
+
+// Entity is a hashtag, mention or URL found in a chirp body. Start and End
+// are offsets in characters (Unicode code points, not bytes) into the body,
+// covering the leading # or @. End is exclusive.
+type Entity struct {
+	Kind  Kind
+	Text  string
+	Start int
+	End   int
+}
+
+// Extract finds the hashtags, mentions and URLs in body, in order of
+// appearance. Hashtag and mention text is normalized to lower case without
+// the leading # or @; URL text is the URL as written.
+//
+// A # or @ only starts an entity at the beginning of the body or after a
+// character that cannot be part of a word, so "a@b.com" and "c#" are plain
+// text. Hashtags are letters, digits and underscores with at least one
+// letter. Mentions must be valid handles and are ignored when the handle runs
+// on into other word characters. URLs start with http://, https:// or www.
+// and run to the next whitespace, less any trailing punctuation; nothing
+// inside a URL is treated as a hashtag or mention.
+func Extract(body string) []Entity {
+	runes := []rune(body)
+	var found []Entity
+
+	for i := 0; i < len(runes); i++ {
+		if i == 0 || !isWordRune(runes[i-1]) {
+			if end := urlEnd(runes, i); end > i {
+				found = append(found, Entity{Kind: URL, Text: string(runes[i:end]), Start: i, End: end})
+				i = end - 1
+				continue
+			}
+		}
+
+		sigil := runes[i]
+		if sigil != '#' && sigil != '@' {
+			continue
+		}
+		if i > 0 && (isWordRune(runes[i-1]) || runes[i-1] == sigil) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		text := string(runes[i+1 : end])
+
+		switch sigil {
+		case '#':
+			if end-i-1 > maxHashtagLength || !strings.ContainsFunc(text, unicode.IsLetter) {
+				continue
+			}
+			found = append(found, Entity{Kind: Hashtag, Text: strings.ToLower(text), Start: i, End: end})
+		case '@':
+			if !ValidHandle(text) {
+				continue
+			}
+			found = append(found, Entity{Kind: Mention, Text: NormalizeHandle(text), Start: i, End: end})
+		}
+		i = end - 1
+	}
+	return found
+}
+
+// ValidHandle reports whether handle can be registered and mentioned: one to
+// MaxHandleLength ASCII letters, digits or underscores.
+func ValidHandle(handle string) bool {
+	if handle == "" || len(handle) > MaxHandleLength {
+		return false
+	}
+	for _, r := range handle {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeHandle returns the form handles are stored and compared in.
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(handle, "@"))
+}
+
+// NormalizeHashtag returns the form hashtags are stored and compared in.
+func NormalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+var urlPrefixes = []string{"https://", "http://", "www."}
+
+// urlEnd returns the end of the URL starting at runes[start], or start if
+// there isn't one.
+func urlEnd(runes []rune, start int) int {
+	prefixLen := 0
+	for _, prefix := range urlPrefixes {
+		if hasPrefixFold(runes[start:], prefix) {
+			prefixLen = len(prefix)
+			break
+		}
+	}
+	if prefixLen == 0 {
+		return start
+	}
+
+	end := start + prefixLen
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+
+	// Sentence punctuation after a URL is almost never part of it. A closing
+	// bracket is kept only when the URL opened one, as in Wikipedia links.
+	for end > start+prefixLen {
+		last := runes[end-1]
+		if strings.ContainsRune(".,:;!?'\"", last) {
+			end--
+			continue
+		}
+		if last == ')' && strings.Count(string(runes[start:end]), "(") < strings.Count(string(runes[start:end]), ")") {
+			end--
+			continue
+		}
+		break
+	}
+
+	host := string(runes[start+prefixLen : end])
+	if host == "" || host[0] == '.' || host[0] == '/' {
+		return start
+	}
+	return end
+}
+
+func hasPrefixFold(runes []rune, prefix string) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	return strings.EqualFold(string(runes[:len(prefix)]), prefix)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
@@ -0,0 +1,112 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{
+			name: "hashtag and mention",
+			body: "Hi @Alice, loving #GoLang",
+			want: []Entity{
+				{Kind: Mention, Text: "alice", Start: 3, End: 9},
+				{Kind: Hashtag, Text: "golang", Start: 18, End: 25},
+			},
+		},
+		{
+			name: "offsets count characters, not bytes",
+			body: "café #über",
+			want: []Entity{
+				{Kind: Hashtag, Text: "über", Start: 5, End: 10},
+			},
+		},
+		{
+			name: "email addresses are not mentions",
+			body: "mail me at bob@example.com",
+			want: nil,
+		},
+		{
+			name: "sigil inside a word",
+			body: "C# and F#",
+			want: nil,
+		},
+		{
+			name: "numeric hashtag",
+			body: "we're #1 at #2024goals",
+			want: []Entity{
+				{Kind: Hashtag, Text: "2024goals", Start: 12, End: 22},
+			},
+		},
+		{
+			name: "handle too long",
+			body: "@abcdefghijklmnop is not a user",
+			want: nil,
+		},
+		{
+			name: "handle followed by non-ASCII letters",
+			body: "@joséfina",
+			want: nil,
+		},
+		{
+			name: "punctuation ends an entity",
+			body: "(#chirpy!) @bob's",
+			want: []Entity{
+				{Kind: Hashtag, Text: "chirpy", Start: 1, End: 8},
+				{Kind: Mention, Text: "bob", Start: 11, End: 15},
+			},
+		},
+		{
+			name: "doubled sigil",
+			body: "##tag @@user",
+			want: nil,
+		},
+		{
+			name: "url with fragment and trailing punctuation",
+			body: "See https://example.com/a#top. #docs",
+			want: []Entity{
+				{Kind: URL, Text: "https://example.com/a#top", Start: 4, End: 29},
+				{Kind: Hashtag, Text: "docs", Start: 31, End: 36},
+			},
+		},
+		{
+			name: "balanced parentheses stay in a url",
+			body: "(www.example.org/Go_(lang))",
+			want: []Entity{
+				{Kind: URL, Text: "www.example.org/Go_(lang)", Start: 1, End: 26},
+			},
+		},
+		{
+			name: "bare scheme is not a url",
+			body: "https:// and xhttp://example.com",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Extract(%q) = %+v, want %+v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidHandle(t *testing.T) {
+	for _, handle := range []string{"a", "chirpy_fan", "User123", "abcdefghijklmno"} {
+		if !ValidHandle(handle) {
+			t.Errorf("ValidHandle(%q) = false, want true", handle)
+		}
+	}
+	for _, handle := range []string{"", "abcdefghijklmnop", "has space", "dash-ed", "josé", "@bob"} {
+		if ValidHandle(handle) {
+			t.Errorf("ValidHandle(%q) = true, want false", handle)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package feed renders lists of chirps as Atom 1.0 and RSS 2.0 documents for
+// feed readers.
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+)
+
+// maxTitleLength is how many characters of an entry's content are used as
+// its title. Chirps have no titles of their own, but both formats expect one.
+const maxTitleLength = 60
+
+// Feed is a feed independent of its format.
+type Feed struct {
+	// ID identifies the feed permanently. It should be a URI.
+	ID    string
+	Title string
+	// Description is only used by RSS, which requires one.
+	Description string
+	// Link is the page the feed is about, and SelfLink the feed itself.
+	Link     string
+	SelfLink string
+	// Updated is when any entry last changed. It defaults to the newest
+	// entry's Updated time.
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry is one item in a feed.
+type Entry struct {
+	// ID identifies the entry permanently, across edits. It should be a
+	// URI, such as a urn:uuid: one.
+	ID        string
+	Link      string
+	Author    string
+	Content   string
+	Published time.Time
+	Updated   time.Time
+}
+
+// Title returns the title an entry is given: the start of its content on a
+// single line.
+func (e Entry) Title() string {
+	title := strings.Join(strings.Fields(e.Content), " ")
+	runes := []rune(title)
+	if len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+	}
+	if title == "" {
+		return "(no text)"
+	}
+	return title
+}
+
+func (f Feed) updated() time.Time {
+	if !f.Updated.IsZero() {
+		return f.Updated
+	}
+	var latest time.Time
+	for _, entry := range f.Entries {
+		if entry.Updated.After(latest) {
+			latest = entry.Updated
+		}
+	}
+	return latest
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Author    atomAuthor  `xml:"author"`
+	Link      *atomLink   `xml:"link,omitempty"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom renders f as an Atom 1.0 document. Every entry carries its own
+// author, so the feed needs none.
+func Atom(f Feed) ([]byte, error) {
+	doc := atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: formatAtomTime(f.updated()),
+		Entries: make([]atomEntry, len(f.Entries)),
+	}
+	if f.Link != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "alternate", Href: f.Link})
+	}
+	if f.SelfLink != "" {
+		doc.Links = append(doc.Links, atomLink{Rel: "self", Type: "application/atom+xml", Href: f.SelfLink})
+	}
+
+	for i, entry := range f.Entries {
+		doc.Entries[i] = atomEntry{
+			ID:        entry.ID,
+			Title:     entry.Title(),
+			Updated:   formatAtomTime(entry.Updated),
+			Published: formatAtomTime(entry.Published),
+			Author:    atomAuthor{Name: entry.Author},
+			Content:   atomContent{Type: "text", Body: entry.Content},
+		}
+		if entry.Link != "" {
+			doc.Entries[i].Link = &atomLink{Rel: "alternate", Href: entry.Link}
+		}
+	}
+
+	return marshal(doc)
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomXMLNS string     `xml:"xmlns:atom,attr"`
+	DCXMLNS   string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      *rssAtom  `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+// rssAtom is the atom:link element RSS feeds use to point at themselves.
+type rssAtom struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description"`
+	Author      string  `xml:"dc:creator,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders f as an RSS 2.0 document. Entry IDs become GUIDs that aren't
+// permalinks, and authors use dc:creator because RSS's own author element
+// requires an email address.
+func RSS(f Feed) ([]byte, error) {
+	doc := rssDocument{
+		Version:   "2.0",
+		AtomXMLNS: "http://www.w3.org/2005/Atom",
+		DCXMLNS:   "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Items:       make([]rssItem, len(f.Entries)),
+		},
+	}
+	if updated := f.updated(); !updated.IsZero() {
+		doc.Channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	if f.SelfLink != "" {
+		doc.Channel.AtomLink = &rssAtom{Rel: "self", Type: "application/rss+xml", Href: f.SelfLink}
+	}
+
+	for i, entry := range f.Entries {
+		doc.Channel.Items[i] = rssItem{
+			Title:       entry.Title(),
+			Link:        entry.Link,
+			Description: entry.Content,
+			Author:      entry.Author,
+			GUID:        rssGUID{IsPermaLink: "false", Value: entry.ID},
+			PubDate:     entry.Published.UTC().Format(time.RFC1123Z),
+		}
+	}
+
+	return marshal(doc)
+}
+
+func formatAtomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func marshal(doc any) ([]byte, error) {
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
@@ -0,0 +1,176 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testFeed() Feed {
+	published := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	return Feed{
+		ID:          "tag:example.com,2025:users/1",
+		Title:       "Chirps by @ada",
+		Description: "The latest chirps by @ada",
+		Link:        "https://example.com/api/chirps?author_id=1",
+		SelfLink:    "https://example.com/users/1/feed.atom",
+		Entries: []Entry{
+			{
+				ID:        "urn:uuid:6f1d0c4e-0000-4000-8000-000000000002",
+				Link:      "https://example.com/api/chirps/2",
+				Author:    "@ada",
+				Content:   "Edited <b>bold</b> & brave",
+				Published: published.Add(time.Hour),
+				Updated:   published.Add(2 * time.Hour),
+			},
+			{
+				ID:        "urn:uuid:6f1d0c4e-0000-4000-8000-000000000001",
+				Author:    "@ada",
+				Content:   "First",
+				Published: published,
+				Updated:   published,
+			},
+		},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	out, err := Atom(testFeed())
+	if err != nil {
+		t.Fatalf("Atom: %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Links   []struct {
+			Rel  string `xml:"rel,attr"`
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Title     string `xml:"title"`
+			Updated   string `xml:"updated"`
+			Published string `xml:"published"`
+			Author    string `xml:"author>name"`
+			Content   struct {
+				Type string `xml:"type,attr"`
+				Body string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("output isn't valid XML: %v\n%s", err, out)
+	}
+
+	if doc.ID != "tag:example.com,2025:users/1" {
+		t.Errorf("feed id = %q", doc.ID)
+	}
+	if doc.Updated != "2025-03-01T14:00:00Z" {
+		t.Errorf("feed updated = %q, want the newest entry's update", doc.Updated)
+	}
+	if len(doc.Links) != 2 || doc.Links[1].Rel != "self" {
+		t.Errorf("links = %+v, want alternate and self", doc.Links)
+	}
+	if len(doc.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(doc.Entries))
+	}
+
+	entry := doc.Entries[0]
+	if entry.ID != "urn:uuid:6f1d0c4e-0000-4000-8000-000000000002" {
+		t.Errorf("entry id = %q", entry.ID)
+	}
+	if entry.Content.Type != "text" || entry.Content.Body != "Edited <b>bold</b> & brave" {
+		t.Errorf("content = %+v, want the text escaped and round-tripped", entry.Content)
+	}
+	if entry.Published != "2025-03-01T13:00:00Z" || entry.Updated != "2025-03-01T14:00:00Z" {
+		t.Errorf("published/updated = %q/%q", entry.Published, entry.Updated)
+	}
+	if entry.Author != "@ada" {
+		t.Errorf("author = %q", entry.Author)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	out, err := RSS(testFeed())
+	if err != nil {
+		t.Fatalf("RSS: %v", err)
+	}
+	if !strings.HasPrefix(string(out), xml.Header) {
+		t.Errorf("output doesn't start with an XML declaration")
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"rss"`
+		Version string   `xml:"version,attr"`
+		Channel struct {
+			Title string `xml:"title"`
+			// RSS's link and the self atom:link both match "link".
+			Links []struct {
+				XMLName xml.Name
+				Rel     string `xml:"rel,attr"`
+				Value   string `xml:",chardata"`
+			} `xml:"link"`
+			Description   string `xml:"description"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title       string `xml:"title"`
+				Description string `xml:"description"`
+				Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				GUID        struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("output isn't valid XML: %v\n%s", err, out)
+	}
+
+	if doc.Version != "2.0" {
+		t.Errorf("version = %q", doc.Version)
+	}
+	if doc.Channel.Title == "" || doc.Channel.Description == "" {
+		t.Errorf("channel = %+v, want title and description", doc.Channel)
+	}
+	if len(doc.Channel.Links) != 2 ||
+		doc.Channel.Links[0].XMLName.Space != "" || doc.Channel.Links[0].Value != "https://example.com/api/chirps?author_id=1" ||
+		doc.Channel.Links[1].XMLName.Space != "http://www.w3.org/2005/Atom" || doc.Channel.Links[1].Rel != "self" {
+		t.Errorf("links = %+v, want the channel link and an atom:link to itself", doc.Channel.Links)
+	}
+	if doc.Channel.LastBuildDate != "Sat, 01 Mar 2025 14:00:00 +0000" {
+		t.Errorf("lastBuildDate = %q", doc.Channel.LastBuildDate)
+	}
+	if len(doc.Channel.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(doc.Channel.Items))
+	}
+
+	item := doc.Channel.Items[1]
+	if item.GUID.Value != "urn:uuid:6f1d0c4e-0000-4000-8000-000000000001" || item.GUID.IsPermaLink != "false" {
+		t.Errorf("guid = %+v", item.GUID)
+	}
+	if item.PubDate != "Sat, 01 Mar 2025 12:00:00 +0000" {
+		t.Errorf("pubDate = %q", item.PubDate)
+	}
+	if item.Creator != "@ada" {
+		t.Errorf("dc:creator = %q", item.Creator)
+	}
+}
+
+func TestEntryTitle(t *testing.T) {
+	tests := map[string]string{
+		"Short":                  "Short",
+		"Two\nlines":             "Two lines",
+		"":                       "(no text)",
+		strings.Repeat("é", 100): strings.Repeat("é", maxTitleLength-1) + "…",
+	}
+	for content, want := range tests {
+		if got := (Entry{Content: content}).Title(); got != want {
+			t.Errorf("Title(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
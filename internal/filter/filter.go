@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+)
+
+// Action is what happens to a chirp that contains a listed word.
+type Action string
+
+const (
+	// Replace censors the word and lets the chirp through.
+	Replace Action = "replace"
+	// Reject refuses the chirp.
+	Reject Action = "reject"
+	// Flag lets the chirp through unchanged and queues it for review.
+	Flag Action = "flag"
+)
+
+// DefaultReplacement is what listed words are usually censored to.
+const DefaultReplacement = "****"
+
+// Valid reports whether a is a known action.
+func (a Action) Valid() bool {
+	return a == Replace || a == Reject || a == Flag
+}
+
+// Rule is one entry in the word list.
+type Rule struct {
+	Word   string
+	Action Action
+	// Replacement is written in place of the word by Replace rules. It may
+	// be empty to remove the word.
+	Replacement string
+}
+
+// Match is an occurrence of a rule's word in a piece of text. Start and End
+// are byte offsets into the original text and cover whatever characters
+// were used to spell the word, so "k3rfuffl3" matches the rule "kerfuffle".
+type Match struct {
+	Rule  Rule
+	Start int
+	End   int
+}
+
+// Result is the outcome of running text through a Filter.
+type Result struct {
+	// Text has every Replace match censored.
+	Text     string
+	Rejected bool
+	Flagged  bool
+	Matches  []Match
+}
+
+// Filter matches text against a word list. Whole words and phrases match
+// regardless of case, accents, look-alike characters from other scripts,
+// leetspeak, or punctuation inserted between letters. A Filter is immutable
+// and safe for concurrent use; to change the list, build a new one.
+type Filter struct {
+	rules   []Rule
+	matcher *matcher
+}
+
+// New compiles rules into a Filter. Rules whose words normalize to nothing
+// are ignored.
+func New(rules []Rule) *Filter {
+	f := &Filter{}
+	var patterns [][]rune
+	for _, rule := range rules {
+		word := Normalize(rule.Word)
+		if word == "" {
+			continue
+		}
+		f.rules = append(f.rules, rule)
+		patterns = append(patterns, []rune(word))
+	}
+	f.matcher = newMatcher(patterns)
+	return f
+}
+
+// Find returns the listed words in text, leftmost first. Where matches
+// overlap, the longest is kept.
+func (f *Filter) Find(text string) []Match {
+	if f == nil || len(f.rules) == 0 {
+		return nil
+	}
+
+	n := normalize(text)
+	var found []occurrence
+	for _, o := range f.matcher.findAll(n.runes) {
+		if o.start > 0 && isWordRune(n.runes[o.start-1]) {
+			continue
+		}
+		if o.end < len(n.runes) && isWordRune(n.runes[o.end]) {
+			continue
+		}
+		found = append(found, o)
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].start != found[j].start {
+			return found[i].start < found[j].start
+		}
+		return found[i].end > found[j].end
+	})
+
+	var matches []Match
+	covered := 0
+	for _, o := range found {
+		if o.start < covered {
+			continue
+		}
+		covered = o.end
+		matches = append(matches, Match{
+			Rule:  f.rules[o.pattern],
+			Start: n.starts[o.start],
+			End:   n.ends[o.end-1],
+		})
+	}
+	return matches
+}
+
+// Apply runs text through the filter.
+func (f *Filter) Apply(text string) Result {
+	result := Result{Text: text, Matches: f.Find(text)}
+	if len(result.Matches) == 0 {
+		return result
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range result.Matches {
+		switch match.Rule.Action {
+		case Reject:
+			result.Rejected = true
+		case Flag:
+			result.Flagged = true
+		case Replace:
+			b.WriteString(text[last:match.Start])
+			b.WriteString(match.Rule.Replacement)
+			last = match.End
+		}
+	}
+	b.WriteString(text[last:])
+	result.Text = b.String()
+	return result
+}
@@ -0,0 +1,107 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"Kerfuffle":            "kerfuffle",
+		"k3rfuffl3":            "kerfuffle",
+		"kérfüfflé":            "kerfuffle",
+		"k.e.r.f.u.f.f.l.e":    "kerfuffle",
+		"k_e*r~f":              "kerf",
+		"end.kerfuffle":        "end.kerfuffle",
+		"k\u0435rfuffl\u0435":  "kerfuffle", // Cyrillic е
+		"ｋｅｒｆｕｆｆｌｅ":            "kerfuffle", // full-width
+		"k\u200berfuffle":      "kerfuffle", // zero-width space
+		"  two   words  ":      "two words",
+		"$h@rb3rt":             "sharbert",
+		"Fo\u0308rnax":         "fornax", // combining diaeresis
+		"\U0001d41f\U0001d428": "fo",     // mathematical bold
+	}
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := New([]Rule{
+		{Word: "kerfuffle", Action: Replace, Replacement: DefaultReplacement},
+		{Word: "sharbert", Action: Replace, Replacement: "[redacted]"},
+		{Word: "fornax", Action: Reject},
+		{Word: "bad idea", Action: Flag},
+	})
+
+	tests := []struct {
+		input    string
+		text     string
+		rejected bool
+		flagged  bool
+	}{
+		{"nothing to see here", "nothing to see here", false, false},
+		{"What a Kerfuffle!", "What a ****!", false, false},
+		{"what a k3rfuffl3 and a $h@rb3rt", "what a **** and a [redacted]", false, false},
+		{"a kerfuffles situation", "a kerfuffles situation", false, false},
+		{"preKerfuffle", "preKerfuffle", false, false},
+		{"k.e.r.f.u.f.f.l.e.", "****.", false, false},
+		{"the end.kerfuffle", "the end.****", false, false},
+		{"kerfuffle.next", "****.next", false, false},
+		{"Hi.Fornax here", "Hi.Fornax here", true, false},
+		{"a.kerfuffle", "a.****", false, false},
+		{"FØRNAX forever", "FØRNAX forever", true, false},
+		{"this is a bad   idea", "this is a bad   idea", false, true},
+		{"bad ideas are fine", "bad ideas are fine", false, false},
+		{"kerfuffle fornax", "**** fornax", true, false},
+	}
+	for _, tt := range tests {
+		got := f.Apply(tt.input)
+		if got.Text != tt.text || got.Rejected != tt.rejected || got.Flagged != tt.flagged {
+			t.Errorf("Apply(%q) = {%q, rejected %v, flagged %v}, want {%q, rejected %v, flagged %v}",
+				tt.input, got.Text, got.Rejected, got.Flagged, tt.text, tt.rejected, tt.flagged)
+		}
+	}
+}
+
+func TestFindOverlaps(t *testing.T) {
+	f := New([]Rule{
+		{Word: "ice", Action: Flag},
+		{Word: "ice cream", Action: Replace, Replacement: DefaultReplacement},
+		{Word: "cream", Action: Flag},
+	})
+
+	got := f.Find("ice cream and ice")
+	want := []Match{
+		{Rule: Rule{Word: "ice cream", Action: Replace, Replacement: DefaultReplacement}, Start: 0, End: 9},
+		{Rule: Rule{Word: "ice", Action: Flag}, Start: 14, End: 17},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMatcherSharedSuffixes(t *testing.T) {
+	m := newMatcher([][]rune{[]rune("he"), []rune("she"), []rune("his"), []rune("hers")})
+	got := m.findAll([]rune("ushers"))
+	want := []occurrence{
+		{pattern: 1, start: 1, end: 4},
+		{pattern: 0, start: 2, end: 4},
+		{pattern: 3, start: 2, end: 6},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findAll() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEmptyFilter(t *testing.T) {
+	var f *Filter
+	if got := f.Find("anything"); got != nil {
+		t.Errorf("nil Filter found %+v", got)
+	}
+	if got := New(nil).Apply("anything"); got.Text != "anything" {
+		t.Errorf("empty Filter changed text to %q", got.Text)
+	}
+}
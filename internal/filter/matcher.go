@@ -0,0 +1,91 @@
+package filter
+
+// matcher finds every occurrence of a set of patterns in one pass using the
+// Aho-Corasick algorithm.
+type matcher struct {
+	nodes   []node
+	lengths []int
+}
+
+type node struct {
+	next map[rune]int
+	fail int
+	// outputs are the patterns ending at this node, including those reached
+	// through failure links.
+	outputs []int
+}
+
+type occurrence struct {
+	pattern    int
+	start, end int
+}
+
+func newMatcher(patterns [][]rune) *matcher {
+	m := &matcher{nodes: []node{{next: map[rune]int{}}}}
+
+	for i, pattern := range patterns {
+		m.lengths = append(m.lengths, len(pattern))
+		current := 0
+		for _, r := range pattern {
+			child, ok := m.nodes[current].next[r]
+			if !ok {
+				child = len(m.nodes)
+				m.nodes = append(m.nodes, node{next: map[rune]int{}})
+				m.nodes[current].next[r] = child
+			}
+			current = child
+		}
+		m.nodes[current].outputs = append(m.nodes[current].outputs, i)
+	}
+
+	// Breadth-first, so every node's failure target is finished before the
+	// node itself.
+	queue := []int{}
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for r, child := range m.nodes[current].next {
+			fail := m.nodes[current].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			failNode := m.nodes[child].fail
+			m.nodes[child].outputs = append(m.nodes[child].outputs, m.nodes[failNode].outputs...)
+			queue = append(queue, child)
+		}
+	}
+	return m
+}
+
+// findAll returns every occurrence of every pattern in text, overlapping or
+// not. Offsets are rune indexes into text.
+func (m *matcher) findAll(text []rune) []occurrence {
+	var found []occurrence
+	current := 0
+	for i, r := range text {
+		for current != 0 {
+			if _, ok := m.nodes[current].next[r]; ok {
+				break
+			}
+			current = m.nodes[current].fail
+		}
+		if next, ok := m.nodes[current].next[r]; ok {
+			current = next
+		}
+
+		for _, pattern := range m.nodes[current].outputs {
+			found = append(found, occurrence{pattern: pattern, start: i + 1 - m.lengths[pattern], end: i + 1})
+		}
+	}
+	return found
+}
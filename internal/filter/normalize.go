@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps letters from other scripts that render like Latin letters
+// onto the letter they imitate. It only needs lower-case forms because text
+// is lower-cased first; full-width and mathematical letters are already
+// folded by compatibility decomposition.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i',
+	'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'һ': 'h',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin look-alikes
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ß': 's',
+}
+
+// leetspeak maps digits and symbols commonly substituted for letters.
+// Punctuation that usually ends a sentence, such as '!', is left alone so
+// it keeps acting as a word boundary.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b',
+	'9': 'g', '@': 'a', '$': 's', '+': 't', '|': 'l',
+}
+
+// isIgnored reports whether r is dropped entirely. These characters are
+// invisible, so inserting them anywhere must not hide a word.
+func isIgnored(r rune) bool {
+	switch r {
+	case '\u00ad', // soft hyphen
+		'\u200b', '\u200c', '\u200d', '\u2060', '\ufeff': // zero-width characters
+		return true
+	}
+	return false
+}
+
+// isSeparator reports whether r is punctuation used to space out the letters
+// of a word ("k.e.r.f"). Elsewhere it separates words like any punctuation.
+func isSeparator(r rune) bool {
+	switch r {
+	case '.', '*', '_', '~':
+		return true
+	}
+	return false
+}
+
+// normalized is text reduced to the form words are matched in, remembering
+// where each rune came from in the original string.
+type normalized struct {
+	runes []rune
+	// starts and ends are byte offsets into the original text for each rune.
+	starts []int
+	ends   []int
+}
+
+// normalize folds text for matching: compatibility decomposition with
+// accents removed, lower case, confusable and leetspeak characters mapped to
+// the letters they stand for, ignored characters dropped, separators between
+// single letters dropped, and runs of whitespace collapsed to a single space.
+func normalize(text string) normalized {
+	var n normalized
+	for i, r := range text {
+		size := utf8.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			d = unicode.ToLower(d)
+			if mapped, ok := confusables[d]; ok {
+				d = mapped
+			}
+			if mapped, ok := leetspeak[d]; ok {
+				d = mapped
+			}
+			if isIgnored(d) {
+				continue
+			}
+			if unicode.IsSpace(d) {
+				if len(n.runes) > 0 && n.runes[len(n.runes)-1] == ' ' {
+					n.ends[len(n.ends)-1] = i + size
+					continue
+				}
+				d = ' '
+			}
+
+			n.runes = append(n.runes, d)
+			n.starts = append(n.starts, i)
+			n.ends = append(n.ends, i+size)
+		}
+	}
+	return n.dropSeparators()
+}
+
+// dropSeparators removes separators that sit between two single letters,
+// joining spelled-out words back together. Any other separator stays, and
+// acts as a word boundary.
+func (n normalized) dropSeparators() normalized {
+	var out normalized
+	for i, r := range n.runes {
+		if isSeparator(r) && n.wordLength(i-1, -1) == 1 && n.wordLength(i+1, 1) == 1 {
+			continue
+		}
+		out.runes = append(out.runes, r)
+		out.starts = append(out.starts, n.starts[i])
+		out.ends = append(out.ends, n.ends[i])
+	}
+	return out
+}
+
+// wordLength counts the word runes starting at i and moving by step.
+func (n normalized) wordLength(i, step int) int {
+	length := 0
+	for ; i >= 0 && i < len(n.runes) && isWordRune(n.runes[i]); i += step {
+		length++
+	}
+	return length
+}
+
+// Normalize returns the form a word list entry is matched in. Two entries
+// with the same normalized form are duplicates.
+func Normalize(word string) string {
+	n := normalize(word)
+	runes := n.runes
+	for len(runes) > 0 && runes[0] == ' ' {
+		runes = runes[1:]
+	}
+	for len(runes) > 0 && runes[len(runes)-1] == ' ' {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,132 @@
+// Package httpcache implements HTTP validators and conditional requests:
+// strong ETags, Last-Modified, If-None-Match, If-Modified-Since and If-Match.
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revalidate is the Cache-Control policy for responses that depend on who is
+// asking. Shared caches mustn't keep them, and the client has to check back
+// with the ETag before every reuse.
+const Revalidate = "private, no-cache"
+
+// Public returns a policy that lets any cache reuse a response for maxAge
+// without checking back.
+func Public(maxAge time.Duration) string {
+	return "public, max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+}
+
+// ETag returns a strong entity tag for a response body. Equal bodies get
+// equal tags, so the tag changes exactly when the representation does.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// VersionedETag returns a strong entity tag for body that also names the
+// version of the resource it shows, as "<version>-<body hash>". The tag
+// changes whenever the body does, while MatchesVersion still accepts it for
+// as long as the version stays the same. version must not contain '"' or '-'.
+func VersionedETag(version string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + version + "-" + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified reports whether a GET or HEAD request can be answered with
+// 304 Not Modified. If-None-Match takes precedence; If-Modified-Since is only
+// consulted when it is absent, as RFC 9110 requires. A zero lastModified
+// never matches If-Modified-Since.
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		return matchesAny(header, etag, false)
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err != nil {
+			return false
+		}
+		// HTTP dates have one-second precision.
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// Matches reports whether an If-Match precondition allows a change to a
+// resource whose current tag is etag. Requests without If-Match always pass.
+// An empty etag means the resource doesn't exist, which only fails "*".
+func Matches(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	return matchesAny(header, etag, true)
+}
+
+// MatchesVersion is Matches for tags made by VersionedETag: any strong tag
+// for the same version passes, whatever body it was sent with, so a
+// representation that only differs in derived data, such as counters, can
+// still be used to make a change. An empty version fails everything but "*".
+func MatchesVersion(r *http.Request, version string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	if strings.TrimSpace(header) == "*" {
+		return version != ""
+	}
+	if version == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.HasPrefix(strings.TrimSpace(candidate), `"`+version+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether etag is in a comma-separated list of entity
+// tags, or the list is "*". If-Match uses the strong comparison, where weak
+// tags never match; If-None-Match uses the weak one, ignoring W/ prefixes.
+func matchesAny(header, etag string, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		weak := strings.HasPrefix(candidate, "W/")
+		if weak {
+			if strong {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetValidators writes the ETag and, when lastModified is set, the
+// Last-Modified header.
+func SetValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
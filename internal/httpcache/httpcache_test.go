@@ -0,0 +1,154 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	a := ETag([]byte(`{"id":1}`))
+	if a != ETag([]byte(`{"id":1}`)) {
+		t.Error("equal bodies got different tags")
+	}
+	if a == ETag([]byte(`{"id":2}`)) {
+		t.Error("different bodies got the same tag")
+	}
+	if a[0] != '"' || a[len(a)-1] != '"' {
+		t.Errorf("ETag = %s, want a quoted strong tag", a)
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := ETag([]byte("body"))
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "no conditions", want: false},
+		{name: "matching tag", headers: map[string]string{"If-None-Match": etag}, want: true},
+		{name: "tag in list", headers: map[string]string{"If-None-Match": `"other", ` + etag}, want: true},
+		{name: "weak form of tag", headers: map[string]string{"If-None-Match": "W/" + etag}, want: true},
+		{name: "star", headers: map[string]string{"If-None-Match": "*"}, want: true},
+		{name: "other tag", headers: map[string]string{"If-None-Match": `"other"`}, want: false},
+		{name: "not modified since", headers: map[string]string{"If-Modified-Since": "Sat, 01 Mar 2025 12:00:00 GMT"}, want: true},
+		{name: "modified since", headers: map[string]string{"If-Modified-Since": "Sat, 01 Mar 2025 11:59:59 GMT"}, want: false},
+		{name: "bad date", headers: map[string]string{"If-Modified-Since": "yesterday"}, want: false},
+		{
+			name: "tag takes precedence over date",
+			headers: map[string]string{
+				"If-None-Match":     `"other"`,
+				"If-Modified-Since": "Sat, 01 Mar 2025 12:00:00 GMT",
+			},
+			want: false,
+		},
+		{name: "not a read", method: http.MethodPut, headers: map[string]string{"If-None-Match": etag}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			for key, value := range tt.headers {
+				r.Header.Set(key, value)
+			}
+			if got := NotModified(r, etag, modified); got != tt.want {
+				t.Errorf("NotModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	etag := ETag([]byte("body"))
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		etag    string
+		want    bool
+	}{
+		{name: "no precondition", ifMatch: "", etag: etag, want: true},
+		{name: "matching tag", ifMatch: etag, etag: etag, want: true},
+		{name: "tag in list", ifMatch: `"a", ` + etag, etag: etag, want: true},
+		{name: "stale tag", ifMatch: `"a"`, etag: etag, want: false},
+		{name: "weak tags never match", ifMatch: "W/" + etag, etag: etag, want: false},
+		{name: "star", ifMatch: "*", etag: etag, want: true},
+		{name: "star on missing resource", ifMatch: "*", etag: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			if got := Matches(r, tt.etag); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesVersion(t *testing.T) {
+	etag := VersionedETag("v1", []byte("body"))
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		version string
+		want    bool
+	}{
+		{name: "no precondition", ifMatch: "", version: "v1", want: true},
+		{name: "same body", ifMatch: etag, version: "v1", want: true},
+		{name: "other body", ifMatch: VersionedETag("v1", []byte("counts changed")), version: "v1", want: true},
+		{name: "tag in list", ifMatch: `"a", ` + etag, version: "v1", want: true},
+		{name: "stale version", ifMatch: etag, version: "v2", want: false},
+		{name: "version prefix", ifMatch: VersionedETag("v", []byte("body")), version: "v1", want: false},
+		{name: "weak tags never match", ifMatch: "W/" + etag, version: "v1", want: false},
+		{name: "star", ifMatch: "*", version: "v1", want: true},
+		{name: "star on missing resource", ifMatch: "*", version: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			if got := MatchesVersion(r, tt.version); got != tt.want {
+				t.Errorf("MatchesVersion = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetValidators(t *testing.T) {
+	w := httptest.NewRecorder()
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	SetValidators(w, `"abc"`, modified)
+
+	if got := w.Header().Get("ETag"); got != `"abc"` {
+		t.Errorf("ETag = %s", got)
+	}
+	if got := w.Header().Get("Last-Modified"); got != "Sat, 01 Mar 2025 11:00:00 GMT" {
+		t.Errorf("Last-Modified = %s", got)
+	}
+
+	w = httptest.NewRecorder()
+	SetValidators(w, `"abc"`, time.Time{})
+	if got := w.Header().Get("Last-Modified"); got != "" {
+		t.Errorf("Last-Modified = %s, want none for a zero time", got)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	if got := Public(90 * time.Second); got != "public, max-age=90" {
+		t.Errorf("Public = %s", got)
+	}
+}
@@ -0,0 +1,56 @@
+package media
+
+import "encoding/binary"
+
+// gifFramePixels adds up the pixels of every frame in a GIF from the frame
+// headers alone, which is what decoding all of them would allocate. It stops
+// counting at anything malformed and leaves that for the decoder to report.
+func gifFramePixels(data []byte) int {
+	// Header and logical screen descriptor.
+	if len(data) < 13 {
+		return 0
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	pixels := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: label, then data sub-blocks
+			i = skipGIFSubBlocks(data, i+2)
+		case 0x2C: // image descriptor
+			if i+10 > len(data) {
+				return pixels
+			}
+			width := int(binary.LittleEndian.Uint16(data[i+5:]))
+			height := int(binary.LittleEndian.Uint16(data[i+7:]))
+			pixels += width * height
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data sub-blocks.
+			i = skipGIFSubBlocks(data, i+1)
+		default: // trailer or garbage
+			return pixels
+		}
+	}
+	return pixels
+}
+
+// skipGIFSubBlocks returns the index just past the sub-blocks starting at i,
+// or len(data) if they run off the end.
+func skipGIFSubBlocks(data []byte, i int) int {
+	for i < len(data) {
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
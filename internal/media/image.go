@@ -0,0 +1,187 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrTooLarge         = errors.New("file is too large")
+	ErrUnsupportedType  = errors.New("unsupported file type")
+	ErrInvalidImage     = errors.New("invalid image")
+	ErrTooManyPixels    = errors.New("image dimensions are too large")
+	supportedImageTypes = map[string]bool{
+		"image/jpeg": true,
+		"image/png":  true,
+		"image/gif":  true,
+	}
+)
+
+const (
+	// maxPixels guards against small files that decode to huge images.
+	maxPixels = 40_000_000
+	// ThumbnailSize is the longest side of a thumbnail, in pixels.
+	ThumbnailSize = 320
+	jpegQuality   = 90
+)
+
+// Image is an upload that has been validated and re-encoded. Re-encoding
+// drops all metadata, including EXIF location data, so Data is safe to serve
+// as-is.
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+
+	ThumbnailContentType string
+	Thumbnail            []byte
+}
+
+// Process reads an uploaded image of at most maxBytes, checks its type from
+// its contents rather than any client-supplied header, and returns it
+// re-encoded along with a thumbnail. JPEGs are rotated upright according to
+// their EXIF orientation before the metadata is discarded. Animated GIFs
+// keep every frame; their thumbnail is the first frame.
+func Process(r io.Reader, maxBytes int64) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !supportedImageTypes[contentType] {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+	// Every frame of a GIF is decoded, so the limit covers them together.
+	if contentType == "image/gif" && gifFramePixels(data) > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	result := &Image{ContentType: contentType}
+	var buf bytes.Buffer
+	var img image.Image
+
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		img = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		err = png.Encode(&buf, img)
+	case "image/gif":
+		var anim *gif.GIF
+		anim, err = gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		img = anim.Image[0]
+		err = gif.EncodeAll(&buf, &gif.GIF{
+			Image:           anim.Image,
+			Delay:           anim.Delay,
+			LoopCount:       anim.LoopCount,
+			Disposal:        anim.Disposal,
+			Config:          anim.Config,
+			BackgroundIndex: anim.BackgroundIndex,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	if contentType == "image/gif" {
+		bounds = image.Rect(0, 0, config.Width, config.Height)
+	}
+	result.Data = buf.Bytes()
+	result.Width = bounds.Dx()
+	result.Height = bounds.Dy()
+
+	// JPEG thumbnails stay JPEG; PNG and GIF thumbnails are PNG so any
+	// transparency survives.
+	thumb := Thumbnail(img, ThumbnailSize)
+	var thumbBuf bytes.Buffer
+	if contentType == "image/jpeg" {
+		result.ThumbnailContentType = "image/jpeg"
+		err = jpeg.Encode(&thumbBuf, thumb, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		result.ThumbnailContentType = "image/png"
+		err = png.Encode(&thumbBuf, thumb)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Thumbnail = thumbBuf.Bytes()
+
+	return result, nil
+}
+
+// Thumbnail scales img down so its longest side is at most size pixels,
+// averaging each block of source pixels into one output pixel. Images that
+// already fit are copied unscaled.
+func Thumbnail(img image.Image, size int) *image.RGBA {
+	src := img.Bounds()
+	w, h := src.Dx(), src.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/src.Dx())
+		} else {
+			w, h = max(1, w*size/src.Dy()), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := src.Min.Y + y*src.Dy()/h
+		y1 := max(y0+1, src.Min.Y+(y+1)*src.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := src.Min.X + x*src.Dx()/w
+			x1 := max(x0+1, src.Min.X+(x+1)*src.Dx()/w)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,215 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+// halves returns a w×h image whose left half is red and right half is blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF segment carrying an orientation tag right
+// after a JPEG's start-of-image marker.
+func withOrientation(jpg []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // header, first IFD at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // orientation, SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(segment) + 2
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, 0xFF, 0xE1, byte(length>>8), byte(length))
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func TestProcessJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, halves(640, 320), nil); err != nil {
+		t.Fatal(err)
+	}
+	upload := withOrientation(buf.Bytes(), 6)
+	if jpegOrientation(upload) != 6 {
+		t.Fatalf("test fixture has orientation %d, want 6", jpegOrientation(upload))
+	}
+
+	img, err := Process(bytes.NewReader(upload), 1<<20)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	if img.ContentType != "image/jpeg" {
+		t.Errorf("ContentType = %q, want image/jpeg", img.ContentType)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Error("processed image still contains EXIF data")
+	}
+
+	// Rotating 90° clockwise puts the red left half on top.
+	if img.Width != 320 || img.Height != 640 {
+		t.Errorf("size = %dx%d, want 320x640", img.Width, img.Height)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("processed image doesn't decode: %v", err)
+	}
+	if !isRed(decoded.At(160, 100)) || isRed(decoded.At(160, 540)) {
+		t.Error("image was not rotated upright")
+	}
+
+	if img.ThumbnailContentType != "image/jpeg" {
+		t.Errorf("ThumbnailContentType = %q, want image/jpeg", img.ThumbnailContentType)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail doesn't decode: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 160 || b.Dy() != ThumbnailSize {
+		t.Errorf("thumbnail size = %dx%d, want 160x%d", b.Dx(), b.Dy(), ThumbnailSize)
+	}
+}
+
+func TestProcessPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(100, 50)); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Process(&buf, 1<<20)
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if img.ContentType != "image/png" || img.ThumbnailContentType != "image/png" {
+		t.Errorf("content types = %q, %q, want image/png", img.ContentType, img.ThumbnailContentType)
+	}
+
+	// Small images are not scaled up.
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail doesn't decode: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("thumbnail size = %dx%d, want 100x50", b.Dx(), b.Dy())
+	}
+}
+
+// bigFrames returns a GIF whose n frame headers each claim a size×size
+// frame, without the image data to fill them.
+func bigFrames(n, size int) []byte {
+	lo, hi := byte(size), byte(size>>8)
+	out := []byte("GIF89a")
+	out = append(out, lo, hi, lo, hi, 0, 0, 0)
+	for range n {
+		out = append(out, 0x2C, 0, 0, 0, 0, lo, hi, lo, hi, 0)
+		out = append(out, 2, 1, 0, 0) // LZW code size, one sub-block
+	}
+	return append(out, 0x3B)
+}
+
+func TestGIFFramePixels(t *testing.T) {
+	frames := []*image.Paletted{
+		image.NewPaletted(image.Rect(0, 0, 40, 30), color.Palette{color.Black, color.White}),
+		image.NewPaletted(image.Rect(10, 10, 20, 20), color.Palette{color.Black, color.White}),
+	}
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{Image: frames, Delay: []int{10, 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gifFramePixels(buf.Bytes()); got != 40*30+10*10 {
+		t.Errorf("gifFramePixels = %d, want %d", got, 40*30+10*10)
+	}
+	if got := gifFramePixels(bigFrames(3, 1000)); got != 3_000_000 {
+		t.Errorf("gifFramePixels = %d, want 3000000", got)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(100, 100)); err != nil {
+		t.Fatal(err)
+	}
+	validPNG := buf.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		max  int64
+		want error
+	}{
+		{"too large", validPNG, int64(len(validPNG)) - 1, ErrTooLarge},
+		{"not an image", []byte("<html><body>hi</body></html>"), 1 << 20, ErrUnsupportedType},
+		{"truncated", validPNG[:40], 1 << 20, ErrInvalidImage},
+		{"too many frames", bigFrames(11, 2000), 1 << 20, ErrTooManyPixels},
+	}
+	for _, tt := range tests {
+		_, err := Process(bytes.NewReader(tt.data), tt.max)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore failed: %v", err)
+	}
+
+	if err := store.Put(ctx, "a/b.png", bytes.NewReader([]byte("hello"))); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	r, err := store.Get(ctx, "a/b.png")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get returned %q, want %q", data, "hello")
+	}
+
+	if err := store.Delete(ctx, "a/b.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "a/b.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete returned %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "a/b.png"); err != nil {
+		t.Errorf("deleting a missing blob failed: %v", err)
+	}
+
+	if err := store.Put(ctx, "../escape", bytes.NewReader(nil)); err == nil {
+		t.Error("Put should reject keys outside the store")
+	}
+}
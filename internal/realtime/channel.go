@@ -0,0 +1,55 @@
+package realtime
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of channel a client can subscribe to.
+const (
+	// Timeline carries chirps from the people the user follows, and their
+	// own.
+	Timeline = "timeline"
+	// Mentions carries chirps that mention the user.
+	Mentions = "mentions"
+	// Thread carries the replies to one conversation, along with who is
+	// reading and typing in it. It is named "thread:" followed by a chirp ID.
+	Thread = "thread"
+)
+
+// ErrUnknownChannel is returned for channel names that aren't recognized.
+var ErrUnknownChannel = errors.New("realtime: unknown channel")
+
+// Channel identifies a stream of events a client can subscribe to.
+type Channel struct {
+	Kind string
+	// ChirpID is the chirp a thread channel was named after.
+	ChirpID uuid.UUID
+}
+
+// ParseChannel parses a channel name sent by a client.
+func ParseChannel(name string) (Channel, error) {
+	switch name {
+	case Timeline, Mentions:
+		return Channel{Kind: name}, nil
+	}
+	id, ok := strings.CutPrefix(name, Thread+":")
+	if !ok {
+		return Channel{}, ErrUnknownChannel
+	}
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		return Channel{}, ErrUnknownChannel
+	}
+	return Channel{Kind: Thread, ChirpID: chirpID}, nil
+}
+
+// String returns the channel's name.
+func (c Channel) String() string {
+	if c.Kind == Thread {
+		return Thread + ":" + c.ChirpID.String()
+	}
+	return c.Kind
+}
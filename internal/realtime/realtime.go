@@ -0,0 +1,214 @@
+// Package realtime runs WebSocket connections that exchange JSON messages
+// with clients. It owns the parts every connection needs regardless of what
+// the messages mean: a bounded queue of outgoing messages, keepalive pings,
+// write deadlines and closing the connection when its credentials expire.
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long a single write may take before the connection
+	// is considered dead.
+	writeWait = 10 * time.Second
+	// maxMessageBytes caps the size of messages from clients, which are
+	// only ever small commands.
+	maxMessageBytes = 4096
+)
+
+// Close codes sent to clients, from the WebSocket registry.
+const (
+	CloseExpired  = websocket.ClosePolicyViolation
+	CloseTryAgain = websocket.CloseTryAgainLater
+)
+
+// ErrClosed is returned when sending on a connection that has closed.
+var ErrClosed = errors.New("realtime: connection closed")
+
+// Message is the envelope of everything sent either way. Clients send
+// commands such as subscribe with a Channel; the server answers with the
+// same Type or "error", and sends events with their Data.
+type Message struct {
+	Type    string          `json:"type"`
+	Channel string          `json:"channel,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// Options configure a connection.
+type Options struct {
+	// Buffer is how many outgoing messages may wait to be written.
+	Buffer int
+	// PingInterval is how often the client is pinged. A client that hasn't
+	// answered within two intervals is disconnected.
+	PingInterval time.Duration
+	// ExpiresAt is when the connection is closed with CloseExpired, such as
+	// when the token it was opened with expires.
+	ExpiresAt time.Time
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Connections authenticate with a bearer token rather than cookies, so a
+	// page on another origin gains nothing by opening one.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Conn is an open WebSocket connection.
+type Conn struct {
+	ws      *websocket.Conn
+	opts    Options
+	send    chan []byte
+	closing chan []byte
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Upgrade switches the request to the WebSocket protocol. On failure it has
+// already responded to the request.
+func Upgrade(w http.ResponseWriter, r *http.Request, opts Options) (*Conn, error) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(maxMessageBytes)
+	return &Conn{
+		ws:      ws,
+		opts:    opts,
+		send:    make(chan []byte, opts.Buffer),
+		closing: make(chan []byte, 1),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Run reads messages and passes each to handle until the connection closes.
+// Messages are handled one at a time, in order, on the calling goroutine.
+// Malformed messages are answered with an error message.
+func (c *Conn) Run(handle func(Message)) {
+	go c.writeLoop()
+	defer c.finish()
+
+	pongWait := 2 * c.opts.PingInterval
+	c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+			c.Send(Message{Type: "error", Error: "Couldn't decode message"})
+			continue
+		}
+		handle(msg)
+	}
+}
+
+// Send queues msg, waiting for room when the queue is full. Replies to
+// clients are sent this way, so a client that stops reading also stops
+// having its commands handled.
+func (c *Conn) Send(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	select {
+	case c.send <- data:
+		return nil
+	case <-c.done:
+		return ErrClosed
+	}
+}
+
+// TrySend queues msg only if there is room, reporting whether it did.
+func (c *Conn) TrySend(msg Message) bool {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close sends the client a close message with code and reason, then closes
+// the connection. Messages still queued are discarded.
+func (c *Conn) Close(code int, reason string) {
+	select {
+	case c.closing <- websocket.FormatCloseMessage(code, reason):
+	default:
+	}
+}
+
+// Done is closed once the connection has closed.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Conn) finish() {
+	c.once.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+func (c *Conn) writeLoop() {
+	ping := time.NewTicker(c.opts.PingInterval)
+	defer ping.Stop()
+	expiry := time.NewTimer(time.Until(c.opts.ExpiresAt))
+	defer expiry.Stop()
+
+	// Finishing closes done, so a Send waiting for room in a queue nobody
+	// drains any more gives up, and closes the socket, so the read loop in
+	// Run returns once the message it is handling is done.
+	defer c.finish()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		case <-expiry.C:
+			c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(CloseExpired, "token expired"), time.Now().Add(writeWait))
+			return
+		case data := <-c.closing:
+			c.ws.WriteControl(websocket.CloseMessage, data, time.Now().Add(writeWait))
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package realtime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// serve starts a server whose connections are handled by handle, and dials
+// it.
+func serve(t *testing.T, opts Options, handle func(c *Conn, msg Message)) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, opts)
+		if err != nil {
+			return
+		}
+		c.Run(func(msg Message) { handle(c, msg) })
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return client
+}
+
+func defaultOptions() Options {
+	return Options{Buffer: 4, PingInterval: time.Minute, ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+func TestRunHandlesMessages(t *testing.T) {
+	client := serve(t, defaultOptions(), func(c *Conn, msg Message) {
+		c.Send(Message{Type: msg.Type + "d", Channel: msg.Channel})
+	})
+
+	client.WriteJSON(Message{Type: "subscribe", Channel: "timeline"})
+	var reply Message
+	if err := client.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if reply.Type != "subscribed" || reply.Channel != "timeline" {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	client.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if err := client.ReadJSON(&reply); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	if reply.Type != "error" {
+		t.Errorf("reply to a malformed message = %+v, want an error", reply)
+	}
+}
+
+func TestConnectionClosesWhenExpired(t *testing.T) {
+	opts := defaultOptions()
+	opts.ExpiresAt = time.Now().Add(50 * time.Millisecond)
+	client := serve(t, opts, func(c *Conn, msg Message) {})
+
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseExpired {
+		t.Fatalf("ReadMessage = %v, want close %d", err, CloseExpired)
+	}
+}
+
+func TestTrySendWhenFull(t *testing.T) {
+	c := &Conn{send: make(chan []byte, 2), done: make(chan struct{})}
+	msg := Message{Type: "event"}
+
+	if !c.TrySend(msg) || !c.TrySend(msg) {
+		t.Fatal("TrySend failed with room in the queue")
+	}
+	if c.TrySend(msg) {
+		t.Error("TrySend succeeded with a full queue")
+	}
+
+	<-c.send
+	close(c.done)
+	if c.TrySend(msg) {
+		t.Error("TrySend succeeded on a closed connection")
+	}
+	if err := c.Send(msg); !errors.Is(err, ErrClosed) {
+		t.Errorf("Send on a closed connection = %v, want ErrClosed", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	client := serve(t, defaultOptions(), func(c *Conn, msg Message) {
+		c.Close(CloseTryAgain, "too slow")
+	})
+
+	client.WriteJSON(Message{Type: "anything"})
+	_, _, err := client.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseTryAgain || closeErr.Text != "too slow" {
+		t.Fatalf("ReadMessage = %v, want close %d", err, CloseTryAgain)
+	}
+}
+
+func TestSendAfterWriterStops(t *testing.T) {
+	opts := defaultOptions()
+	opts.Buffer = 1
+	result := make(chan error, 1)
+	client := serve(t, opts, func(c *Conn, msg Message) {
+		c.Close(CloseTryAgain, "too slow")
+		// Nothing drains the queue once the writer has stopped, so these
+		// fill it; the last must fail instead of waiting forever.
+		var err error
+		for i := 0; i < 10 && err == nil; i++ {
+			err = c.Send(Message{Type: "event"})
+		}
+		result <- err
+	})
+
+	client.WriteJSON(Message{Type: "anything"})
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("Send = %v, want ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked after the connection closed")
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name string
+		want Channel
+		ok   bool
+	}{
+		{"timeline", Channel{Kind: Timeline}, true},
+		{"mentions", Channel{Kind: Mentions}, true},
+		{"thread:" + id.String(), Channel{Kind: Thread, ChirpID: id}, true},
+		{"thread:nope", Channel{}, false},
+		{"thread", Channel{}, false},
+		{"everything", Channel{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseChannel(tt.name)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("ParseChannel(%q) = %+v, %v", tt.name, got, err)
+		}
+		if tt.ok && got.String() != tt.name {
+			t.Errorf("String() = %q, want %q", got.String(), tt.name)
+		}
+	}
+}
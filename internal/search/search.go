@@ -0,0 +1,160 @@
+package search
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// ErrEmptyQuery is returned when a query has no searchable terms.
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// ParseQuery turns a user's search string into Postgres tsquery syntax for
+// to_tsquery. It supports:
+//
+//	word      chirps containing word (stemmed, so "running" matches "run")
+//	"a b c"   the phrase a b c, in order
+//	pre*      words starting with pre
+//	-word     chirps not containing word
+//
+// Terms are ANDed together. Punctuation inside terms is dropped, so user
+// input can never produce tsquery syntax errors.
+func ParseQuery(input string) (string, error) {
+	var clauses []string
+	var negated []string
+
+	rest := strings.TrimSpace(input)
+	for rest != "" {
+		negate := false
+		if rest[0] == '-' {
+			negate = true
+			rest = rest[1:]
+		}
+
+		var clause string
+		if strings.HasPrefix(rest, `"`) {
+			phrase, after, found := strings.Cut(rest[1:], `"`)
+			if !found {
+				after = ""
+			}
+			clause = phraseClause(phrase)
+			rest = after
+		} else {
+			word, after, _ := strings.Cut(rest, " ")
+			clause = wordClause(word)
+			rest = after
+		}
+		rest = strings.TrimSpace(rest)
+
+		if clause == "" {
+			continue
+		}
+		if negate {
+			negated = append(negated, "!"+clause)
+		} else {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	// A query made only of exclusions would match nearly everything.
+	if len(clauses) == 0 {
+		return "", ErrEmptyQuery
+	}
+	return strings.Join(append(clauses, negated...), " & "), nil
+}
+
+func phraseClause(phrase string) string {
+	var words []string
+	for _, word := range strings.Fields(phrase) {
+		if lexeme := cleanWord(word); lexeme != "" {
+			words = append(words, lexeme)
+		}
+	}
+	switch len(words) {
+	case 0:
+		return ""
+	case 1:
+		return words[0]
+	default:
+		return "(" + strings.Join(words, " <-> ") + ")"
+	}
+}
+
+func wordClause(word string) string {
+	prefix := strings.HasSuffix(word, "*")
+	lexeme := cleanWord(strings.TrimSuffix(word, "*"))
+	if lexeme == "" {
+		return ""
+	}
+	if prefix {
+		return lexeme + ":*"
+	}
+	return lexeme
+}
+
+func cleanWord(word string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, word)
+}
+
+// Highlight marks must match the StartSel and StopSel passed to ts_headline.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// Highlight turns a ts_headline snippet into HTML: the chirp text is escaped
+// and matched terms are wrapped in <mark> tags.
+func Highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
+
+// Cursor is a position in ranked search results, which are ordered by rank
+// and then chirp ID, both descending.
+type Cursor struct {
+	Rank float32
+	ID   uuid.UUID
+}
+
+// Encode returns the cursor as an opaque string.
+func (c Cursor) Encode() string {
+	raw := strconv.FormatFloat(float64(c.Rank), 'g', -1, 32) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor reverses Cursor.Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	rankPart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, fmt.Errorf("invalid cursor")
+	}
+
+	rank, err := strconv.ParseFloat(rankPart, 32)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor rank: %w", err)
+	}
+
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor ID: %w", err)
+	}
+
+	return Cursor{Rank: float32(rank), ID: id}, nil
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"hello", "hello"},
+		{"Hello World", "hello & world"},
+		{`"good morning" chirpy`, "(good <-> morning) & chirpy"},
+		{`"  single  "`, "single"},
+		{"chir*", "chir:*"},
+		{"cats -dogs", "cats & !dogs"},
+		{`-"bad day" news`, "news & !(bad <-> day)"},
+		{"it's a c&a test!", "its & a & ca & test"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"café", "café"},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuery(tt.input)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseQuery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryEmpty(t *testing.T) {
+	for _, input := range []string{"", "   ", "!!! ???", `""`, "*", "-excluded"} {
+		_, err := ParseQuery(input)
+		if !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("ParseQuery(%q) should return ErrEmptyQuery, got %v", input, err)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	snippet := "I <3 " + highlightStart + "chirpy" + highlightStop + " & friends"
+	want := "I &lt;3 <mark>chirpy</mark> &amp; friends"
+	if got := Highlight(snippet); got != want {
+		t.Errorf("Highlight() = %q, want %q", got, want)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cursor := Cursor{Rank: 0.0607927, ID: uuid.New()}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if decoded != cursor {
+		t.Errorf("DecodeCursor returned %+v, want %+v", decoded, cursor)
+	}
+
+	if _, err := DecodeCursor("garbage"); err == nil {
+		t.Error("DecodeCursor should have failed for garbage")
+	}
+}
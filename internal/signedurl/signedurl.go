@@ -0,0 +1,59 @@
+// Package signedurl makes links that grant access to one path until they
+// expire, such as download links handed to a browser, which can't send an
+// access token. A link is the path with an expiry time and an HMAC of both
+// in its query string.
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrExpired is returned for a correctly signed link past its expiry.
+	ErrExpired = errors.New("signedurl: link has expired")
+	// ErrInvalidSignature is returned for a link that wasn't signed with the
+	// secret, or was changed after it was.
+	ErrInvalidSignature = errors.New("signedurl: invalid signature")
+)
+
+// Sign returns path with a signature valid until expires.
+func Sign(secret []byte, path string, expires time.Time) string {
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{
+		"expires":   {unix},
+		"signature": {signature(secret, path, unix)},
+	}
+	return path + "?" + query.Encode()
+}
+
+// Verify checks that query holds a signature for path that hasn't expired
+// by now.
+func Verify(secret []byte, path string, query url.Values, now time.Time) error {
+	unix := query.Get("expires")
+	expires, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want := signature(secret, path, unix)
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+func signature(secret []byte, path, expires string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,52 @@
+package signedurl
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Now()
+	link := Sign(secret, "/api/exports/1/download", now.Add(time.Minute))
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("Sign returned an invalid URL %q: %v", link, err)
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		path   string
+		query  url.Values
+		now    time.Time
+		want   error
+	}{
+		{"valid", secret, parsed.Path, parsed.Query(), now, nil},
+		{"expired", secret, parsed.Path, parsed.Query(), now.Add(2 * time.Minute), ErrExpired},
+		{"other path", secret, "/api/exports/2/download", parsed.Query(), now, ErrInvalidSignature},
+		{"other secret", []byte("other"), parsed.Path, parsed.Query(), now, ErrInvalidSignature},
+		{"extended expiry", secret, parsed.Path, withValue(parsed.Query(), "expires", "99999999999"), now, ErrInvalidSignature},
+		{"missing signature", secret, parsed.Path, withValue(parsed.Query(), "signature", ""), now, ErrInvalidSignature},
+		{"missing expiry", secret, parsed.Path, url.Values{}, now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		err := Verify(tt.secret, tt.path, tt.query, tt.now)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if !strings.HasPrefix(link, "/api/exports/1/download?") {
+		t.Errorf("Sign = %q, want the path with a query", link)
+	}
+}
+
+func withValue(query url.Values, key, value string) url.Values {
+	query.Set(key, value)
+	return query
+}
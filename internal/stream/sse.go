@@ -0,0 +1,56 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of a Server-Sent Events stream.
+const ContentType = "text/event-stream"
+
+// WriteEvent writes e as a server-sent event with its ID, type and data.
+// Data spanning several lines is split into several data fields, which
+// clients join back together.
+func WriteEvent(w io.Writer, e Event) error {
+	var b bytes.Buffer
+	b.WriteString("id: ")
+	b.WriteString(strconv.FormatInt(e.ID, 10))
+	b.WriteString("\nevent: ")
+	b.WriteString(e.Type)
+	b.WriteByte('\n')
+	for _, line := range bytes.Split(e.Data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(bytes.TrimSuffix(line, []byte("\r")))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WriteComment writes a comment line, which clients ignore. Comments keep
+// idle connections from being closed by proxies along the way.
+func WriteComment(w io.Writer, comment string) error {
+	_, err := io.WriteString(w, ": "+strings.ReplaceAll(comment, "\n", " ")+"\n\n")
+	return err
+}
+
+// WriteRetry tells the client how long to wait before reconnecting after the
+// connection drops.
+func WriteRetry(w io.Writer, milliseconds int64) error {
+	_, err := io.WriteString(w, "retry: "+strconv.FormatInt(milliseconds, 10)+"\n\n")
+	return err
+}
+
+// ParseLastEventID parses the ID a reconnecting client sends in its
+// Last-Event-ID header. ok is false when there is no usable ID, in which case
+// the client should only get new events.
+func ParseLastEventID(value string) (id int64, ok bool) {
+	id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,156 @@
+// Package stream fans live events out to subscribers, such as clients of the
+// Server-Sent Events endpoint, and writes them in the text/event-stream format.
+//
+// Every subscriber has a bounded buffer. A subscriber that falls so far behind
+// that its buffer fills is dropped rather than allowed to hold up the others
+// or grow without limit; it is expected to reconnect and catch up from the
+// ID of the last event it handled.
+package stream
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is one change to publish. IDs increase over time, so a subscriber
+// that resumes can skip everything up to the last ID it saw. Ephemeral events,
+// which are never stored and can't be resumed, have no ID.
+type Event struct {
+	ID       int64
+	Type     string
+	AuthorID uuid.UUID
+	Hashtags []string
+	Mentions []uuid.UUID
+	// ThreadID is the chirp at the root of the conversation the event is
+	// about.
+	ThreadID uuid.UUID
+	// Data is the event's payload, usually a JSON document.
+	Data []byte
+}
+
+// Filter selects the events a subscriber receives. An event matches when it
+// matches every list that isn't empty: its type is one of Types, its author
+// one of Authors, it has one of Hashtags, mentions one of Mentions and
+// belongs to one of Threads. Hashtags are compared in their normalized form.
+type Filter struct {
+	Types    []string
+	Authors  []uuid.UUID
+	Hashtags []string
+	Mentions []uuid.UUID
+	Threads  []uuid.UUID
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.Authors) > 0 && !slices.Contains(f.Authors, e.AuthorID) {
+		return false
+	}
+	if len(f.Hashtags) > 0 && !containsAny(f.Hashtags, e.Hashtags) {
+		return false
+	}
+	if len(f.Mentions) > 0 && !containsAny(f.Mentions, e.Mentions) {
+		return false
+	}
+	if len(f.Threads) > 0 && !slices.Contains(f.Threads, e.ThreadID) {
+		return false
+	}
+	return true
+}
+
+func containsAny[T comparable](want, have []T) bool {
+	return slices.ContainsFunc(have, func(v T) bool {
+		return slices.Contains(want, v)
+	})
+}
+
+// Hub delivers published events to every subscriber whose filter they match.
+// The zero Hub is ready to use.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// Subscription is one subscriber's view of a Hub.
+type Subscription struct {
+	hub    *Hub
+	filter Filter
+	events chan Event
+	lagged bool
+}
+
+// Subscribe starts receiving events that match filter, buffering up to
+// buffer of them for the subscriber. The subscription must be closed when
+// the subscriber is done with it.
+func (h *Hub) Subscribe(filter Filter, buffer int) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		filter: filter,
+		events: make(chan Event, buffer),
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs == nil {
+		h.subs = make(map[*Subscription]struct{})
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Publish hands e to every matching subscriber without waiting for any of
+// them. Subscribers whose buffers are full are dropped.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			s.lagged = true
+			h.remove(s)
+		}
+	}
+}
+
+// Subscribers returns how many subscriptions are open.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// remove ends a subscription. h.mu must be held.
+func (h *Hub) remove(s *Subscription) {
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.events)
+	}
+}
+
+// Events returns the channel events arrive on. It is closed when the
+// subscription ends, either by Close or because the subscriber lagged.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Lagged reports whether the hub dropped the subscription because its buffer
+// was full. It is only meaningful once Events has been closed.
+func (s *Subscription) Lagged() bool {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.lagged
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
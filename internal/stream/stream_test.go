@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFilterMatch(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	thread := uuid.New()
+	event := Event{
+		Type:     "chirp.created",
+		AuthorID: alice,
+		Hashtags: []string{"go", "sse"},
+		Mentions: []uuid.UUID{bob},
+		ThreadID: thread,
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty", Filter{}, true},
+		{"author", Filter{Authors: []uuid.UUID{bob, alice}}, true},
+		{"other author", Filter{Authors: []uuid.UUID{bob}}, false},
+		{"hashtag", Filter{Hashtags: []string{"rust", "sse"}}, true},
+		{"other hashtag", Filter{Hashtags: []string{"rust"}}, false},
+		{"author and hashtag", Filter{Authors: []uuid.UUID{alice}, Hashtags: []string{"go"}}, true},
+		{"author but not hashtag", Filter{Authors: []uuid.UUID{alice}, Hashtags: []string{"rust"}}, false},
+		{"type", Filter{Types: []string{"chirp.created"}}, true},
+		{"other type", Filter{Types: []string{"typing"}}, false},
+		{"mention", Filter{Mentions: []uuid.UUID{bob}}, true},
+		{"other mention", Filter{Mentions: []uuid.UUID{alice}}, false},
+		{"thread", Filter{Threads: []uuid.UUID{thread}}, true},
+		{"other thread", Filter{Threads: []uuid.UUID{uuid.New()}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(event); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHubDeliversMatchingEvents(t *testing.T) {
+	var hub Hub
+	alice, bob := uuid.New(), uuid.New()
+	all := hub.Subscribe(Filter{}, 4)
+	defer all.Close()
+	onlyBob := hub.Subscribe(Filter{Authors: []uuid.UUID{bob}}, 4)
+	defer onlyBob.Close()
+
+	hub.Publish(Event{ID: 1, AuthorID: alice})
+	hub.Publish(Event{ID: 2, AuthorID: bob})
+
+	if got := (<-all.Events()).ID; got != 1 {
+		t.Errorf("first event = %d, want 1", got)
+	}
+	if got := (<-all.Events()).ID; got != 2 {
+		t.Errorf("second event = %d, want 2", got)
+	}
+	if got := (<-onlyBob.Events()).ID; got != 2 {
+		t.Errorf("filtered event = %d, want 2", got)
+	}
+	if len(onlyBob.Events()) != 0 {
+		t.Error("filtered subscriber got alice's event")
+	}
+}
+
+func TestHubDropsLaggingSubscriber(t *testing.T) {
+	var hub Hub
+	slow := hub.Subscribe(Filter{}, 2)
+	fast := hub.Subscribe(Filter{}, 8)
+	defer fast.Close()
+
+	for id := int64(1); id <= 3; id++ {
+		hub.Publish(Event{ID: id})
+	}
+
+	var got []int64
+	for e := range slow.Events() {
+		got = append(got, e.ID)
+	}
+	if len(got) != 2 || !slow.Lagged() {
+		t.Errorf("slow subscriber got %v, lagged %v; want the first 2 and lagged", got, slow.Lagged())
+	}
+	if len(fast.Events()) != 3 || fast.Lagged() {
+		t.Errorf("fast subscriber has %d events, want 3", len(fast.Events()))
+	}
+	if hub.Subscribers() != 1 {
+		t.Errorf("Subscribers = %d, want 1", hub.Subscribers())
+	}
+	slow.Close()
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	var hub Hub
+	s := hub.Subscribe(Filter{}, 1)
+	s.Close()
+	s.Close()
+
+	hub.Publish(Event{ID: 1})
+	if _, ok := <-s.Events(); ok {
+		t.Error("closed subscription received an event")
+	}
+	if s.Lagged() {
+		t.Error("closed subscription reported lagging")
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteEvent(&b, Event{ID: 42, Type: "chirp.created", Data: []byte("{\"a\":1}\nsecond")})
+	if err != nil {
+		t.Fatalf("WriteEvent: %v", err)
+	}
+	want := "id: 42\nevent: chirp.created\ndata: {\"a\":1}\ndata: second\n\n"
+	if b.String() != want {
+		t.Errorf("WriteEvent wrote %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	WriteComment(&b, "keep\nalive")
+	if b.String() != ": keep alive\n\n" {
+		t.Errorf("WriteComment wrote %q", b.String())
+	}
+}
+
+func TestParseLastEventID(t *testing.T) {
+	tests := []struct {
+		value string
+		id    int64
+		ok    bool
+	}{
+		{"17", 17, true},
+		{" 17 ", 17, true},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"-1", 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := ParseLastEventID(tt.value)
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("ParseLastEventID(%q) = %d, %v; want %d, %v", tt.value, id, ok, tt.id, tt.ok)
+		}
+	}
+}
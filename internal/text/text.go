@@ -0,0 +1,96 @@
+// Package text prepares chirp bodies for storage: it normalizes them, counts
+// their length the way a reader would and finds their entities.
+package text
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mjayio/server/internal/entities"
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLWeight is how many characters a URL counts as, however long it is, so
+// links don't eat into the limit and clients can shorten them for display.
+const URLWeight = 23
+
+// Result is a processed piece of text.
+type Result struct {
+	// Text is the normalized text.
+	Text string
+	// Length is the number of user-perceived characters in Text, with each
+	// URL counted as URLWeight.
+	Length int
+	// Entities are the hashtags, mentions and URLs in Text, with offsets in
+	// code points.
+	Entities []entities.Entity
+}
+
+// Process normalizes s and measures the result. Offsets and lengths refer to
+// Result.Text, not s, so callers should store Text.
+func Process(s string) Result {
+	normalized := Normalize(s)
+	found := entities.Extract(normalized)
+	return Result{
+		Text:     normalized,
+		Length:   length(normalized, found),
+		Entities: found,
+	}
+}
+
+// Normalize puts s in Unicode normalization form C, so a precomposed é and an
+// e followed by a combining accent are stored and counted alike. CRLF line
+// endings become newlines, other whitespace control characters such as tabs
+// become spaces so the words either side stay apart, and the remaining
+// control characters are removed. Format characters such as the zero width joiner
+// are kept because emoji sequences depend on them.
+func Normalize(s string) string {
+	s = norm.NFC.String(s)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case unicode.IsControl(r) && unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// Length returns the length of s as Process would count it.
+func Length(s string) int {
+	return Process(s).Length
+}
+
+// length counts the grapheme clusters in s outside the given URLs and adds
+// URLWeight for each URL.
+func length(s string, found []entities.Entity) int {
+	var urls []entities.Entity
+	for _, entity := range found {
+		if entity.Kind == entities.URL {
+			urls = append(urls, entity)
+		}
+	}
+
+	count := len(urls) * URLWeight
+	pos := 0
+	next := 0
+	graphemes := uniseg.NewGraphemes(s)
+	for graphemes.Next() {
+		start := pos
+		pos += len(graphemes.Runes())
+
+		for next < len(urls) && urls[next].End <= start {
+			next++
+		}
+		if next < len(urls) && urls[next].Start <= start {
+			continue
+		}
+		count++
+	}
+	return count
+}
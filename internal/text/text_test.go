@@ -0,0 +1,70 @@
+package text
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mjayio/server/internal/entities"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"plain":                      "plain",
+		"Cafe\u0301":                 "Caf\u00e9", // combining acute accent
+		"line one\nline two":         "line one\nline two",
+		"bell\a and\x00 nul":         "bell and nul",
+		"crlf\r\n":                   "crlf\n",
+		"tab\there":                  "tab here",
+		"form\ffeed\vtab":            "form feed tab",
+		"next\u0085line":             "next line",
+		"\U0001F469\u200d\U0001F4BB": "\U0001F469\u200d\U0001F4BB", // ZWJ kept
+	}
+	for input, want := range tests {
+		if got := Normalize(input); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{name: "ascii", input: "hello", want: 5},
+		{name: "empty", input: "", want: 0},
+		{name: "accented", input: "Cafe\u0301", want: 4},
+		{name: "cjk", input: "你好世界", want: 4},
+		{name: "family emoji", input: "\U0001F468\u200d\U0001F469\u200d\U0001F467", want: 1},
+		{name: "flag", input: "\U0001F1F3\U0001F1FF", want: 1},
+		{name: "skin tone", input: "\U0001F44B\U0001F3FD!", want: 2},
+		{name: "url", input: "https://example.com", want: URLWeight},
+		{name: "long url", input: "https://example.com/" + strings.Repeat("a", 200), want: URLWeight},
+		{name: "url in text", input: "see www.example.com now", want: 4 + URLWeight + 4},
+		{name: "control characters", input: "a\x00b\x7fc", want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Length(tt.input); got != tt.want {
+				t.Errorf("Length(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessOffsetsFollowNormalizedText(t *testing.T) {
+	// The decomposed é is two code points in the input and one in the
+	// result, so the hashtag's offsets shift left by one.
+	got := Process("Cafe\u0301 #coffee")
+	if got.Text != "Caf\u00e9 #coffee" {
+		t.Fatalf("Text = %q", got.Text)
+	}
+	if got.Length != 12 {
+		t.Errorf("Length = %d, want 12", got.Length)
+	}
+	want := entities.Entity{Kind: entities.Hashtag, Text: "coffee", Start: 5, End: 12}
+	if len(got.Entities) != 1 || got.Entities[0] != want {
+		t.Errorf("Entities = %+v, want [%+v]", got.Entities, want)
+	}
+}
@@ -0,0 +1,108 @@
+package timeline
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps timelines in process memory. It is lost on restart and
+// not shared between server instances, which is fine because an empty
+// timeline makes readers fall back to the database.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	maxEntries int
+	timelines  map[uuid.UUID][]Entry
+}
+
+// NewMemoryStore creates a MemoryStore that keeps at most maxEntries per user.
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		timelines:  make(map[uuid.UUID][]Entry),
+	}
+}
+
+func (s *MemoryStore) Add(ctx context.Context, entry Entry, userIDs []uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, userID := range userIDs {
+		entries := s.timelines[userID]
+		i := sort.Search(len(entries), func(i int) bool {
+			return !Less(entries[i], entry)
+		})
+		if i < len(entries) && entries[i].ChirpID == entry.ChirpID {
+			continue
+		}
+		if i >= s.maxEntries {
+			continue
+		}
+		entries = append(entries, Entry{})
+		copy(entries[i+1:], entries[i:])
+		entries[i] = entry
+		if len(entries) > s.maxEntries {
+			entries = entries[:s.maxEntries]
+		}
+		s.timelines[userID] = entries
+	}
+	return nil
+}
+
+func (s *MemoryStore) Page(ctx context.Context, userID uuid.UUID, before Position, limit int) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := s.timelines[userID]
+	start := sort.Search(len(entries), func(i int) bool {
+		return before.IsBefore(entries[i])
+	})
+	end := min(start+limit, len(entries))
+	page := make([]Entry, end-start)
+	copy(page, entries[start:end])
+	return page, nil
+}
+
+func (s *MemoryStore) RemoveChirp(ctx context.Context, chirpID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, entries := range s.timelines {
+		s.timelines[userID] = removeEntries(entries, func(e Entry) bool {
+			return e.ChirpID == chirpID
+		})
+	}
+	return nil
+}
+
+func (s *MemoryStore) RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.timelines[userID] = removeEntries(s.timelines[userID], func(e Entry) bool {
+		return e.AuthorID == authorID
+	})
+	return nil
+}
+
+func (s *MemoryStore) Clear(ctx context.Context, userIDs []uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, userID := range userIDs {
+		delete(s.timelines, userID)
+	}
+	return nil
+}
+
+func removeEntries(entries []Entry, match func(Entry) bool) []Entry {
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !match(entry) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
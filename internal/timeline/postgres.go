@@ -0,0 +1,89 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+)
+
+// PostgresStore keeps timelines in the timeline_entries table so they
+// survive restarts and are shared by every server instance.
+type PostgresStore struct {
+	queries    *database.Queries
+	maxEntries int32
+}
+
+// NewPostgresStore creates a PostgresStore that keeps at most maxEntries per user.
+func NewPostgresStore(queries *database.Queries, maxEntries int32) *PostgresStore {
+	return &PostgresStore{
+		queries:    queries,
+		maxEntries: maxEntries,
+	}
+}
+
+func (s *PostgresStore) Add(ctx context.Context, entry Entry, userIDs []uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	err := s.queries.AddTimelineEntries(ctx, database.AddTimelineEntriesParams{
+		UserIds:   userIDs,
+		ChirpID:   entry.ChirpID,
+		AuthorID:  entry.AuthorID,
+		CreatedAt: entry.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.queries.TrimTimelines(ctx, database.TrimTimelinesParams{
+		UserIds:    userIDs,
+		MaxEntries: s.maxEntries,
+	})
+}
+
+func (s *PostgresStore) Page(ctx context.Context, userID uuid.UUID, before Position, limit int) ([]Entry, error) {
+	params := database.ListTimelineEntriesParams{
+		UserID:   userID,
+		PageSize: int32(limit),
+	}
+	if !before.IsZero() {
+		params.BeforeCreatedAt = sql.NullTime{Time: before.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: before.ChirpID, Valid: true}
+	}
+
+	rows, err := s.queries.ListTimelineEntries(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, len(rows))
+	for i, row := range rows {
+		entries[i] = Entry{
+			ChirpID:   row.ChirpID,
+			AuthorID:  row.AuthorID,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return entries, nil
+}
+
+func (s *PostgresStore) RemoveChirp(ctx context.Context, chirpID uuid.UUID) error {
+	return s.queries.DeleteTimelineEntriesForChirp(ctx, chirpID)
+}
+
+func (s *PostgresStore) RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error {
+	return s.queries.DeleteTimelineEntriesForAuthor(ctx, database.DeleteTimelineEntriesForAuthorParams{
+		UserID:   userID,
+		AuthorID: authorID,
+	})
+}
+
+func (s *PostgresStore) Clear(ctx context.Context, userIDs []uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return s.queries.DeleteTimelineEntriesForUsers(ctx, userIDs)
+}
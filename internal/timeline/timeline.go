@@ -0,0 +1,91 @@
+package timeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry is one chirp in a user's materialized home timeline.
+type Entry struct {
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+// Position marks where a page of a timeline ends. The zero Position means
+// the newest end of the timeline.
+type Position struct {
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+}
+
+// IsZero reports whether p is the start of the timeline.
+func (p Position) IsZero() bool {
+	return p.CreatedAt.IsZero()
+}
+
+// Store holds per-user materialized timelines. Each timeline is kept newest
+// first and trimmed to a bounded length, so it is always a prefix of the
+// full timeline; readers fall back to querying chirps past its end.
+type Store interface {
+	// Add pushes the entry onto the timeline of every listed user.
+	Add(ctx context.Context, entry Entry, userIDs []uuid.UUID) error
+	// Page returns up to limit entries older than before, newest first.
+	Page(ctx context.Context, userID uuid.UUID, before Position, limit int) ([]Entry, error)
+	// RemoveChirp drops a chirp from every timeline.
+	RemoveChirp(ctx context.Context, chirpID uuid.UUID) error
+	// RemoveAuthor drops an author's chirps from one user's timeline.
+	RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error
+	// Clear empties the timelines of every listed user. They fill up again
+	// as new chirps are added, and until then readers fall back.
+	Clear(ctx context.Context, userIDs []uuid.UUID) error
+}
+
+// Less reports whether a sorts before b in a timeline, i.e. a is newer.
+func Less(a, b Entry) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ChirpID.String() > b.ChirpID.String()
+}
+
+// IsBefore reports whether p comes before e in a timeline, so that e belongs
+// on the page that starts at p.
+func (p Position) IsBefore(e Entry) bool {
+	if p.IsZero() {
+		return true
+	}
+	return Less(Entry{CreatedAt: p.CreatedAt, ChirpID: p.ChirpID}, e)
+}
+
+// Merge combines timelines that are each sorted newest first into one,
+// dropping duplicate chirps and keeping at most limit entries.
+func Merge(limit int, lists ...[]Entry) []Entry {
+	merged := make([]Entry, 0, limit)
+	seen := make(map[uuid.UUID]bool)
+	heads := make([]int, len(lists))
+	for len(merged) < limit {
+		best := -1
+		for i, list := range lists {
+			if heads[i] >= len(list) {
+				continue
+			}
+			if best == -1 || Less(list[heads[i]], lists[best][heads[best]]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			break
+		}
+		entry := lists[best][heads[best]]
+		heads[best]++
+		if seen[entry.ChirpID] {
+			continue
+		}
+		seen[entry.ChirpID] = true
+		merged = append(merged, entry)
+	}
+	return merged
+}
@@ -0,0 +1,175 @@
+package timeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func makeEntries(authorID uuid.UUID, start time.Time, n int) []Entry {
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{
+			ChirpID:   uuid.New(),
+			AuthorID:  authorID,
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return entries
+}
+
+func TestMemoryStoreOrderAndTrim(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(3)
+	userID := uuid.New()
+	entries := makeEntries(uuid.New(), time.Now(), 5)
+
+	// Add out of order to make sure the store sorts newest first.
+	for _, i := range []int{2, 0, 4, 1, 3} {
+		if err := store.Add(ctx, entries[i], []uuid.UUID{userID}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	page, err := store.Page(ctx, userID, Position{}, 10)
+	if err != nil {
+		t.Fatalf("Page failed: %v", err)
+	}
+	if len(page) != 3 {
+		t.Fatalf("Page returned %d entries, want 3", len(page))
+	}
+	for i, want := range []Entry{entries[4], entries[3], entries[2]} {
+		if page[i].ChirpID != want.ChirpID {
+			t.Errorf("entry %d: got %v, want %v", i, page[i].ChirpID, want.ChirpID)
+		}
+	}
+}
+
+func TestMemoryStoreAddIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+	userID := uuid.New()
+	entry := makeEntries(uuid.New(), time.Now(), 1)[0]
+
+	for range 3 {
+		if err := store.Add(ctx, entry, []uuid.UUID{userID}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	page, _ := store.Page(ctx, userID, Position{}, 10)
+	if len(page) != 1 {
+		t.Errorf("Page returned %d entries, want 1", len(page))
+	}
+}
+
+func TestMemoryStorePaging(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(100)
+	userID := uuid.New()
+	entries := makeEntries(uuid.New(), time.Now(), 7)
+	for _, entry := range entries {
+		store.Add(ctx, entry, []uuid.UUID{userID})
+	}
+
+	var seen []Entry
+	position := Position{}
+	for {
+		page, err := store.Page(ctx, userID, position, 3)
+		if err != nil {
+			t.Fatalf("Page failed: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		seen = append(seen, page...)
+		last := page[len(page)-1]
+		position = Position{CreatedAt: last.CreatedAt, ChirpID: last.ChirpID}
+	}
+
+	if len(seen) != len(entries) {
+		t.Fatalf("paged through %d entries, want %d", len(seen), len(entries))
+	}
+	for i := range seen {
+		if seen[i].ChirpID != entries[len(entries)-1-i].ChirpID {
+			t.Errorf("entry %d out of order", i)
+		}
+	}
+}
+
+func TestMemoryStoreRemove(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	authorA := uuid.New()
+	authorB := uuid.New()
+	a := makeEntries(authorA, time.Now(), 2)
+	b := makeEntries(authorB, time.Now().Add(time.Hour), 1)
+	for _, entry := range append(a, b...) {
+		store.Add(ctx, entry, []uuid.UUID{userID, otherUserID})
+	}
+
+	store.RemoveChirp(ctx, a[0].ChirpID)
+	page, _ := store.Page(ctx, otherUserID, Position{}, 10)
+	if len(page) != 2 {
+		t.Errorf("RemoveChirp should remove the chirp from every timeline, got %d entries", len(page))
+	}
+
+	store.RemoveAuthor(ctx, userID, authorA)
+	page, _ = store.Page(ctx, userID, Position{}, 10)
+	if len(page) != 1 || page[0].AuthorID != authorB {
+		t.Errorf("RemoveAuthor should leave only author B's chirp, got %v", page)
+	}
+	page, _ = store.Page(ctx, otherUserID, Position{}, 10)
+	if len(page) != 2 {
+		t.Errorf("RemoveAuthor should not touch other timelines, got %d entries", len(page))
+	}
+}
+
+func TestMemoryStoreClear(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(10)
+	userID := uuid.New()
+	otherUserID := uuid.New()
+	for _, entry := range makeEntries(uuid.New(), time.Now(), 3) {
+		store.Add(ctx, entry, []uuid.UUID{userID, otherUserID})
+	}
+
+	store.Clear(ctx, []uuid.UUID{userID})
+	page, _ := store.Page(ctx, userID, Position{}, 10)
+	if len(page) != 0 {
+		t.Errorf("Clear should empty the timeline, got %d entries", len(page))
+	}
+	page, _ = store.Page(ctx, otherUserID, Position{}, 10)
+	if len(page) != 3 {
+		t.Errorf("Clear should not touch other timelines, got %d entries", len(page))
+	}
+}
+
+func TestMerge(t *testing.T) {
+	start := time.Now()
+	a := makeEntries(uuid.New(), start, 3)
+	b := makeEntries(uuid.New(), start.Add(30*time.Second), 3)
+
+	// b[0] also appears in the first list and must only be returned once.
+	first := []Entry{a[2], a[1], b[0], a[0]}
+	second := []Entry{b[2], b[1], b[0]}
+	merged := Merge(10, first, second)
+	want := []Entry{b[2], a[2], b[1], a[1], b[0], a[0]}
+	if len(merged) != len(want) {
+		t.Fatalf("Merge returned %d entries, want %d", len(merged), len(want))
+	}
+
+	for i := range want {
+		if merged[i].ChirpID != want[i].ChirpID {
+			t.Errorf("entry %d: got %v, want %v", i, merged[i].CreatedAt, want[i].CreatedAt)
+		}
+	}
+
+	if limited := Merge(2, first, second); len(limited) != 2 {
+		t.Errorf("Merge should stop at the limit, got %d entries", len(limited))
+	}
+}
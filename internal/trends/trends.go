@@ -0,0 +1,124 @@
+package trends
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Window is a sliding period that trends are computed over. Activity older
+// than Length is ignored, and newer activity counts for half as much every
+// HalfLife, so recent bursts outrank steady background chatter.
+type Window struct {
+	Name     string
+	Length   time.Duration
+	HalfLife time.Duration
+}
+
+// Event is one unit of activity, such as a hashtag use or a like, on the
+// item identified by Key.
+type Event struct {
+	Key    string
+	At     time.Time
+	Weight float64
+}
+
+// Trend is an item's decayed score within a window and the number of events
+// that contributed to it.
+type Trend struct {
+	Key   string
+	Score float64
+	Count int
+}
+
+// ParseWindows parses a comma-separated list of durations such as "1h,24h".
+// Each window's half-life is its length multiplied by halfLifeRatio. Window
+// names are the durations as written, so they can be used as API parameters.
+func ParseWindows(spec string, halfLifeRatio float64) ([]Window, error) {
+	if halfLifeRatio <= 0 {
+		return nil, fmt.Errorf("half-life ratio must be positive, got %v", halfLifeRatio)
+	}
+
+	var windows []Window
+	seen := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		length, err := time.ParseDuration(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", name, err)
+		}
+		if length <= 0 {
+			return nil, fmt.Errorf("invalid window %q: must be positive", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate window %q", name)
+		}
+		seen[name] = true
+
+		windows = append(windows, Window{
+			Name:     name,
+			Length:   length,
+			HalfLife: time.Duration(float64(length) * halfLifeRatio),
+		})
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no windows in %q", spec)
+	}
+	return windows, nil
+}
+
+// Longest returns the longest of the given windows.
+func Longest(windows []Window) time.Duration {
+	var longest time.Duration
+	for _, window := range windows {
+		longest = max(longest, window.Length)
+	}
+	return longest
+}
+
+// Rank scores events within window as of now and returns the top limit
+// items, highest score first. Each event contributes its weight halved for
+// every half-life of age; events from the future, which can appear with
+// clock skew between servers, count as brand new.
+func Rank(events []Event, window Window, now time.Time, limit int) []Trend {
+	start := now.Add(-window.Length)
+	byKey := map[string]*Trend{}
+
+	for _, event := range events {
+		if event.At.Before(start) {
+			continue
+		}
+		age := max(now.Sub(event.At), 0)
+		decay := math.Exp2(-float64(age) / float64(window.HalfLife))
+
+		trend, ok := byKey[event.Key]
+		if !ok {
+			trend = &Trend{Key: event.Key}
+			byKey[event.Key] = trend
+		}
+		trend.Score += event.Weight * decay
+		trend.Count++
+	}
+
+	ranked := make([]Trend, 0, len(byKey))
+	for _, trend := range byKey {
+		ranked = append(ranked, *trend)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Key < ranked[j].Key
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
@@ -0,0 +1,99 @@
+package trends
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseWindows(t *testing.T) {
+	windows, err := ParseWindows(" 1h, 24h ", 0.25)
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+
+	want := []Window{
+		{Name: "1h", Length: time.Hour, HalfLife: 15 * time.Minute},
+		{Name: "24h", Length: 24 * time.Hour, HalfLife: 6 * time.Hour},
+	}
+	if len(windows) != len(want) {
+		t.Fatalf("got %d windows, want %d", len(windows), len(want))
+	}
+	for i := range want {
+		if windows[i] != want[i] {
+			t.Errorf("window %d = %+v, want %+v", i, windows[i], want[i])
+		}
+	}
+
+	if got := Longest(windows); got != 24*time.Hour {
+		t.Errorf("Longest() = %s, want 24h", got)
+	}
+
+	for _, spec := range []string{"", "1h,1h", "soon", "-1h"} {
+		if _, err := ParseWindows(spec, 0.25); err == nil {
+			t.Errorf("ParseWindows(%q) should have failed", spec)
+		}
+	}
+	if _, err := ParseWindows("1h", 0); err == nil {
+		t.Error("ParseWindows should reject a zero half-life ratio")
+	}
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := Window{Name: "1h", Length: time.Hour, HalfLife: 10 * time.Minute}
+
+	events := []Event{
+		// Three uses just under an hour ago: heavily decayed.
+		{Key: "old", At: now.Add(-50 * time.Minute), Weight: 1},
+		{Key: "old", At: now.Add(-50 * time.Minute), Weight: 1},
+		{Key: "old", At: now.Add(-50 * time.Minute), Weight: 1},
+		// One use right now outranks them.
+		{Key: "new", At: now, Weight: 1},
+		// One use exactly one half-life ago.
+		{Key: "half", At: now.Add(-10 * time.Minute), Weight: 1},
+		// Outside the window entirely.
+		{Key: "gone", At: now.Add(-2 * time.Hour), Weight: 100},
+		// From a server whose clock runs ahead.
+		{Key: "skew", At: now.Add(time.Minute), Weight: 2},
+	}
+
+	got := Rank(events, window, now, 10)
+	wantKeys := []string{"skew", "new", "half", "old"}
+	if len(got) != len(wantKeys) {
+		t.Fatalf("Rank returned %d trends, want %d: %+v", len(got), len(wantKeys), got)
+	}
+	for i, key := range wantKeys {
+		if got[i].Key != key {
+			t.Errorf("trend %d = %q, want %q", i, got[i].Key, key)
+		}
+	}
+
+	if got[1].Score != 1 {
+		t.Errorf("score of a brand new event = %v, want 1", got[1].Score)
+	}
+	if math.Abs(got[2].Score-0.5) > 1e-9 {
+		t.Errorf("score after one half-life = %v, want 0.5", got[2].Score)
+	}
+	if got[3].Count != 3 {
+		t.Errorf("count = %d, want 3", got[3].Count)
+	}
+
+	if limited := Rank(events, window, now, 2); len(limited) != 2 || limited[0].Key != "skew" {
+		t.Errorf("Rank with limit 2 = %+v", limited)
+	}
+}
+
+func TestRankTies(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := Window{Name: "1h", Length: time.Hour, HalfLife: time.Hour}
+
+	events := []Event{
+		{Key: "b", At: now, Weight: 1},
+		{Key: "a", At: now, Weight: 1},
+	}
+	got := Rank(events, window, now, 10)
+	if got[0].Key != "a" || got[1].Key != "b" {
+		t.Errorf("ties should be broken by key, got %+v", got)
+	}
+}
@@ -0,0 +1,123 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Limits on the card fields, in characters. Pages sometimes stuff whole
+// articles into their description.
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+	maxSiteNameLength    = 100
+	maxImageURLLength    = 2048
+)
+
+// parse reads the metadata in the document's head. base is the page's URL,
+// which relative image URLs are resolved against.
+func parse(r io.Reader, base *url.URL) Card {
+	meta := map[string]string{}
+	var title strings.Builder
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(r)
+loop:
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			// Either the end of the document or the size limit; use whatever
+			// was read.
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "meta":
+				key, content := metaAttrs(token.Attr)
+				// The first value wins, as it does for most consumers.
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = content
+				}
+			case "title":
+				inTitle = true
+			case "body":
+				break loop
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = false
+			case "head":
+				break loop
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		}
+	}
+
+	card := Card{
+		URL:         base.String(),
+		Title:       first(meta["og:title"], meta["twitter:title"], title.String()),
+		Description: first(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    first(meta["og:site_name"]),
+	}
+	card.Title = truncate(clean(card.Title), maxTitleLength)
+	card.Description = truncate(clean(card.Description), maxDescriptionLength)
+	card.SiteName = truncate(clean(card.SiteName), maxSiteNameLength)
+
+	image := first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"])
+	if image != "" {
+		if ref, err := base.Parse(image); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") {
+			if s := ref.String(); len(s) <= maxImageURLLength {
+				card.ImageURL = s
+			}
+		}
+	}
+	return card
+}
+
+// metaAttrs returns the key of a meta tag, from its property or name
+// attribute, and its content. OpenGraph uses property and Twitter cards use
+// name, but pages mix them up often enough that either is accepted.
+func metaAttrs(attrs []html.Attribute) (key, content string) {
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(attr.Val))
+			}
+		case "content":
+			content = attr.Val
+		}
+	}
+	return key, content
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// clean collapses runs of whitespace, which titles often contain from the
+// way the HTML was indented.
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
@@ -0,0 +1,222 @@
+// Package unfurl fetches web pages and reads the OpenGraph and Twitter card
+// metadata they publish, for showing link previews.
+//
+// The URLs come from users, so fetching is locked down: only http and https
+// are allowed, connections to private, loopback and other non-public
+// addresses are refused after DNS resolution (which also covers redirects
+// and DNS rebinding), and every fetch is bounded in time and size.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// maxRedirects is how many redirects a fetch follows.
+const maxRedirects = 5
+
+var (
+	// ErrUnsupportedURL is returned for URLs that aren't http or https.
+	ErrUnsupportedURL = errors.New("unfurl: unsupported URL")
+	// ErrBlockedAddress is returned when a URL resolves to an address that
+	// isn't on the public internet.
+	ErrBlockedAddress = errors.New("unfurl: address is not public")
+	// ErrNotHTML is returned when the page isn't an HTML document.
+	ErrNotHTML = errors.New("unfurl: not an HTML page")
+	// ErrNoCard is returned when the page has no title or description.
+	ErrNoCard = errors.New("unfurl: page has no preview metadata")
+)
+
+// Card is the preview of a page.
+type Card struct {
+	// URL is where the page was found after following redirects.
+	URL         string
+	Title       string
+	Description string
+	// ImageURL is absolute, or empty when the page has no image.
+	ImageURL string
+	SiteName string
+}
+
+// Fetcher fetches pages for previews. It is safe for concurrent use.
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+	// allow reports whether an address may be connected to. Tests relax it
+	// to reach servers on the loopback interface.
+	allow func(netip.Addr) bool
+}
+
+// New returns a Fetcher that gives up on a page after timeout and reads at
+// most maxBytes of it. Metadata past that point is ignored.
+func New(timeout time.Duration, maxBytes int64, userAgent string) *Fetcher {
+	f := &Fetcher{
+		maxBytes:  maxBytes,
+		userAgent: userAgent,
+		allow:     IsPublic,
+	}
+
+	transport := NewTransport(timeout, func(addr netip.Addr) bool {
+		return f.allow(addr)
+	})
+	transport.MaxIdleConns = 10
+	transport.IdleConnTimeout = 30 * time.Second
+
+	f.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("unfurl: too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// NewTransport returns a transport for requests to URLs from outside, which
+// refuses with ErrBlockedAddress to connect to any address allow rejects,
+// usually IsPublic. Dialing, the TLS handshake and waiting for response
+// headers are each bounded by timeout.
+func NewTransport(timeout time.Duration, allow func(netip.Addr) bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Control runs after name resolution, once per address tried, so it
+		// sees exactly what is about to be connected to.
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !allow(addr) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+			}
+			return nil
+		},
+	}
+
+	return &http.Transport{
+		// A proxy from the environment would make the connection on our
+		// behalf, out of reach of the address check.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+	}
+}
+
+// Fetch downloads the page at rawURL and returns its card. URLs without a
+// scheme, such as "www.example.com", are fetched over https.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Card, error) {
+	target, err := parseURL(rawURL)
+	if err != nil {
+		return Card{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return Card{}, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	if f.userAgent != "" {
+		req.Header.Set("User-Agent", f.userAgent)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Card{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Card{}, fmt.Errorf("unfurl: unexpected status %s", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return Card{}, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return Card{}, err
+	}
+
+	card := parse(body, resp.Request.URL)
+	if card.Title == "" && card.Description == "" {
+		return Card{}, ErrNoCard
+	}
+	return card, nil
+}
+
+func parseURL(rawURL string) (*url.URL, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedURL, err)
+	}
+	target.Scheme = strings.ToLower(target.Scheme)
+	if target.Scheme != "http" && target.Scheme != "https" || target.Hostname() == "" {
+		return nil, ErrUnsupportedURL
+	}
+	return target, nil
+}
+
+// blockedPrefixes are special-purpose ranges that IsGlobalUnicast and
+// IsPrivate don't already exclude.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can reach private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("100::/64"),        // discard
+	netip.MustParsePrefix("2001::/23"),       // IETF protocol assignments
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which can reach private IPv4
+}
+
+// IsPublic reports whether addr is an ordinary address on the public
+// internet, as opposed to a loopback, private, link-local, multicast or
+// otherwise special-purpose one.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
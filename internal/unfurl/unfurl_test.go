@@ -0,0 +1,186 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFetcher returns a fetcher that may connect to the loopback
+// interface, where httptest servers listen.
+func newTestFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	f := New(timeout, maxBytes, "test")
+	f.allow = func(addr netip.Addr) bool { return addr.IsLoopback() }
+	return f
+}
+
+func serveHTML(page string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}))
+}
+
+func TestFetchOpenGraph(t *testing.T) {
+	server := serveHTML(`<!doctype html>
+<html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="  The   real title ">
+<meta property="og:description" content="What the page is about">
+<meta property="og:image" content="/images/card.png">
+<meta property="og:site_name" content="Example">
+</head><body><meta property="og:title" content="Not in head"></body></html>`)
+	defer server.Close()
+
+	card, err := newTestFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL+"/post")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := Card{
+		URL:         server.URL + "/post",
+		Title:       "The real title",
+		Description: "What the page is about",
+		ImageURL:    server.URL + "/images/card.png",
+		SiteName:    "Example",
+	}
+	if card != want {
+		t.Errorf("Fetch = %+v, want %+v", card, want)
+	}
+}
+
+func TestFetchFallbacks(t *testing.T) {
+	server := serveHTML(`<html><head>
+<title>Page title</title>
+<meta name="twitter:description" content="From the Twitter card">
+<meta name="twitter:image" content="javascript:alert(1)">
+</head></html>`)
+	defer server.Close()
+
+	card, err := newTestFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if card.Title != "Page title" {
+		t.Errorf("Title = %q, want %q", card.Title, "Page title")
+	}
+	if card.Description != "From the Twitter card" {
+		t.Errorf("Description = %q, want %q", card.Description, "From the Twitter card")
+	}
+	if card.ImageURL != "" {
+		t.Errorf("ImageURL = %q, want it dropped", card.ImageURL)
+	}
+}
+
+func TestFetchFollowsRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/short" {
+			http.Redirect(w, r, "/long", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<title>Landed</title>`))
+	}))
+	defer server.Close()
+
+	card, err := newTestFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL+"/short")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if card.URL != server.URL+"/long" || card.Title != "Landed" {
+		t.Errorf("Fetch = %+v", card)
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	server := serveHTML(`<title>Internal</title>`)
+	defer server.Close()
+
+	_, err := New(time.Second, 1<<20, "test").Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Fetch = %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestFetchRejectsOtherSchemes(t *testing.T) {
+	for _, rawURL := range []string{"ftp://example.com/file", "file:///etc/passwd", "https://"} {
+		_, err := New(time.Second, 1<<20, "test").Fetch(context.Background(), rawURL)
+		if !errors.Is(err, ErrUnsupportedURL) {
+			t.Errorf("Fetch(%q) = %v, want ErrUnsupportedURL", rawURL, err)
+		}
+	}
+}
+
+func TestFetchTimesOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := newTestFetcher(50*time.Millisecond, 1<<20).Fetch(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("Fetch succeeded, want a timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Fetch took %v", elapsed)
+	}
+}
+
+func TestFetchCapsResponseSize(t *testing.T) {
+	server := serveHTML("<html><head><!--" + strings.Repeat("x", 4096) + `--><title>Too far in</title></head></html>`)
+	defer server.Close()
+
+	_, err := newTestFetcher(time.Second, 1024).Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrNoCard) {
+		t.Fatalf("Fetch = %v, want ErrNoCard", err)
+	}
+}
+
+func TestFetchRejectsNonHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	}))
+	defer server.Close()
+
+	_, err := newTestFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrNotHTML) {
+		t.Fatalf("Fetch = %v, want ErrNotHTML", err)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::":    true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false, // cloud metadata
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::1":                  false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"64:ff9b::a00:1":       false,
+		"2002:c0a8:101::1":     false,
+		"::ffff:93.184.216.34": true,
+	}
+	for input, want := range tests {
+		if got := IsPublic(netip.MustParseAddr(input)); got != want {
+			t.Errorf("IsPublic(%s) = %v, want %v", input, got, want)
+		}
+	}
+}
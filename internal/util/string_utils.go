@@ -3,6 +3,8 @@ package util
 import (
 	"regexp"
 	"strings"
+
+	"github.com/mjayio/server/internal/filter"
 )
 
 // ReplaceSubstring replaces occurrences of old with new in the input string.
@@ -25,9 +27,19 @@ func ReplaceWholeWord(input, oldWord, newWord string) string {
 }
 
 // ReplaceWholeWordCaseInsensitive replaces occurrences of a word with a new word in case-insensitive manner.
-// It only replaces complete words (not parts of other words).
+// It only replaces complete words (not parts of other words), and also catches the word when it is
+// disguised with accents, look-alike characters or leetspeak.
 func ReplaceWholeWordCaseInsensitive(input, oldWord, newWord string) string {
-	pattern := `(?i)\b` + regexp.QuoteMeta(oldWord) + `\b`
-	regex := regexp.MustCompile(pattern)
-	return regex.ReplaceAllString(input, newWord)
+	return ReplaceWholeWords(input, []string{oldWord}, newWord)
+}
+
+// ReplaceWholeWords replaces every listed word with newWord in a single pass, matching the same way as
+// ReplaceWholeWordCaseInsensitive. Callers that censor the same list repeatedly should build a
+// filter.Filter once instead.
+func ReplaceWholeWords(input string, words []string, newWord string) string {
+	rules := make([]filter.Rule, len(words))
+	for i, word := range words {
+		rules[i] = filter.Rule{Word: word, Action: filter.Replace, Replacement: newWord}
+	}
+	return filter.New(rules).Apply(input).Text
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+type likeResponse struct {
+	ChirpID   string `json:"chirp_id"`
+	LikeCount int32  `json:"like_count"`
+	LikedByMe bool   `json:"liked_by_me"`
+}
+
+func (cfg *apiConfig) handlerChirpsLike(w http.ResponseWriter, r *http.Request) {
+	cfg.setChirpLike(w, r, true)
+}
+
+func (cfg *apiConfig) handlerChirpsUnlike(w http.ResponseWriter, r *http.Request) {
+	cfg.setChirpLike(w, r, false)
+}
+
+// setChirpLike records or removes the caller's like. Liking a rechirp likes
+// the original. Both directions are idempotent: the counter only moves when
+// a likes row was actually inserted or deleted, and both changes commit
+// together so like_count stays accurate under concurrent requests. Suspended
+// users can remove likes but not add them.
+func (cfg *apiConfig) setChirpLike(w http.ResponseWriter, r *http.Request, like bool) {
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	if like && !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update like", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update like", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	chirp, err := getOriginalChirp(r.Context(), qtx, parseChirpID)
+	if errors.Is(err, errChirpDeleted) {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	if !visibility.canSee(chirp) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	likeCount := chirp.LikeCount
+	if like {
+		inserted, err := qtx.CreateLike(r.Context(), database.CreateLikeParams{
+			UserID:  userID,
+			ChirpID: chirp.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+			return
+		}
+		if inserted > 0 {
+			likeCount, err = qtx.IncrementLikeCount(r.Context(), chirp.ID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't like chirp", err)
+				return
+			}
+		}
+	} else {
+		deleted, err := qtx.DeleteLike(r.Context(), database.DeleteLikeParams{
+			UserID:  userID,
+			ChirpID: chirp.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+			return
+		}
+		if deleted > 0 {
+			likeCount, err = qtx.DecrementLikeCount(r.Context(), chirp.ID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't unlike chirp", err)
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update like", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, likeResponse{
+		ChirpID:   chirp.ID.String(),
+		LikeCount: likeCount,
+		LikedByMe: like,
+	})
+}
+
+func (cfg *apiConfig) handlerUserLikes(w http.ResponseWriter, r *http.Request) {
+	parseUserID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	chirps, err := cfg.database.ListChirpsLikedByUser(r.Context(), database.ListChirpsLikedByUserParams{
+		UserID: parseUserID,
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list likes", err)
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list likes", err)
+		return
+	}
+	chirps = visibility.filter(chirps)
+
+	response, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list likes", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/unfurl"
+)
+
+const (
+	// unfurlBatchSize is how many URLs the unfurler picks up per round.
+	unfurlBatchSize = 50
+	// unfurlConcurrency is how many pages are fetched at once.
+	unfurlConcurrency = 4
+)
+
+type linkCardResponse struct {
+	URL         string  `json:"url"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	ImageURL    *string `json:"image_url"`
+	SiteName    *string `json:"site_name"`
+}
+
+// chirpCards loads the link preview card of several chirps at once, keyed by
+// chirp ID. Chirps without a URL, or whose URLs have no preview yet, are
+// missing from the map.
+func (cfg *apiConfig) chirpCards(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*linkCardResponse, error) {
+	result := make(map[uuid.UUID]*linkCardResponse, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	previews, err := cfg.database.ListLinkPreviewsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, preview := range previews {
+		cardURL := preview.Url
+		if preview.ResolvedUrl.Valid {
+			cardURL = preview.ResolvedUrl.String
+		}
+		result[preview.ChirpID] = &linkCardResponse{
+			URL:         cardURL,
+			Title:       nullStringPtr(preview.Title),
+			Description: nullStringPtr(preview.Description),
+			ImageURL:    nullStringPtr(preview.ImageUrl),
+			SiteName:    nullStringPtr(preview.SiteName),
+		}
+	}
+	return result, nil
+}
+
+// runLinkUnfurler fetches previews for URLs in new chirps every interval.
+// Cached previews older than ttl are fetched again when a newer chirp uses
+// the URL. Several servers can run
+// it at once; at worst a page is fetched twice.
+func (cfg *apiConfig) runLinkUnfurler(ctx context.Context, fetcher *unfurl.Fetcher, interval, ttl time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.unfurlPendingURLs(ctx, fetcher, ttl); err != nil {
+			log.Printf("Error unfurling links: %v", err)
+		}
+	})
+}
+
+func (cfg *apiConfig) unfurlPendingURLs(ctx context.Context, fetcher *unfurl.Fetcher, ttl time.Duration) error {
+	urls, err := cfg.database.ListURLsToUnfurl(ctx, database.ListURLsToUnfurlParams{
+		StaleBefore: time.Now().Add(-ttl),
+		BatchSize:   unfurlBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, unfurlConcurrency)
+	for _, url := range urls {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			cfg.unfurlURL(ctx, fetcher, url)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// unfurlURL fetches one URL and caches the result. Failures are cached as
+// well, so a broken link waits out the TTL before it is tried again.
+func (cfg *apiConfig) unfurlURL(ctx context.Context, fetcher *unfurl.Fetcher, url string) {
+	params := database.UpsertLinkPreviewParams{Url: url}
+
+	// Links in chirps are often broken or not web pages at all, so failures
+	// are only recorded, not logged.
+	card, err := fetcher.Fetch(ctx, url)
+	if err != nil {
+		params.Error = sql.NullString{String: err.Error(), Valid: true}
+	} else {
+		params.ResolvedUrl = optionalString(card.URL)
+		params.Title = optionalString(card.Title)
+		params.Description = optionalString(card.Description)
+		params.ImageUrl = optionalString(card.ImageURL)
+		params.SiteName = optionalString(card.SiteName)
+	}
+
+	if err := cfg.database.UpsertLinkPreview(ctx, params); err != nil {
+		log.Printf("Error saving link preview for %s: %v", url, err)
+	}
+}
+
+func optionalString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
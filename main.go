@@ -1,23 +1,54 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/mjayio/server/internal/activitypub"
 	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/filter"
+	"github.com/mjayio/server/internal/media"
+	"github.com/mjayio/server/internal/stream"
+	"github.com/mjayio/server/internal/timeline"
+	"github.com/mjayio/server/internal/trends"
+	"github.com/mjayio/server/internal/unfurl"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	database       *database.Queries
-	platform       string
-	secret         string
-	apiKey         string
+	fileserverHits          atomic.Int32
+	db                      *sql.DB
+	database                *database.Queries
+	platform                string
+	secret                  string
+	apiKey                  string
+	chirpEditWindow         time.Duration
+	chirpRestoreWindow      time.Duration
+	timelines               timeline.Store
+	timelineFanoutThreshold int32
+	trendWindows            []trends.Window
+	trendLimit              int32
+	blobs                   media.BlobStore
+	mediaMaxBytes           int64
+	mediaOrphanTTL          time.Duration
+	contentFilter           atomic.Pointer[filter.Filter]
+	maxDrafts               int32
+	baseURL                 string
+	federation              *activitypub.Client
+	chirpStream             stream.Hub
+	streamBuffer            int32
+	streamHeartbeat         time.Duration
+	realtimePingInterval    time.Duration
+	exportTTL               time.Duration
+	exportLinkTTL           time.Duration
+	accountDeletionGrace    time.Duration
 }
 
 func main() {
@@ -33,12 +64,86 @@ func main() {
 	const filepathRoot = "."
 	const port = "8080"
 
+	timelineMaxEntries := intFromEnv("TIMELINE_MAX_ENTRIES", 800)
+	var timelines timeline.Store
+	if os.Getenv("TIMELINE_STORE") == "memory" {
+		timelines = timeline.NewMemoryStore(int(timelineMaxEntries))
+	} else {
+		timelines = timeline.NewPostgresStore(dbQueries, timelineMaxEntries)
+	}
+
+	trendWindowsSpec := os.Getenv("TRENDS_WINDOWS")
+	if trendWindowsSpec == "" {
+		trendWindowsSpec = "1h,24h"
+	}
+	trendWindows, err := trends.ParseWindows(trendWindowsSpec, floatFromEnv("TRENDS_HALF_LIFE_RATIO", 0.25))
+	if err != nil {
+		log.Fatalf("Invalid trends configuration: %v", err)
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	blobs, err := media.NewLocalStore(mediaDir)
+	if err != nil {
+		log.Fatalf("Error opening media store: %v", err)
+	}
+
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		database:       dbQueries,
-		platform:       os.Getenv("PLATFORM"),
-		secret:         os.Getenv("SECRET"),
-		apiKey:         os.Getenv("POLKA_KEY"),
+		fileserverHits:          atomic.Int32{},
+		db:                      db,
+		database:                dbQueries,
+		platform:                os.Getenv("PLATFORM"),
+		secret:                  os.Getenv("SECRET"),
+		apiKey:                  os.Getenv("POLKA_KEY"),
+		chirpEditWindow:         durationFromEnv("CHIRP_EDIT_WINDOW", 15*time.Minute),
+		chirpRestoreWindow:      durationFromEnv("CHIRP_RESTORE_WINDOW", 30*24*time.Hour),
+		timelines:               timelines,
+		timelineFanoutThreshold: intFromEnv("TIMELINE_FANOUT_THRESHOLD", 10000),
+		trendWindows:            trendWindows,
+		trendLimit:              intFromEnv("TRENDS_LIMIT", 10),
+		blobs:                   blobs,
+		mediaMaxBytes:           int64(intFromEnv("MEDIA_MAX_BYTES", 5<<20)),
+		mediaOrphanTTL:          durationFromEnv("MEDIA_ORPHAN_TTL", 24*time.Hour),
+		maxDrafts:               intFromEnv("MAX_DRAFTS", 100),
+		baseURL:                 os.Getenv("BASE_URL"),
+		streamBuffer:            intFromEnv("STREAM_BUFFER", 64),
+		streamHeartbeat:         durationFromEnv("STREAM_HEARTBEAT", 15*time.Second),
+		realtimePingInterval:    durationFromEnv("REALTIME_PING_INTERVAL", 30*time.Second),
+		exportTTL:               durationFromEnv("EXPORT_TTL", 72*time.Hour),
+		exportLinkTTL:           durationFromEnv("EXPORT_LINK_TTL", 15*time.Minute),
+		accountDeletionGrace:    durationFromEnv("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+	}
+
+	if err := apiCfg.loadContentFilter(context.Background()); err != nil {
+		log.Fatalf("Error loading content filter: %v", err)
+	}
+	go apiCfg.runContentFilterReloader(context.Background(), durationFromEnv("FILTER_RELOAD_INTERVAL", time.Minute))
+	go apiCfg.runTrendsAggregator(context.Background(), durationFromEnv("TRENDS_INTERVAL", time.Minute))
+	go apiCfg.runChirpPurger(context.Background(), durationFromEnv("CHIRP_PURGE_INTERVAL", time.Hour))
+	go apiCfg.runMediaPurger(context.Background(), durationFromEnv("MEDIA_PURGE_INTERVAL", time.Hour))
+	go apiCfg.runScheduledChirpPublisher(context.Background(), durationFromEnv("SCHEDULED_CHIRPS_INTERVAL", 15*time.Second))
+	go apiCfg.runDataExporter(context.Background(), durationFromEnv("EXPORT_INTERVAL", 30*time.Second))
+	go apiCfg.runAccountDeleter(context.Background(), durationFromEnv("ACCOUNT_DELETION_INTERVAL", 10*time.Minute))
+
+	unfurler := unfurl.New(
+		durationFromEnv("LINK_PREVIEW_TIMEOUT", 5*time.Second),
+		int64(intFromEnv("LINK_PREVIEW_MAX_BYTES", 1<<20)),
+		"ChirpyBot/1.0 (link preview)",
+	)
+	go apiCfg.runLinkUnfurler(context.Background(), unfurler, durationFromEnv("LINK_PREVIEW_INTERVAL", 5*time.Second), durationFromEnv("LINK_PREVIEW_TTL", 24*time.Hour))
+	go apiCfg.runChirpStream(context.Background(), dbURL, durationFromEnv("STREAM_RETENTION", 24*time.Hour))
+
+	if os.Getenv("FEDERATION_ENABLED") == "true" {
+		if apiCfg.baseURL == "" {
+			log.Fatal("FEDERATION_ENABLED requires BASE_URL")
+		}
+		apiCfg.federation = activitypub.NewClient(
+			durationFromEnv("FEDERATION_TIMEOUT", 10*time.Second),
+			"Chirpy/1.0 (+"+apiCfg.baseURL+")",
+		)
+		go apiCfg.runFederationDelivery(context.Background(), durationFromEnv("FEDERATION_DELIVERY_INTERVAL", 10*time.Second))
 	}
 
 	mux := http.NewServeMux()
@@ -49,22 +154,132 @@ func main() {
 
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
+	mux.HandleFunc("PUT /admin/users/{userID}/role", apiCfg.handlerAdminSetRole)
+	mux.HandleFunc("GET /admin/filter/words", apiCfg.handlerFilterWordsList)
+	mux.HandleFunc("POST /admin/filter/words", apiCfg.handlerFilterWordsCreate)
+	mux.HandleFunc("PUT /admin/filter/words/{wordID}", apiCfg.handlerFilterWordsUpdate)
+	mux.HandleFunc("DELETE /admin/filter/words/{wordID}", apiCfg.handlerFilterWordsDelete)
+	mux.HandleFunc("GET /admin/content-flags", apiCfg.handlerContentFlagsList)
+	mux.HandleFunc("POST /admin/content-flags/{flagID}/resolve", apiCfg.handlerContentFlagsResolve)
+	mux.HandleFunc("GET /admin/reports", apiCfg.handlerReportsList)
+	mux.HandleFunc("POST /admin/reports/{reportID}/actions", apiCfg.handlerReportsAction)
+	mux.HandleFunc("GET /admin/moderation/actions", apiCfg.handlerModerationLog)
+	mux.HandleFunc("GET /users/{userID}/feed.atom", apiCfg.handlerUserFeedAtom)
+	mux.HandleFunc("GET /users/{userID}/feed.rss", apiCfg.handlerUserFeedRSS)
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUserCreate)
 	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirpsCreate)
 	mux.HandleFunc("GET /api/chirps", apiCfg.handlerChirpsList)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.handlerChirpsSearch)
+	mux.HandleFunc("GET /api/chirps/deleted", apiCfg.handlerChirpsDeleted)
+	mux.HandleFunc("GET /api/chirps/scheduled", apiCfg.handlerScheduledChirpsList)
+	mux.HandleFunc("DELETE /api/chirps/scheduled", apiCfg.handlerScheduledChirpsDelete)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerChirpsRead)
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeToken)
 	mux.HandleFunc("PUT /api/users", apiCfg.handlerUserUpdateEmailPassword)
+	mux.HandleFunc("DELETE /api/users/me", apiCfg.handlerUserDelete)
+	mux.HandleFunc("POST /api/users/me/restore", apiCfg.handlerUserRestore)
+	mux.HandleFunc("POST /api/users/me/export", apiCfg.handlerDataExportCreate)
+	mux.HandleFunc("GET /api/users/me/export", apiCfg.handlerDataExportGet)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", apiCfg.handlerDataExportDownload)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerChirpsDelete)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.handlerChirpsRestore)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.handlerChirpsUpdate)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/history", apiCfg.handlerChirpsHistory)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.handlerChirpsThread)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/remote-replies", apiCfg.handlerChirpsRemoteReplies)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.handlerChirpsLike)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.handlerChirpsUnlike)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiCfg.handlerPollVotesCreate)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.handlerChirpsRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.handlerChirpsUnrechirp)
+	mux.HandleFunc("GET /api/users/{userID}/likes", apiCfg.handlerUserLikes)
+	mux.HandleFunc("GET /api/users/{userID}/mentions", apiCfg.handlerUserMentions)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.handlerUserFollow)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.handlerUserUnfollow)
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.handlerUserFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.handlerUserFollowing)
+	mux.HandleFunc("GET /api/timeline", apiCfg.handlerTimeline)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.handlerHashtagChirps)
+	mux.HandleFunc("GET /api/hashtags/{tag}/feed.atom", apiCfg.handlerHashtagFeedAtom)
+	mux.HandleFunc("GET /api/hashtags/{tag}/feed.rss", apiCfg.handlerHashtagFeedRSS)
+	mux.HandleFunc("GET /api/trends", apiCfg.handlerTrends)
+	mux.HandleFunc("GET /api/stream", apiCfg.handlerChirpStream)
+	mux.HandleFunc("GET /api/realtime", apiCfg.handlerRealtime)
+	mux.HandleFunc("POST /api/drafts", apiCfg.handlerDraftsCreate)
+	mux.HandleFunc("GET /api/drafts", apiCfg.handlerDraftsList)
+	mux.HandleFunc("GET /api/drafts/{draftID}", apiCfg.handlerDraftsGet)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.handlerDraftsUpdate)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.handlerDraftsDelete)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.handlerDraftsPublish)
+	mux.HandleFunc("POST /api/reports", apiCfg.handlerReportsCreate)
+	mux.HandleFunc("POST /api/media", apiCfg.handlerMediaUpload)
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.handlerMediaServe)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.handlerMediaThumbnail)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhooks)
 
+	if apiCfg.federation != nil {
+		mux.HandleFunc("GET /.well-known/webfinger", apiCfg.handlerWebFinger)
+		mux.HandleFunc("GET /ap/users/{userID}", apiCfg.handlerActor)
+		mux.HandleFunc("GET /ap/users/{userID}/outbox", apiCfg.handlerOutbox)
+		mux.HandleFunc("GET /ap/users/{userID}/followers", apiCfg.handlerFollowersCollection)
+		mux.HandleFunc("POST /ap/users/{userID}/inbox", apiCfg.handlerInbox)
+		mux.HandleFunc("POST /ap/inbox", apiCfg.handlerInbox)
+		mux.HandleFunc("GET /ap/chirps/{chirpID}", apiCfg.handlerNote)
+	}
+
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: apiCfg.middlewareTokenRevocation(mux),
 	}
 
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+// durationFromEnv reads a positive duration such as "15m" from the
+// environment, falling back to the given default when the variable is unset
+// or invalid.
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// intFromEnv reads a positive integer from the environment, falling back to
+// the given default when the variable is unset or invalid.
+func intFromEnv(key string, fallback int32) int32 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 1 {
+		log.Printf("Invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+	return int32(n)
+}
+
+// floatFromEnv reads a positive number from the environment, falling back to
+// the given default when the variable is unset or invalid.
+func floatFromEnv(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		log.Printf("Invalid %s %q, using %v", key, value, fallback)
+		return fallback
+	}
+	return f
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/httpcache"
+	"github.com/mjayio/server/internal/media"
+)
+
+// maxChirpMedia is how many uploads can be attached to one chirp.
+const maxChirpMedia = 4
+
+type mediaResponse struct {
+	ID           string `json:"id"`
+	CreatedAt    string `json:"created_at"`
+	ContentType  string `json:"content_type"`
+	Width        int32  `json:"width"`
+	Height       int32  `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+func newMediaResponse(file database.MediaFile) mediaResponse {
+	return mediaResponse{
+		ID:           file.ID.String(),
+		CreatedAt:    file.CreatedAt.String(),
+		ContentType:  file.ContentType,
+		Width:        file.Width,
+		Height:       file.Height,
+		URL:          "/api/media/" + file.ID.String(),
+		ThumbnailURL: "/api/media/" + file.ID.String() + "/thumbnail",
+	}
+}
+
+func mediaKey(id uuid.UUID) string {
+	return id.String()
+}
+
+func thumbnailKey(id uuid.UUID) string {
+	return id.String() + "-thumb"
+}
+
+func (cfg *apiConfig) handlerMediaUpload(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.mediaMaxBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", err)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Missing file upload", err)
+		return
+	}
+	defer file.Close()
+
+	img, err := media.Process(file, cfg.mediaMaxBytes)
+	if err != nil {
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			respondWithError(w, http.StatusRequestEntityTooLarge, "File is too large", err)
+		case errors.Is(err, media.ErrUnsupportedType):
+			respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported", err)
+		case errors.Is(err, media.ErrInvalidImage), errors.Is(err, media.ErrTooManyPixels):
+			respondWithError(w, http.StatusBadRequest, "Invalid image", err)
+		default:
+			respondWithError(w, http.StatusInternalServerError, "Couldn't process image", err)
+		}
+		return
+	}
+
+	id := uuid.New()
+	if err := cfg.blobs.Put(r.Context(), mediaKey(id), bytes.NewReader(img.Data)); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store image", err)
+		return
+	}
+	if err := cfg.blobs.Put(r.Context(), thumbnailKey(id), bytes.NewReader(img.Thumbnail)); err != nil {
+		cfg.deleteMediaBlobs(id)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store image", err)
+		return
+	}
+
+	mediaFile, err := cfg.database.CreateMediaFile(r.Context(), database.CreateMediaFileParams{
+		ID:                   id,
+		UserID:               userID,
+		ContentType:          img.ContentType,
+		SizeBytes:            int32(len(img.Data)),
+		Width:                int32(img.Width),
+		Height:               int32(img.Height),
+		ThumbnailContentType: img.ThumbnailContentType,
+	})
+	if err != nil {
+		cfg.deleteMediaBlobs(id)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save media", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newMediaResponse(mediaFile))
+}
+
+func (cfg *apiConfig) deleteMediaBlobs(id uuid.UUID) {
+	for _, key := range []string{mediaKey(id), thumbnailKey(id)} {
+		if err := cfg.blobs.Delete(context.Background(), key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+func (cfg *apiConfig) handlerMediaServe(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, false)
+}
+
+func (cfg *apiConfig) handlerMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, true)
+}
+
+// serveMedia streams an upload or its thumbnail. Anyone can fetch media on a
+// published chirp that anyone can see, and since blobs never change once
+// written, those are cached forever and revalidated by ID alone. Media that
+// isn't on such a chirp goes only to the uploader and whoever can see the
+// chirp, and is never kept by shared caches.
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid media ID", err)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	mediaFile, err := cfg.database.GetMediaFile(r.Context(), mediaID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Media not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve media", err)
+		return
+	}
+
+	chirp, err := cfg.database.GetChirpForMedia(r.Context(), mediaID)
+	attached := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve media", err)
+		return
+	}
+
+	public := attached && !chirp.HiddenAt.Valid && !chirp.DeletedAt.Valid
+	if !public && !(viewerID.Valid && viewerID.UUID == mediaFile.UserID) {
+		visible := false
+		if attached {
+			visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve media", err)
+				return
+			}
+			visible = visibility.canSee(chirp)
+		}
+		if !visible {
+			respondWithError(w, http.StatusNotFound, "Media not found", nil)
+			return
+		}
+	}
+
+	key, contentType := mediaKey(mediaID), mediaFile.ContentType
+	if thumbnail {
+		key, contentType = thumbnailKey(mediaID), mediaFile.ThumbnailContentType
+	}
+
+	blob, err := cfg.blobs.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, media.ErrNotFound) {
+			respondWithError(w, http.StatusNotFound, "Media not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve media", err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	if public {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", httpcache.Revalidate)
+		w.Header().Add("Vary", "Authorization")
+	}
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent handles conditional and range requests when the store can
+	// seek, as files on local disk can.
+	if seeker, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", mediaFile.CreatedAt, seeker)
+		return
+	}
+	if r.Header.Get("If-None-Match") == `"`+key+`"` {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// orphanedMediaBatch is how many orphaned uploads runMediaPurger deletes per
+// statement.
+const orphanedMediaBatch = 100
+
+// runMediaPurger deletes uploads that are older than cfg.mediaOrphanTTL and
+// not attached to any chirp, draft or scheduled chirp, checking every
+// interval. That covers uploads that were never used, and attachments of
+// chirps that have been purged.
+func (cfg *apiConfig) runMediaPurger(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		cutoff := time.Now().Add(-cfg.mediaOrphanTTL)
+		for {
+			ids, err := cfg.database.DeleteOrphanedMediaFiles(ctx, database.DeleteOrphanedMediaFilesParams{
+				CreatedBefore: cutoff,
+				BatchSize:     orphanedMediaBatch,
+			})
+			if err != nil {
+				log.Printf("Error purging orphaned media: %v", err)
+				return
+			}
+			for _, id := range ids {
+				cfg.deleteMediaBlobs(id)
+			}
+			if len(ids) > 0 {
+				log.Printf("Purged %d orphaned uploads", len(ids))
+			}
+			if len(ids) < orphanedMediaBatch {
+				return
+			}
+		}
+	})
+}
+
+// chirpMedia loads the attachments of several chirps at once, keyed by chirp
+// ID and in attachment order.
+func (cfg *apiConfig) chirpMedia(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]mediaResponse, error) {
+	result := make(map[uuid.UUID][]mediaResponse, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	rows, err := cfg.database.ListAttachmentsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.ChirpID] = append(result[row.ChirpID], newMediaResponse(database.MediaFile{
+			ID:                   row.ID,
+			CreatedAt:            row.CreatedAt,
+			UserID:               row.UserID,
+			ContentType:          row.ContentType,
+			SizeBytes:            row.SizeBytes,
+			Width:                row.Width,
+			Height:               row.Height,
+			ThumbnailContentType: row.ThumbnailContentType,
+		}))
+	}
+	return result, nil
+}
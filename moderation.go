@@ -0,0 +1,564 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+var reportReasons = []string{
+	"spam", "harassment", "hate", "violence", "sexual", "self_harm",
+	"misinformation", "impersonation", "other",
+}
+
+const (
+	moderationDismiss     = "dismiss"
+	moderationHideChirp   = "hide_chirp"
+	moderationDeleteChirp = "delete_chirp"
+	moderationWarnUser    = "warn_user"
+	moderationSuspendUser = "suspend_user"
+)
+
+const maxReportDetailsLength = 1000
+
+type reportResponse struct {
+	ID         string  `json:"id"`
+	CreatedAt  string  `json:"created_at"`
+	ReporterID string  `json:"reporter_id"`
+	UserID     string  `json:"user_id"`
+	ChirpID    *string `json:"chirp_id"`
+	ChirpBody  *string `json:"chirp_body"`
+	Reason     string  `json:"reason"`
+	Details    string  `json:"details"`
+	Status     string  `json:"status"`
+	ResolvedAt *string `json:"resolved_at"`
+	ResolvedBy *string `json:"resolved_by"`
+	Resolution *string `json:"resolution"`
+}
+
+func newReportResponse(report database.Report) reportResponse {
+	return reportResponse{
+		ID:         report.ID.String(),
+		CreatedAt:  report.CreatedAt.String(),
+		ReporterID: report.ReporterID.String(),
+		UserID:     report.UserID.String(),
+		ChirpID:    nullUUIDString(report.ChirpID),
+		ChirpBody:  nullStringPtr(report.ChirpBody),
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+		ResolvedAt: nullTimeString(report.ResolvedAt),
+		ResolvedBy: nullUUIDString(report.ResolvedBy),
+		Resolution: nullStringPtr(report.Resolution),
+	}
+}
+
+type moderationActionResponse struct {
+	ID             string  `json:"id"`
+	CreatedAt      string  `json:"created_at"`
+	ModeratorID    *string `json:"moderator_id"`
+	ReportID       *string `json:"report_id"`
+	Action         string  `json:"action"`
+	TargetUserID   string  `json:"target_user_id"`
+	TargetChirpID  *string `json:"target_chirp_id"`
+	Note           string  `json:"note"`
+	SuspendedUntil *string `json:"suspended_until"`
+}
+
+func newModerationActionResponse(action database.ModerationAction) moderationActionResponse {
+	return moderationActionResponse{
+		ID:             action.ID.String(),
+		CreatedAt:      action.CreatedAt.String(),
+		ModeratorID:    nullUUIDString(action.ModeratorID),
+		ReportID:       nullUUIDString(action.ReportID),
+		Action:         action.Action,
+		TargetUserID:   action.TargetUserID.String(),
+		TargetChirpID:  nullUUIDString(action.TargetChirpID),
+		Note:           action.Note,
+		SuspendedUntil: nullTimeString(action.SuspendedUntil),
+	}
+}
+
+func nullTimeString(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	s := t.Time.String()
+	return &s
+}
+
+// chirpVisibility decides which hidden chirps a viewer may see: their own,
+// or all of them for moderators and admins. Deleted chirps are visible to
+// no one.
+type chirpVisibility struct {
+	viewerID  uuid.NullUUID
+	moderator bool
+}
+
+func (cfg *apiConfig) chirpVisibilityFor(ctx context.Context, viewerID uuid.NullUUID) (chirpVisibility, error) {
+	visibility := chirpVisibility{viewerID: viewerID}
+	if !viewerID.Valid {
+		return visibility, nil
+	}
+
+	role, err := cfg.database.GetUserRole(ctx, viewerID.UUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return chirpVisibility{}, err
+	}
+	visibility.moderator = role == roleModerator || role == roleAdmin
+	return visibility, nil
+}
+
+func (v chirpVisibility) canSee(chirp database.Chirp) bool {
+	if chirp.DeletedAt.Valid {
+		return false
+	}
+	if !chirp.HiddenAt.Valid || v.moderator {
+		return true
+	}
+	return v.viewerID.Valid && v.viewerID.UUID == chirp.UserID
+}
+
+// filter drops the chirps the viewer can't see. Pages filtered this way may
+// come back short, but cursors still point past the whole page.
+func (v chirpVisibility) filter(chirps []database.Chirp) []database.Chirp {
+	return slices.DeleteFunc(chirps, func(chirp database.Chirp) bool {
+		return !v.canSee(chirp)
+	})
+}
+
+// requireNotSuspended checks that the user may post. On failure it writes
+// the error response and returns false.
+func (cfg *apiConfig) requireNotSuspended(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	suspendedUntil, err := cfg.database.GetUserSuspension(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+			return false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check account status", err)
+		return false
+	}
+
+	if suspendedUntil.Valid && suspendedUntil.Time.After(time.Now()) {
+		msg := fmt.Sprintf("Your account is suspended until %s", suspendedUntil.Time.UTC().Format(time.RFC3339))
+		respondWithError(w, http.StatusForbidden, msg, nil)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) handlerReportsCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChirpID *uuid.UUID `json:"chirp_id"`
+		UserID  *uuid.UUID `json:"user_id"`
+		Reason  string     `json:"reason"`
+		Details string     `json:"details"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	reporterID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, "Invalid report reason", nil)
+		return
+	}
+
+	if len(params.Details) > maxReportDetailsLength {
+		respondWithError(w, http.StatusBadRequest, "Report details are too long", nil)
+		return
+	}
+
+	createParams := database.CreateReportParams{
+		ReporterID: reporterID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	}
+
+	switch {
+	case params.ChirpID != nil && params.UserID == nil:
+		chirp, err := cfg.database.GetChirp(r.Context(), *params.ChirpID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+			return
+		}
+		if chirp.DeletedAt.Valid {
+			respondWithError(w, http.StatusGone, "Chirp has been deleted", nil)
+			return
+		}
+		createParams.UserID = chirp.UserID
+		createParams.ChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		createParams.ChirpBody = sql.NullString{String: chirp.Body, Valid: true}
+	case params.UserID != nil && params.ChirpID == nil:
+		createParams.UserID = *params.UserID
+	default:
+		respondWithError(w, http.StatusBadRequest, "Report either a chirp_id or a user_id", nil)
+		return
+	}
+
+	if createParams.UserID == reporterID {
+		respondWithError(w, http.StatusBadRequest, "You can't report yourself", nil)
+		return
+	}
+
+	report, err := cfg.database.CreateReport(r.Context(), createParams)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, http.StatusConflict, "You have already reported this", err)
+			return
+		}
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newReportResponse(report))
+}
+
+func (cfg *apiConfig) handlerReportsList(w http.ResponseWriter, r *http.Request) {
+	type queueResponse struct {
+		reportResponse
+		Chirp *chirpResponse `json:"chirp"`
+	}
+
+	type returnVals struct {
+		Reports    []queueResponse `json:"reports"`
+		NextOffset *int32          `json:"next_offset"`
+	}
+
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	if status != "open" && status != "resolved" {
+		respondWithError(w, http.StatusBadRequest, "Status must be open or resolved", nil)
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	reports, err := cfg.database.ListReports(r.Context(), database.ListReportsParams{
+		Status: status,
+		Limit:  limit + 1,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list reports", err)
+		return
+	}
+
+	var nextOffset *int32
+	if int32(len(reports)) > limit {
+		reports = reports[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	// Attach the reported chirps as they are now, if they still exist, so
+	// moderators can compare them with the body at the time of the report.
+	var chirpIDs []uuid.UUID
+	for _, report := range reports {
+		if report.ChirpID.Valid {
+			chirpIDs = append(chirpIDs, report.ChirpID.UUID)
+		}
+	}
+	chirps := map[uuid.UUID]chirpResponse{}
+	if len(chirpIDs) > 0 {
+		found, err := cfg.database.GetChirpsByIDs(r.Context(), chirpIDs)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't list reports", err)
+			return
+		}
+		converted, err := cfg.chirpResponses(r.Context(), found, uuid.NullUUID{UUID: moderatorID, Valid: true})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't list reports", err)
+			return
+		}
+		for i, chirp := range found {
+			chirps[chirp.ID] = converted[i]
+		}
+	}
+
+	response := returnVals{
+		Reports:    make([]queueResponse, len(reports)),
+		NextOffset: nextOffset,
+	}
+	for i, report := range reports {
+		response.Reports[i] = queueResponse{reportResponse: newReportResponse(report)}
+		if chirp, ok := chirps[report.ChirpID.UUID]; ok && report.ChirpID.Valid {
+			response.Reports[i].Chirp = &chirp
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerReportsAction(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action   string `json:"action"`
+		Note     string `json:"note"`
+		Duration string `json:"duration"`
+	}
+
+	moderatorID, ok := cfg.requireRole(w, r, roleModerator, roleAdmin)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	var suspendedUntil sql.NullTime
+	switch params.Action {
+	case moderationDismiss, moderationHideChirp, moderationDeleteChirp, moderationWarnUser:
+	case moderationSuspendUser:
+		duration, err := time.ParseDuration(params.Duration)
+		if err != nil || duration <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Suspensions need a positive duration such as \"72h\"", err)
+			return
+		}
+		suspendedUntil = sql.NullTime{Time: time.Now().Add(duration), Valid: true}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Action must be dismiss, hide_chirp, delete_chirp, warn_user or suspend_user", nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	report, err := qtx.GetReportForUpdate(r.Context(), reportID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Report not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+		return
+	}
+
+	if report.Status != "open" {
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	var deleted *database.Chirp
+	switch params.Action {
+	case moderationHideChirp, moderationDeleteChirp:
+		if !report.ChirpID.Valid {
+			respondWithError(w, http.StatusBadRequest, "Report is not about a chirp", nil)
+			return
+		}
+		chirp, err := qtx.GetChirp(r.Context(), report.ChirpID.UUID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusNotFound, "Chirp no longer exists", err)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+			return
+		}
+
+		// To anyone watching the stream, a hidden chirp is as good as gone.
+		// A chirp that is already hidden or deleted has had its event.
+		if !chirp.HiddenAt.Valid && !chirp.DeletedAt.Valid {
+			err = recordChirpEvent(r.Context(), qtx, chirpDeletedEvent, chirp.ID)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+				return
+			}
+		}
+
+		if params.Action == moderationHideChirp {
+			err = qtx.HideChirp(r.Context(), chirp.ID)
+		} else {
+			err = qtx.DeleteChirp(r.Context(), chirp.ID)
+			// Soft deleting the chirp already undid its counters and took
+			// it out of timelines and other servers.
+			if err == nil && !chirp.DeletedAt.Valid {
+				err = decrementParentCounts(r.Context(), qtx, chirp)
+				deleted = &chirp
+			}
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+			return
+		}
+	case moderationSuspendUser:
+		err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+			SuspendedUntil: suspendedUntil,
+			ID:             report.UserID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+			return
+		}
+	}
+
+	action, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID:    uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ReportID:       uuid.NullUUID{UUID: report.ID, Valid: true},
+		Action:         params.Action,
+		TargetUserID:   report.UserID,
+		TargetChirpID:  report.ChirpID,
+		Note:           params.Note,
+		SuspendedUntil: suspendedUntil,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+		return
+	}
+
+	resolveParams := database.ResolveReportParams{
+		ResolvedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		Resolution: sql.NullString{String: params.Action, Valid: true},
+		ID:         report.ID,
+	}
+	resolved, err := qtx.ResolveReport(r.Context(), resolveParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+		return
+	}
+	if resolved == 0 {
+		respondWithError(w, http.StatusConflict, "Report is already resolved", nil)
+		return
+	}
+
+	// Once a chirp is hidden or deleted, other open reports about it are
+	// settled by the same decision.
+	if params.Action == moderationHideChirp || params.Action == moderationDeleteChirp {
+		err = qtx.ResolveChirpReports(r.Context(), database.ResolveChirpReportsParams{
+			ResolvedBy: resolveParams.ResolvedBy,
+			Resolution: resolveParams.Resolution,
+			ChirpID:    report.ChirpID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply action", err)
+		return
+	}
+
+	if deleted != nil {
+		err = cfg.timelines.RemoveChirp(r.Context(), deleted.ID)
+		if err != nil {
+			log.Printf("Error removing chirp %s from timelines: %v", deleted.ID, err)
+		}
+		go cfg.federateChirpDeletion(context.Background(), *deleted)
+	}
+
+	respondWithJSON(w, http.StatusCreated, newModerationActionResponse(action))
+}
+
+func (cfg *apiConfig) handlerModerationLog(w http.ResponseWriter, r *http.Request) {
+	type returnVals struct {
+		Actions    []moderationActionResponse `json:"actions"`
+		NextOffset *int32                     `json:"next_offset"`
+	}
+
+	if _, ok := cfg.requireRole(w, r, roleModerator, roleAdmin); !ok {
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	params := database.ListModerationActionsParams{
+		PageSize:   limit + 1,
+		PageOffset: offset,
+	}
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		userID, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+		params.TargetUserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	actions, err := cfg.database.ListModerationActions(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list moderation actions", err)
+		return
+	}
+
+	var nextOffset *int32
+	if int32(len(actions)) > limit {
+		actions = actions[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	response := returnVals{
+		Actions:    make([]moderationActionResponse, len(actions)),
+		NextOffset: nextOffset,
+	}
+	for i, action := range actions {
+		response.Actions[i] = newModerationActionResponse(action)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
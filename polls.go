@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/text"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+type pollInput struct {
+	Options []string `json:"options"`
+	// Duration is how long the poll stays open, such as "24h".
+	Duration string `json:"duration"`
+}
+
+type pollOptionResponse struct {
+	Position int32  `json:"position"`
+	Text     string `json:"text"`
+	// Votes is null until the viewer has voted or the poll has closed.
+	Votes *int32 `json:"votes"`
+}
+
+type pollResponse struct {
+	ClosesAt   string               `json:"closes_at"`
+	Closed     bool                 `json:"closed"`
+	TotalVotes int32                `json:"total_votes"`
+	Options    []pollOptionResponse `json:"options"`
+	MyVote     *int32               `json:"my_vote"`
+}
+
+// cleanPollInput checks a new poll and returns its normalized options and
+// closing time. Options go through the content filter like chirp bodies. On
+// failure it writes the error response and returns false.
+func (cfg *apiConfig) cleanPollInput(w http.ResponseWriter, input pollInput) ([]string, time.Time, bool) {
+	if len(input.Options) < minPollOptions || len(input.Options) > maxPollOptions {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("A poll needs %d to %d options", minPollOptions, maxPollOptions), nil)
+		return nil, time.Time{}, false
+	}
+
+	duration, err := time.ParseDuration(input.Duration)
+	if err != nil || duration < minPollDuration || duration > maxPollDuration {
+		respondWithError(w, http.StatusBadRequest, "Polls need a duration between 5m and 168h", err)
+		return nil, time.Time{}, false
+	}
+
+	options := make([]string, len(input.Options))
+	seen := make(map[string]bool, len(input.Options))
+	for i, option := range input.Options {
+		processed := text.Process(strings.TrimSpace(option))
+		if processed.Text == "" || processed.Length > maxPollOptionLength {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Poll options must be 1 to %d characters", maxPollOptionLength), nil)
+			return nil, time.Time{}, false
+		}
+
+		filtered := cfg.contentFilter.Load().Apply(processed.Text)
+		if filtered.Rejected {
+			respondWithError(w, http.StatusBadRequest, "Poll option contains a blocked word", nil)
+			return nil, time.Time{}, false
+		}
+
+		key := strings.ToLower(filtered.Text)
+		if seen[key] {
+			respondWithError(w, http.StatusBadRequest, "Poll options must be different", nil)
+			return nil, time.Time{}, false
+		}
+		seen[key] = true
+		options[i] = filtered.Text
+	}
+
+	return options, time.Now().Add(duration), true
+}
+
+// createPoll attaches a poll to a chirp that was just created with q.
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, options []string, closesAt time.Time) error {
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: closesAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, option := range options {
+		err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int32(i),
+			Text:     option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chirpPolls loads the polls of several chirps at once, keyed by chirp ID,
+// as the viewer should see them. Vote counts are left out until the viewer
+// has voted or the poll has closed.
+func (cfg *apiConfig) chirpPolls(ctx context.Context, ids []uuid.UUID, viewerID uuid.NullUUID) (map[uuid.UUID]*pollResponse, error) {
+	result := map[uuid.UUID]*pollResponse{}
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	options, err := cfg.database.ListPollOptionsForChirps(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(options) == 0 {
+		return result, nil
+	}
+
+	myVotes := map[uuid.UUID]int32{}
+	if viewerID.Valid {
+		votes, err := cfg.database.ListPollVotesByUser(ctx, database.ListPollVotesByUserParams{
+			UserID:   viewerID.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			myVotes[vote.ChirpID] = vote.Position
+		}
+	}
+
+	now := time.Now()
+	for _, option := range options {
+		poll, ok := result[option.ChirpID]
+		if !ok {
+			poll = &pollResponse{
+				ClosesAt: option.ClosesAt.String(),
+				Closed:   !option.ClosesAt.After(now),
+				Options:  []pollOptionResponse{},
+			}
+			if position, voted := myVotes[option.ChirpID]; voted {
+				poll.MyVote = &position
+			}
+			result[option.ChirpID] = poll
+		}
+
+		poll.TotalVotes += option.VoteCount
+		optionResponse := pollOptionResponse{
+			Position: option.Position,
+			Text:     option.Text,
+		}
+		if poll.Closed || poll.MyVote != nil {
+			votes := option.VoteCount
+			optionResponse.Votes = &votes
+		}
+		poll.Options = append(poll.Options, optionResponse)
+	}
+	return result, nil
+}
+
+func (cfg *apiConfig) handlerPollVotesCreate(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Option *int32 `json:"option"`
+	}
+
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Option == nil {
+		respondWithError(w, http.StatusBadRequest, "Missing option", nil)
+		return
+	}
+
+	if !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	// Voting through a rechirp votes in the original's poll.
+	chirp, err := getOriginalChirp(r.Context(), qtx, parseChirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+			return
+		}
+		if errors.Is(err, errChirpDeleted) {
+			respondWithError(w, http.StatusGone, "Chirp has been deleted", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+	if !visibility.canSee(chirp) {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	poll, err := qtx.GetPoll(r.Context(), chirp.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Chirp has no poll", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+
+	if !poll.ClosesAt.After(time.Now()) {
+		respondWithError(w, http.StatusConflict, "Poll is closed", nil)
+		return
+	}
+
+	inserted, err := qtx.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		ChirpID:  chirp.ID,
+		UserID:   userID,
+		Position: *params.Option,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			respondWithError(w, http.StatusBadRequest, "Invalid option", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+	if inserted == 0 {
+		respondWithError(w, http.StatusConflict, "You have already voted", nil)
+		return
+	}
+
+	err = qtx.IncrementPollOptionVotes(r.Context(), database.IncrementPollOptionVotesParams{
+		ChirpID:  chirp.ID,
+		Position: *params.Option,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't record vote", err)
+		return
+	}
+
+	polls, err := cfg.chirpPolls(r.Context(), []uuid.UUID{chirp.ID}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load poll", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, polls[chirp.ID])
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/realtime"
+	"github.com/mjayio/server/internal/stream"
+)
+
+const (
+	typingEvent   = "typing"
+	presenceEvent = "presence"
+	// tokensRevokedEvent tells every instance to close the connections of a
+	// user whose tokens have been revoked. It never reaches clients.
+	tokensRevokedEvent = "tokens.revoked"
+
+	// realtimeEventsChannel is the Postgres channel ephemeral events are
+	// announced on.
+	realtimeEventsChannel = "realtime_events"
+	// maxRealtimeSubscriptions caps the channels one connection may follow.
+	maxRealtimeSubscriptions = 20
+	// typingInterval is the shortest time between typing events a client
+	// sends to the same thread; more frequent ones are ignored.
+	typingInterval = 2 * time.Second
+)
+
+// realtimeNotification is an ephemeral event as it travels between server
+// instances.
+type realtimeNotification struct {
+	Type     string    `json:"type"`
+	ThreadID uuid.UUID `json:"thread_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Status   string    `json:"status,omitempty"`
+}
+
+// publishRealtimeEvent announces an ephemeral event to every server
+// instance, including this one.
+func (cfg *apiConfig) publishRealtimeEvent(ctx context.Context, n realtimeNotification) {
+	payload, err := json.Marshal(n)
+	if err == nil {
+		err = cfg.database.NotifyRealtimeEvent(ctx, string(payload))
+	}
+	if err != nil {
+		log.Printf("Error publishing %s event: %v", n.Type, err)
+	}
+}
+
+// receiveRealtimeEvent publishes an announced ephemeral event to this
+// instance's subscribers.
+func (cfg *apiConfig) receiveRealtimeEvent(payload string) {
+	var n realtimeNotification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		log.Printf("Invalid realtime event %q: %v", payload, err)
+		return
+	}
+	data, err := json.Marshal(struct {
+		UserID string `json:"user_id"`
+		Status string `json:"status,omitempty"`
+	}{n.UserID.String(), n.Status})
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", n.Type, err)
+		return
+	}
+	cfg.chirpStream.Publish(stream.Event{
+		Type:     n.Type,
+		AuthorID: n.UserID,
+		ThreadID: n.ThreadID,
+		Data:     data,
+	})
+}
+
+// clientError is a problem with a client's message, reported back to it.
+type clientError string
+
+func (e clientError) Error() string {
+	return string(e)
+}
+
+func isEphemeralEvent(eventType string) bool {
+	return eventType == typingEvent || eventType == presenceEvent
+}
+
+// realtimeSession is one user's WebSocket connection and the channels it
+// follows. Its methods run on the connection's read loop, one at a time.
+type realtimeSession struct {
+	cfg        *apiConfig
+	conn       *realtime.Conn
+	userID     uuid.UUID
+	subs       map[string]realtimeSubscription
+	lastTyping map[string]time.Time
+}
+
+type realtimeSubscription struct {
+	sub      *stream.Subscription
+	threadID uuid.NullUUID
+}
+
+func (cfg *apiConfig) handlerRealtime(w http.ResponseWriter, r *http.Request) {
+	// Browsers can't set headers on WebSocket requests, so the token may
+	// also be passed in the query string.
+	token := r.URL.Query().Get("access_token")
+	if token == "" {
+		var err error
+		token, err = auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+			return
+		}
+	}
+
+	userID, expiresAt, err := auth.ValidateJWTExpiry(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	conn, err := realtime.Upgrade(w, r, realtime.Options{
+		Buffer:       int(cfg.streamBuffer),
+		PingInterval: cfg.realtimePingInterval,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		// The upgrader has already responded.
+		return
+	}
+
+	// Connections outlive the request that authenticated them, so they are
+	// closed here when the user's tokens are revoked.
+	revoked := cfg.chirpStream.Subscribe(stream.Filter{
+		Types:   []string{tokensRevokedEvent},
+		Authors: []uuid.UUID{userID},
+	}, 1)
+	defer revoked.Close()
+	go func() {
+		select {
+		case <-revoked.Events():
+			conn.Close(realtime.CloseExpired, "token revoked")
+		case <-conn.Done():
+		}
+	}()
+
+	s := &realtimeSession{
+		cfg:        cfg,
+		conn:       conn,
+		userID:     userID,
+		subs:       make(map[string]realtimeSubscription),
+		lastTyping: make(map[string]time.Time),
+	}
+	conn.Run(func(msg realtime.Message) {
+		s.handle(r.Context(), msg)
+	})
+	for name := range s.subs {
+		s.unsubscribe(context.Background(), name)
+	}
+}
+
+func (s *realtimeSession) handle(ctx context.Context, msg realtime.Message) {
+	var err error
+	switch msg.Type {
+	case "subscribe":
+		err = s.subscribe(ctx, msg.Channel)
+	case "unsubscribe":
+		if _, ok := s.subs[msg.Channel]; !ok {
+			err = clientError("Not subscribed to " + msg.Channel)
+			break
+		}
+		s.unsubscribe(ctx, msg.Channel)
+		err = s.conn.Send(realtime.Message{Type: "unsubscribed", Channel: msg.Channel})
+	case typingEvent:
+		err = s.typing(ctx, msg.Channel)
+	default:
+		err = clientError("Unknown message type " + msg.Type)
+	}
+	if err != nil && !errors.Is(err, realtime.ErrClosed) {
+		s.conn.Send(realtime.Message{Type: "error", Channel: msg.Channel, Error: err.Error()})
+	}
+}
+
+// subscribe starts following a channel. Timelines follow the people the
+// user follows at the moment they subscribe.
+func (s *realtimeSession) subscribe(ctx context.Context, name string) error {
+	channel, err := realtime.ParseChannel(name)
+	if err != nil {
+		return clientError("Unknown channel " + name)
+	}
+	if _, ok := s.subs[name]; ok {
+		return s.conn.Send(realtime.Message{Type: "subscribed", Channel: name})
+	}
+	if len(s.subs) >= maxRealtimeSubscriptions {
+		return clientError("Too many subscriptions")
+	}
+
+	chirpEvents := []string{chirpCreatedEvent, chirpDeletedEvent}
+	var filter stream.Filter
+	var threadID uuid.NullUUID
+	switch channel.Kind {
+	case realtime.Timeline:
+		followees, err := s.cfg.database.ListFolloweeIDs(ctx, s.userID)
+		if err != nil {
+			log.Printf("Error subscribing to %s: %v", name, err)
+			return clientError("Couldn't subscribe")
+		}
+		filter = stream.Filter{Types: chirpEvents, Authors: append(followees, s.userID)}
+	case realtime.Mentions:
+		filter = stream.Filter{Types: chirpEvents, Mentions: []uuid.UUID{s.userID}}
+	case realtime.Thread:
+		root, err := s.threadRoot(ctx, channel.ChirpID)
+		if err != nil {
+			return err
+		}
+		threadID = uuid.NullUUID{UUID: root, Valid: true}
+		filter = stream.Filter{Threads: []uuid.UUID{root}}
+	}
+
+	sub := s.cfg.chirpStream.Subscribe(filter, int(s.cfg.streamBuffer))
+	s.subs[name] = realtimeSubscription{sub: sub, threadID: threadID}
+	go s.forward(name, sub)
+
+	if threadID.Valid {
+		s.cfg.publishRealtimeEvent(ctx, realtimeNotification{
+			Type:     presenceEvent,
+			ThreadID: threadID.UUID,
+			UserID:   s.userID,
+			Status:   "joined",
+		})
+	}
+	return s.conn.Send(realtime.Message{Type: "subscribed", Channel: name})
+}
+
+// threadRoot returns the root of the conversation a chirp belongs to, if the
+// user can see the chirp.
+func (s *realtimeSession) threadRoot(ctx context.Context, chirpID uuid.UUID) (uuid.UUID, error) {
+	chirp, err := s.cfg.database.GetChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, clientError("Chirp not found")
+	}
+	if err != nil {
+		log.Printf("Error loading chirp %s: %v", chirpID, err)
+		return uuid.Nil, clientError("Couldn't subscribe")
+	}
+	visibility, err := s.cfg.chirpVisibilityFor(ctx, uuid.NullUUID{UUID: s.userID, Valid: true})
+	if err != nil {
+		log.Printf("Error loading chirp %s: %v", chirpID, err)
+		return uuid.Nil, clientError("Couldn't subscribe")
+	}
+	if !visibility.canSee(chirp) {
+		return uuid.Nil, clientError("Chirp not found")
+	}
+	if chirp.RootID.Valid {
+		return chirp.RootID.UUID, nil
+	}
+	return chirp.ID, nil
+}
+
+func (s *realtimeSession) unsubscribe(ctx context.Context, name string) {
+	subscription := s.subs[name]
+	subscription.sub.Close()
+	delete(s.subs, name)
+	delete(s.lastTyping, name)
+
+	if subscription.threadID.Valid {
+		s.cfg.publishRealtimeEvent(ctx, realtimeNotification{
+			Type:     presenceEvent,
+			ThreadID: subscription.threadID.UUID,
+			UserID:   s.userID,
+			Status:   "left",
+		})
+	}
+}
+
+func (s *realtimeSession) typing(ctx context.Context, name string) error {
+	subscription, ok := s.subs[name]
+	if !ok || !subscription.threadID.Valid {
+		return clientError("Typing events need a subscribed thread channel")
+	}
+	if time.Since(s.lastTyping[name]) < typingInterval {
+		return nil
+	}
+	s.lastTyping[name] = time.Now()
+	s.cfg.publishRealtimeEvent(ctx, realtimeNotification{
+		Type:     typingEvent,
+		ThreadID: subscription.threadID.UUID,
+		UserID:   s.userID,
+	})
+	return nil
+}
+
+// forward passes a channel's events to the client. Ephemeral events are
+// dropped when the connection can't keep up, but a connection that can't
+// take a chirp event is closed so the client knows to reload.
+func (s *realtimeSession) forward(name string, sub *stream.Subscription) {
+	for event := range sub.Events() {
+		ephemeral := isEphemeralEvent(event.Type)
+		if ephemeral && event.AuthorID == s.userID {
+			continue
+		}
+		sent := s.conn.TrySend(realtime.Message{
+			Type:    event.Type,
+			Channel: name,
+			Data:    event.Data,
+		})
+		if !sent && !ephemeral {
+			s.conn.Close(realtime.CloseTryAgain, "connection too slow")
+			return
+		}
+	}
+	if sub.Lagged() {
+		s.conn.Close(realtime.CloseTryAgain, "connection too slow")
+	}
+}
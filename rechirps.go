@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+func (cfg *apiConfig) handlerChirpsRechirp(w http.ResponseWriter, r *http.Request) {
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	if !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	original, err := getOriginalChirp(r.Context(), qtx, parseChirpID)
+	if errors.Is(err, errChirpDeleted) {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+	originalID := uuid.NullUUID{UUID: original.ID, Valid: true}
+
+	// Rechirping twice is a no-op that returns the existing rechirp.
+	existing, err := qtx.GetRechirp(r.Context(), database.GetRechirpParams{
+		UserID:      userID,
+		RechirpOfID: originalID,
+	})
+	if err == nil {
+		response, err := cfg.chirpResponseFor(r.Context(), existing, viewerID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, response)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	rechirp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		UserID:      userID,
+		RechirpOfID: originalID,
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, http.StatusConflict, "Chirp already rechirped", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	err = qtx.IncrementRechirpCount(r.Context(), original.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	err = recordChirpEvent(r.Context(), qtx, chirpCreatedEvent, rechirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	go cfg.fanOutChirp(context.Background(), rechirp)
+
+	response, err := cfg.chirpResponseFor(r.Context(), rechirp, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rechirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func (cfg *apiConfig) handlerChirpsUnrechirp(w http.ResponseWriter, r *http.Request) {
+	parseChirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove rechirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	original, err := getOriginalChirp(r.Context(), qtx, parseChirpID)
+	if errors.Is(err, errChirpDeleted) {
+		respondWithError(w, http.StatusGone, "Chirp has been deleted", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	rechirp, err := qtx.GetRechirp(r.Context(), database.GetRechirpParams{
+		UserID:      userID,
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Rechirp not found", err)
+		return
+	}
+
+	err = recordChirpEvent(r.Context(), qtx, chirpDeletedEvent, rechirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove rechirp", err)
+		return
+	}
+
+	err = qtx.DeleteChirp(r.Context(), rechirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove rechirp", err)
+		return
+	}
+
+	err = decrementParentCounts(r.Context(), qtx, rechirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove rechirp", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't remove rechirp", err)
+		return
+	}
+
+	err = cfg.timelines.RemoveChirp(r.Context(), rechirp.ID)
+	if err != nil {
+		log.Printf("Error removing chirp %s from timelines: %v", rechirp.ID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// requireRole authenticates the request and checks that the user has one of
+// the given roles. On failure it writes the error response and returns false.
+func (cfg *apiConfig) requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return uuid.Nil, false
+	}
+
+	role, err := cfg.database.GetUserRole(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+			return uuid.Nil, false
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check permissions", err)
+		return uuid.Nil, false
+	}
+
+	if !slices.Contains(roles, role) {
+		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func (cfg *apiConfig) handlerAdminSetRole(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+
+	type returnVals struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+
+	adminID, ok := cfg.requireRole(w, r, roleAdmin)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !slices.Contains([]string{roleUser, roleModerator, roleAdmin}, params.Role) {
+		respondWithError(w, http.StatusBadRequest, "Role must be user, moderator or admin", nil)
+		return
+	}
+
+	// Stops the last admin from locking everyone out by accident.
+	if userID == adminID && params.Role != roleAdmin {
+		respondWithError(w, http.StatusBadRequest, "You can't remove your own admin role", nil)
+		return
+	}
+
+	user, err := cfg.database.SetUserRole(r.Context(), database.SetUserRoleParams{
+		Role: params.Role,
+		ID:   userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "User not found", err)
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update role", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		ID:   user.ID.String(),
+		Role: user.Role,
+	})
+}
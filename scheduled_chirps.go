@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/database"
+)
+
+const (
+	// maxScheduleAhead is how far in the future a chirp can be scheduled.
+	maxScheduleAhead = 365 * 24 * time.Hour
+	// maxScheduledChirpAttempts is how many times publishing a chirp is
+	// tried when it fails unexpectedly, with the wait between attempts
+	// doubling from a minute up to maxScheduledChirpBackoff.
+	maxScheduledChirpAttempts = 8
+	maxScheduledChirpBackoff  = time.Hour
+)
+
+type scheduledChirpResponse struct {
+	ID        string   `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	PublishAt string   `json:"publish_at"`
+	Body      string   `json:"body"`
+	ParentID  *string  `json:"parent_id"`
+	QuoteOfID *string  `json:"quote_of_id"`
+	MediaIDs  []string `json:"media_ids"`
+	FailedAt  *string  `json:"failed_at"`
+	Failure   *string  `json:"failure"`
+}
+
+func newScheduledChirpResponse(scheduled database.ScheduledChirp) scheduledChirpResponse {
+	mediaIDs := make([]string, len(scheduled.MediaIds))
+	for i, id := range scheduled.MediaIds {
+		mediaIDs[i] = id.String()
+	}
+	return scheduledChirpResponse{
+		ID:        scheduled.ID.String(),
+		CreatedAt: scheduled.CreatedAt.String(),
+		PublishAt: scheduled.PublishAt.String(),
+		Body:      scheduled.Body,
+		ParentID:  nullUUIDString(scheduled.ParentID),
+		QuoteOfID: nullUUIDString(scheduled.QuoteOfID),
+		MediaIDs:  mediaIDs,
+		FailedAt:  nullTimeString(scheduled.FailedAt),
+		Failure:   nullStringPtr(scheduled.Failure),
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func uuidPtrToNull(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+// scheduleChirp stores a chirp for the publisher to create at publishAt. The
+// input is checked now so obvious mistakes are reported straight away, and
+// again when the chirp is published.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID, input chirpInput, publishAt time.Time) {
+	if !publishAt.After(time.Now()) {
+		respondWithError(w, http.StatusBadRequest, "publish_at must be in the future", nil)
+		return
+	}
+	if time.Until(publishAt) > maxScheduleAhead {
+		respondWithError(w, http.StatusBadRequest, "Chirps can be scheduled at most a year ahead", nil)
+		return
+	}
+
+	err := cfg.checkChirpInput(r.Context(), cfg.database, userID, input)
+	if err != nil {
+		respondWithChirpInputError(w, err, "Couldn't schedule chirp")
+		return
+	}
+
+	mediaIDs := input.MediaIDs
+	if mediaIDs == nil {
+		mediaIDs = []uuid.UUID{}
+	}
+
+	scheduled, err := cfg.database.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+		UserID:    userID,
+		Body:      input.Body,
+		ParentID:  uuidPtrToNull(input.ParentID),
+		QuoteOfID: uuidPtrToNull(input.QuoteOfID),
+		MediaIds:  mediaIDs,
+		PublishAt: publishAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newScheduledChirpResponse(scheduled))
+}
+
+func (cfg *apiConfig) handlerScheduledChirpsList(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	scheduled, err := cfg.database.ListScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't list scheduled chirps", err)
+		return
+	}
+
+	response := make([]scheduledChirpResponse, len(scheduled))
+	for i, item := range scheduled {
+		response[i] = newScheduledChirpResponse(item)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handlerScheduledChirpsDelete(w http.ResponseWriter, r *http.Request) {
+	scheduledID, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid scheduled chirp ID", err)
+		return
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	// A chirp that is being published right now is locked, so this waits for
+	// the publisher and then finds nothing to delete.
+	deleted, err := cfg.database.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     scheduledID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete scheduled chirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Scheduled chirp not found", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runScheduledChirpPublisher publishes due chirps every interval, so a chirp
+// goes out at most an interval after its publish_at. Rows are claimed with
+// SKIP LOCKED, so several servers can run it at once without publishing a
+// chirp twice.
+func (cfg *apiConfig) runScheduledChirpPublisher(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.publishDueChirps(ctx); err != nil {
+			log.Printf("Error publishing scheduled chirps: %v", err)
+		}
+	})
+}
+
+// publishDueChirps publishes scheduled chirps one at a time until none are
+// due. It stops at the first unexpected error and tries again next time.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) error {
+	for {
+		published, err := cfg.publishNextScheduledChirp(ctx)
+		if err != nil {
+			return err
+		}
+		if !published {
+			return nil
+		}
+	}
+}
+
+// publishNextScheduledChirp claims one due chirp and creates it in the same
+// transaction. A chirp that is no longer valid, for example because its
+// parent was deleted, is marked as failed rather than retried; one that
+// fails for any other reason is retried later so it doesn't hold up the
+// rest. It reports whether a row was handled.
+func (cfg *apiConfig) publishNextScheduledChirp(ctx context.Context) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	scheduled, err := qtx.ClaimDueScheduledChirp(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	suspendedUntil, err := qtx.GetUserSuspension(ctx, scheduled.UserID)
+	if err != nil {
+		return false, err
+	}
+	if suspendedUntil.Valid && suspendedUntil.Time.After(time.Now()) {
+		return true, cfg.failScheduledChirp(ctx, tx, scheduled.ID, "account is suspended")
+	}
+
+	deleteAfter, err := qtx.GetUserDeletion(ctx, scheduled.UserID)
+	if err != nil {
+		return false, err
+	}
+	if deleteAfter.Valid {
+		return true, cfg.failScheduledChirp(ctx, tx, scheduled.ID, "account is scheduled for deletion")
+	}
+
+	chirp, err := cfg.createChirp(ctx, qtx, scheduled.UserID, chirpInput{
+		Body:      scheduled.Body,
+		ParentID:  nullUUIDPtr(scheduled.ParentID),
+		QuoteOfID: nullUUIDPtr(scheduled.QuoteOfID),
+		MediaIDs:  scheduled.MediaIds,
+	})
+	if isChirpInputError(err) {
+		return true, cfg.failScheduledChirp(ctx, tx, scheduled.ID, err.Error())
+	}
+	if err != nil {
+		return true, cfg.retryScheduledChirp(ctx, tx, scheduled, err)
+	}
+
+	if err := qtx.RemoveScheduledChirp(ctx, scheduled.ID); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	cfg.fanOutChirp(ctx, chirp)
+	cfg.federateChirp(ctx, chirp)
+	return true, nil
+}
+
+// retryScheduledChirp abandons the publishing transaction after an
+// unexpected error and puts the chirp off with exponential backoff. Once it
+// has run out of attempts it is marked as failed; the error itself is only
+// logged, since it means nothing to the author.
+func (cfg *apiConfig) retryScheduledChirp(ctx context.Context, tx *sql.Tx, scheduled database.ScheduledChirp, cause error) error {
+	log.Printf("Error publishing scheduled chirp %s: %v", scheduled.ID, cause)
+	if scheduled.Attempts+1 >= maxScheduledChirpAttempts {
+		return cfg.failScheduledChirp(ctx, tx, scheduled.ID, "couldn't be published")
+	}
+
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	backoff := min(time.Minute<<scheduled.Attempts, maxScheduledChirpBackoff)
+	return cfg.database.RetryScheduledChirp(ctx, database.RetryScheduledChirpParams{
+		ID:            scheduled.ID,
+		NextAttemptAt: time.Now().Add(backoff),
+	})
+}
+
+// failScheduledChirp abandons the publishing transaction, which may have
+// been aborted by the failure, and records why the chirp wasn't published.
+func (cfg *apiConfig) failScheduledChirp(ctx context.Context, tx *sql.Tx, id uuid.UUID, reason string) error {
+	if err := tx.Rollback(); err != nil {
+		return err
+	}
+	return cfg.database.MarkScheduledChirpFailed(ctx, database.MarkScheduledChirpFailedParams{
+		ID:      id,
+		Failure: sql.NullString{String: reason, Valid: true},
+	})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/search"
+)
+
+func (cfg *apiConfig) handlerChirpsSearch(w http.ResponseWriter, r *http.Request) {
+	type resultResponse struct {
+		Chirp   chirpResponse `json:"chirp"`
+		Rank    float32       `json:"rank"`
+		Snippet string        `json:"snippet"`
+	}
+
+	type returnVals struct {
+		Results    []resultResponse `json:"results"`
+		NextCursor *string          `json:"next_cursor"`
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	query, err := search.ParseQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Search query is required", err)
+		return
+	}
+
+	limit, _, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	params := database.SearchChirpsParams{
+		Query:    query,
+		PageSize: limit + 1,
+	}
+
+	if value := r.URL.Query().Get("author_id"); value != "" {
+		authorID, err := uuid.Parse(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	params.Since, err = parseTimeParam(r, "since")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid since parameter", err)
+		return
+	}
+
+	params.Until, err = parseTimeParam(r, "until")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid until parameter", err)
+		return
+	}
+
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		position, err := search.DecodeCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		params.CursorRank = sql.NullFloat64{Float64: float64(position.Rank), Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: position.ID, Valid: true}
+	}
+
+	rows, err := cfg.database.SearchChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+
+	var nextCursor *string
+	if int32(len(rows)) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		next := search.Cursor{Rank: last.Rank, ID: last.ID}.Encode()
+		nextCursor = &next
+	}
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+	rows = slices.DeleteFunc(rows, func(row database.SearchChirpsRow) bool {
+		return !visibility.canSee(database.Chirp{UserID: row.UserID, HiddenAt: row.HiddenAt, DeletedAt: row.DeletedAt})
+	})
+
+	chirps := make([]database.Chirp, len(rows))
+	for i, row := range rows {
+		chirps[i] = database.Chirp{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			UserID:       row.UserID,
+			Body:         row.Body,
+			ParentID:     row.ParentID,
+			RootID:       row.RootID,
+			ReplyCount:   row.ReplyCount,
+			LikeCount:    row.LikeCount,
+			RechirpOfID:  row.RechirpOfID,
+			QuoteOfID:    row.QuoteOfID,
+			RechirpCount: row.RechirpCount,
+			QuoteCount:   row.QuoteCount,
+			HiddenAt:     row.HiddenAt,
+			DeletedAt:    row.DeletedAt,
+		}
+	}
+
+	responses, err := cfg.chirpResponses(r.Context(), chirps, viewerID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+
+	results := make([]resultResponse, len(rows))
+	for i, row := range rows {
+		results[i] = resultResponse{
+			Chirp:   responses[i],
+			Rank:    row.Rank,
+			Snippet: search.Highlight(row.Snippet),
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, returnVals{
+		Results:    results,
+		NextCursor: nextCursor,
+	})
+}
+
+// parseTimeParam reads an optional RFC 3339 timestamp from the query string.
+func parseTimeParam(r *http.Request, name string) (sql.NullTime, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return sql.NullTime{}, errors.New("expected an RFC 3339 timestamp")
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
@@ -1,18 +1,20 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password, token)
-VALUES ($1, NOW(), NOW(), $2, $3, $4)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, token, handle)
+VALUES ($1, NOW(), NOW(), $2, $3, $4, $5)
 RETURNING *;
 
 -- name: DeleteAllUsers :exec
 DELETE FROM users;
 
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, user_id, body)
-VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2)
+INSERT INTO chirps (id, created_at, updated_at, user_id, body, parent_id, root_id, rechirp_of_id, quote_of_id)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: ListChirps :many
 SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (hidden_at IS NULL OR user_id = sqlc.narg(viewer_id) OR sqlc.arg(include_hidden)::bool)
 ORDER BY created_at ASC;
 
 -- name: GetChirp :one
@@ -55,5 +57,16 @@ WHERE id = $1
 RETURNING *;
 
 -- name: ListChirpsByAuthor :many
-SELECT * FROM chirps WHERE user_id = $1
-Order by created_at ASC;
\ No newline at end of file
+SELECT * FROM chirps WHERE user_id = sqlc.arg(user_id) AND deleted_at IS NULL
+AND (hidden_at IS NULL OR user_id = sqlc.narg(viewer_id) OR sqlc.arg(include_hidden)::bool)
+Order by created_at ASC;
+
+-- name: ListLatestChirpsByAuthor :many
+-- Lists a user's newest chirps that anyone can see, leaving out rechirps.
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+AND deleted_at IS NULL
+AND hidden_at IS NULL
+AND rechirp_of_id IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,18 @@
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: CreateChirpRevision :one
+INSERT INTO chirp_revisions (id, created_at, chirp_id, body)
+VALUES (gen_random_uuid(), $1, $2, $3)
+RETURNING *;
+
+-- name: ListChirpRevisions :many
+SELECT * FROM chirp_revisions WHERE chirp_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,36 @@
+-- name: IncrementReplyCount :exec
+UPDATE chirps
+SET reply_count = reply_count + 1
+WHERE id = $1;
+
+-- name: DecrementReplyCount :exec
+UPDATE chirps
+SET reply_count = GREATEST(reply_count - 1, 0)
+WHERE id = $1;
+
+-- name: ListChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT c.*, 1 AS depth FROM chirps c
+    WHERE c.id = (SELECT parent_id FROM chirps WHERE chirps.id = $1)
+    UNION ALL
+    SELECT p.*, a.depth + 1 FROM chirps p
+    JOIN ancestors a ON p.id = a.parent_id
+)
+SELECT id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at FROM ancestors
+ORDER BY depth DESC;
+
+-- name: ListChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT c.*, 1 AS depth,
+        ARRAY[to_char(c.created_at AT TIME ZONE 'UTC', 'YYYYMMDDHH24MISSUS') || c.id::text] AS path
+    FROM chirps c
+    WHERE c.parent_id = $1
+    UNION ALL
+    SELECT r.*, d.depth + 1,
+        d.path || (to_char(r.created_at AT TIME ZONE 'UTC', 'YYYYMMDDHH24MISSUS') || r.id::text)
+    FROM chirps r
+    JOIN descendants d ON r.parent_id = d.id
+)
+SELECT id, created_at, updated_at, user_id, body, parent_id, root_id, reply_count, like_count, rechirp_of_id, quote_of_id, rechirp_count, quote_count, hidden_at, deleted_at, depth::integer AS depth FROM descendants
+ORDER BY path
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,30 @@
+-- name: CreateLike :execrows
+INSERT INTO likes (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteLike :execrows
+DELETE FROM likes WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: IncrementLikeCount :one
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id = $1
+RETURNING like_count;
+
+-- name: DecrementLikeCount :one
+UPDATE chirps
+SET like_count = GREATEST(like_count - 1, 0)
+WHERE id = $1
+RETURNING like_count;
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id FROM likes
+WHERE user_id = $1 AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+
+-- name: ListChirpsLikedByUser :many
+SELECT c.* FROM chirps c
+JOIN likes l ON l.chirp_id = c.id
+WHERE l.user_id = $1
+ORDER BY l.created_at DESC
+LIMIT $2 OFFSET $3;
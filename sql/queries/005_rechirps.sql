@@ -0,0 +1,27 @@
+-- name: GetRechirp :one
+SELECT * FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: IncrementRechirpCount :exec
+UPDATE chirps
+SET rechirp_count = rechirp_count + 1
+WHERE id = $1;
+
+-- name: DecrementRechirpCount :exec
+UPDATE chirps
+SET rechirp_count = GREATEST(rechirp_count - 1, 0)
+WHERE id = $1;
+
+-- name: IncrementQuoteCount :exec
+UPDATE chirps
+SET quote_count = quote_count + 1
+WHERE id = $1;
+
+-- name: DecrementQuoteCount :exec
+UPDATE chirps
+SET quote_count = GREATEST(quote_count - 1, 0)
+WHERE id = $1;
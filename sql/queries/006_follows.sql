@@ -0,0 +1,32 @@
+-- name: CreateFollow :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowers :many
+SELECT follower_id, created_at FROM follows
+WHERE followee_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListFollowing :many
+SELECT followee_id, created_at FROM follows
+WHERE follower_id = $1
+ORDER BY created_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: ListTimeline :many
+SELECT * FROM chirps
+WHERE (
+    user_id = sqlc.arg(user_id)
+    OR user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg(user_id))
+)
+AND (
+    sqlc.narg(before_created_at)::timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamptz, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
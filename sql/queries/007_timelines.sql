@@ -0,0 +1,68 @@
+-- name: IncrementFollowCounts :exec
+UPDATE users SET
+    follower_count = follower_count + CASE WHEN id = sqlc.arg(followee_id) THEN 1 ELSE 0 END,
+    following_count = following_count + CASE WHEN id = sqlc.arg(follower_id) THEN 1 ELSE 0 END
+WHERE id IN (sqlc.arg(follower_id), sqlc.arg(followee_id));
+
+-- name: DecrementFollowCounts :exec
+UPDATE users SET
+    follower_count = GREATEST(follower_count - CASE WHEN id = sqlc.arg(followee_id) THEN 1 ELSE 0 END, 0),
+    following_count = GREATEST(following_count - CASE WHEN id = sqlc.arg(follower_id) THEN 1 ELSE 0 END, 0)
+WHERE id IN (sqlc.arg(follower_id), sqlc.arg(followee_id));
+
+-- name: GetFollowCounts :one
+SELECT follower_count, following_count FROM users WHERE id = $1;
+
+-- name: ListFollowerIDs :many
+SELECT follower_id FROM follows WHERE followee_id = $1;
+
+-- name: ListFollowedAuthorsOverThreshold :many
+SELECT u.id FROM follows f
+JOIN users u ON u.id = f.followee_id
+WHERE f.follower_id = $1 AND u.follower_count > $2;
+
+-- name: ListChirpsByAuthorsBefore :many
+SELECT * FROM chirps
+WHERE user_id = ANY(sqlc.arg(author_ids)::uuid[])
+AND (
+    sqlc.narg(before_created_at)::timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamptz, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: AddTimelineEntries :exec
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT unnest(sqlc.arg(user_ids)::uuid[]), sqlc.arg(chirp_id)::uuid, sqlc.arg(author_id)::uuid, sqlc.arg(created_at)::timestamptz
+ON CONFLICT DO NOTHING;
+
+-- name: TrimTimelines :exec
+DELETE FROM timeline_entries t
+USING (
+    SELECT user_id, chirp_id,
+        ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC, chirp_id DESC) AS position
+    FROM timeline_entries
+    WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[])
+) ranked
+WHERE t.user_id = ranked.user_id
+AND t.chirp_id = ranked.chirp_id
+AND ranked.position > sqlc.arg(max_entries)::integer;
+
+-- name: ListTimelineEntries :many
+SELECT * FROM timeline_entries
+WHERE user_id = sqlc.arg(user_id)
+AND (
+    sqlc.narg(before_created_at)::timestamptz IS NULL
+    OR (created_at, chirp_id) < (sqlc.narg(before_created_at)::timestamptz, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, chirp_id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: DeleteTimelineEntriesForChirp :exec
+DELETE FROM timeline_entries WHERE chirp_id = $1;
+
+-- name: DeleteTimelineEntriesForAuthor :exec
+DELETE FROM timeline_entries WHERE user_id = $1 AND author_id = $2;
+
+-- name: DeleteTimelineEntriesForUsers :exec
+DELETE FROM timeline_entries WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[]);
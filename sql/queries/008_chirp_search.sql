@@ -0,0 +1,24 @@
+-- name: UpsertChirpSearchDocument :exec
+INSERT INTO chirp_search (chirp_id, document)
+VALUES ($1, to_tsvector('english', sqlc.arg(body)::text))
+ON CONFLICT (chirp_id) DO UPDATE SET document = EXCLUDED.document;
+
+-- name: SearchChirps :many
+SELECT c.*, ranked.rank::real AS rank,
+    ts_headline('english', c.body, to_tsquery('english', sqlc.arg(query)::text),
+        'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxFragments=2, MinWords=5, MaxWords=20')::text AS snippet
+FROM (
+    SELECT s.chirp_id, ts_rank(s.document, to_tsquery('english', sqlc.arg(query)::text)) AS rank
+    FROM chirp_search s
+    WHERE s.document @@ to_tsquery('english', sqlc.arg(query)::text)
+) ranked
+JOIN chirps c ON c.id = ranked.chirp_id
+WHERE (sqlc.narg(author_id)::uuid IS NULL OR c.user_id = sqlc.narg(author_id)::uuid)
+AND (sqlc.narg(since)::timestamptz IS NULL OR c.created_at >= sqlc.narg(since)::timestamptz)
+AND (sqlc.narg(until)::timestamptz IS NULL OR c.created_at < sqlc.narg(until)::timestamptz)
+AND (
+    sqlc.narg(cursor_rank)::real IS NULL
+    OR (ranked.rank, c.id) < (sqlc.narg(cursor_rank)::real, sqlc.narg(cursor_id)::uuid)
+)
+ORDER BY ranked.rank DESC, c.id DESC
+LIMIT sqlc.arg(page_size);
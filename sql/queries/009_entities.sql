@@ -0,0 +1,57 @@
+-- name: SetUserHandle :one
+UPDATE users
+SET handle = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: ListUsersByHandles :many
+SELECT id, handle FROM users
+WHERE handle = ANY(sqlc.arg(handles)::text[]);
+
+-- name: ListUsersByIDs :many
+SELECT id, handle FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: CreateChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, handle, start_offset, end_offset)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions WHERE chirp_id = $1;
+
+-- name: ListHashtagsForChirps :many
+SELECT * FROM chirp_hashtags
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: ListMentionsForChirps :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: ListChirpsByHashtag :many
+SELECT * FROM chirps
+WHERE id IN (SELECT chirp_id FROM chirp_hashtags WHERE tag = sqlc.arg(tag))
+AND (
+    sqlc.narg(before_created_at)::timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamptz, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListChirpsMentioningUser :many
+SELECT * FROM chirps
+WHERE id IN (SELECT chirp_id FROM chirp_mentions WHERE user_id = sqlc.arg(user_id))
+AND (
+    sqlc.narg(before_created_at)::timestamptz IS NULL
+    OR (created_at, id) < (sqlc.narg(before_created_at)::timestamptz, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,41 @@
+-- name: TryLockTrends :one
+-- Held until the transaction ends. Only one server refreshes trends at a
+-- time; the others skip their turn.
+SELECT pg_try_advisory_xact_lock(hashtext('trends'));
+
+-- name: ListHashtagActivity :many
+SELECT h.tag, c.created_at FROM chirp_hashtags h
+JOIN chirps c ON c.id = h.chirp_id
+WHERE c.created_at >= $1 AND c.deleted_at IS NULL AND c.hidden_at IS NULL;
+
+-- name: ListChirpActivity :many
+-- Deleted and hidden chirps neither trend nor make other chirps trend.
+WITH live AS (
+    SELECT * FROM chirps WHERE deleted_at IS NULL AND hidden_at IS NULL
+)
+SELECT a.chirp_id, a.kind, a.created_at FROM (
+    SELECT chirp_id, 'like'::text AS kind, created_at FROM likes
+    WHERE likes.created_at >= sqlc.arg(since)::timestamptz
+    UNION ALL
+    SELECT parent_id, 'reply'::text, created_at FROM live
+    WHERE parent_id IS NOT NULL AND live.created_at >= sqlc.arg(since)::timestamptz
+    UNION ALL
+    SELECT rechirp_of_id, 'rechirp'::text, created_at FROM live
+    WHERE rechirp_of_id IS NOT NULL AND live.created_at >= sqlc.arg(since)::timestamptz
+    UNION ALL
+    SELECT quote_of_id, 'quote'::text, created_at FROM live
+    WHERE quote_of_id IS NOT NULL AND live.created_at >= sqlc.arg(since)::timestamptz
+) a
+JOIN live ON live.id = a.chirp_id;
+
+-- name: DeleteTrends :exec
+DELETE FROM trends WHERE window_name = $1;
+
+-- name: CreateTrend :exec
+INSERT INTO trends (window_name, kind, rank, item, score, activity_count, computed_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ListTrends :many
+SELECT * FROM trends
+WHERE window_name = $1
+ORDER BY kind, rank;
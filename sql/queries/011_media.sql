@@ -0,0 +1,39 @@
+-- name: CreateMediaFile :one
+INSERT INTO media_files (id, created_at, user_id, content_type, size_bytes, width, height, thumbnail_content_type)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetMediaFile :one
+SELECT * FROM media_files WHERE id = $1;
+
+-- name: CreateChirpAttachment :exec
+INSERT INTO chirp_attachments (chirp_id, media_id, position)
+VALUES ($1, $2, $3);
+
+-- name: ListAttachmentsForChirps :many
+SELECT a.chirp_id, m.id, m.created_at, m.user_id, m.content_type, m.size_bytes, m.width, m.height, m.thumbnail_content_type
+FROM chirp_attachments a
+JOIN media_files m ON m.id = a.media_id
+WHERE a.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY a.chirp_id, a.position;
+
+-- name: GetChirpForMedia :one
+SELECT c.* FROM chirps c
+JOIN chirp_attachments a ON a.chirp_id = c.id
+WHERE a.media_id = $1;
+
+-- name: DeleteOrphanedMediaFiles :many
+-- Deletes uploads made before the cutoff that no chirp, draft or scheduled
+-- chirp refers to, returning their IDs so their blobs can go too.
+DELETE FROM media_files
+WHERE id IN (
+    SELECT m.id FROM media_files m
+    WHERE m.created_at < sqlc.arg(created_before)
+    AND NOT EXISTS (SELECT 1 FROM chirp_attachments a WHERE a.media_id = m.id)
+    AND NOT EXISTS (SELECT 1 FROM drafts d WHERE m.id = ANY(d.media_ids))
+    AND NOT EXISTS (SELECT 1 FROM scheduled_chirps s WHERE m.id = ANY(s.media_ids))
+    ORDER BY m.created_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id;
@@ -0,0 +1,41 @@
+-- name: GetUserRole :one
+SELECT role FROM users WHERE id = $1;
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: ListFilterWords :many
+SELECT * FROM filter_words
+ORDER BY word;
+
+-- name: CreateFilterWord :one
+INSERT INTO filter_words (id, word, action, replacement, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW())
+RETURNING *;
+
+-- name: UpdateFilterWord :one
+UPDATE filter_words
+SET word = $1, action = $2, replacement = $3, updated_at = NOW()
+WHERE id = $4
+RETURNING *;
+
+-- name: DeleteFilterWord :execrows
+DELETE FROM filter_words WHERE id = $1;
+
+-- name: CreateContentFlag :exec
+INSERT INTO content_flags (id, chirp_id, words, created_at)
+VALUES (gen_random_uuid(), $1, $2, NOW());
+
+-- name: ListOpenContentFlags :many
+SELECT * FROM content_flags
+WHERE resolved_at IS NULL
+ORDER BY created_at
+LIMIT $1 OFFSET $2;
+
+-- name: ResolveContentFlag :execrows
+UPDATE content_flags
+SET resolved_at = NOW(), resolved_by = $1
+WHERE id = $2 AND resolved_at IS NULL;
@@ -0,0 +1,45 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, reporter_id, user_id, chirp_id, chirp_body, reason, details)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetReportForUpdate :one
+-- Locks the report so that two moderators acting on it at once take turns,
+-- and the second finds it already resolved.
+SELECT * FROM reports WHERE id = $1 FOR UPDATE;
+
+-- name: ListReports :many
+SELECT * FROM reports
+WHERE status = $1
+ORDER BY created_at
+LIMIT $2 OFFSET $3;
+
+-- name: ResolveReport :execrows
+UPDATE reports
+SET status = 'resolved', resolved_at = NOW(), resolved_by = $1, resolution = $2
+WHERE id = $3 AND status = 'open';
+
+-- name: ResolveChirpReports :exec
+UPDATE reports
+SET status = 'resolved', resolved_at = NOW(), resolved_by = $1, resolution = $2
+WHERE chirp_id = $3 AND status = 'open';
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, report_id, action, target_user_id, target_chirp_id, note, suspended_until)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+WHERE sqlc.narg(target_user_id)::uuid IS NULL OR target_user_id = sqlc.narg(target_user_id)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(page_size) OFFSET sqlc.arg(page_offset);
+
+-- name: HideChirp :exec
+UPDATE chirps SET hidden_at = NOW() WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: SuspendUser :exec
+UPDATE users SET suspended_until = $1, updated_at = NOW() WHERE id = $2;
+
+-- name: GetUserSuspension :one
+SELECT suspended_until FROM users WHERE id = $1;
@@ -0,0 +1,17 @@
+-- name: SoftDeleteChirp :exec
+UPDATE chirps
+SET deleted_at = NOW()
+WHERE (id = $1 OR rechirp_of_id = $1) AND deleted_at IS NULL;
+
+-- name: RestoreChirp :exec
+UPDATE chirps
+SET deleted_at = NULL
+WHERE (id = sqlc.arg(id) OR rechirp_of_id = sqlc.arg(id)) AND deleted_at = sqlc.arg(deleted_at);
+
+-- name: ListDeletedChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND rechirp_of_id IS NULL AND deleted_at > $2
+ORDER BY deleted_at DESC;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps WHERE deleted_at < $1;
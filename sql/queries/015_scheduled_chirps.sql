@@ -0,0 +1,32 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, user_id, body, parent_id, quote_of_id, media_ids, publish_at, next_attempt_at)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6, $6)
+RETURNING *;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at, id;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE next_attempt_at <= NOW() AND failed_at IS NULL
+ORDER BY next_attempt_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: RemoveScheduledChirp :exec
+DELETE FROM scheduled_chirps WHERE id = $1;
+
+-- name: MarkScheduledChirpFailed :exec
+UPDATE scheduled_chirps
+SET failed_at = NOW(), failure = $2
+WHERE id = $1;
+
+-- name: RetryScheduledChirp :exec
+UPDATE scheduled_chirps
+SET attempts = attempts + 1, next_attempt_at = $2
+WHERE id = $1;
@@ -0,0 +1,33 @@
+-- name: LockUserForDrafts :exec
+-- Held until the transaction ends, so two drafts created at once can't both
+-- see room for one more.
+SELECT id FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: CreateDraft :one
+-- Inserts nothing, and so returns no row, once the user has max_drafts. Call
+-- LockUserForDrafts first in the same transaction.
+INSERT INTO drafts (id, created_at, updated_at, user_id, body, parent_id, quote_of_id, media_ids)
+SELECT gen_random_uuid(), NOW(), NOW(), sqlc.arg(user_id), sqlc.arg(body), sqlc.arg(parent_id), sqlc.arg(quote_of_id), sqlc.arg(media_ids)::uuid[]
+WHERE (SELECT COUNT(*) FROM drafts WHERE user_id = sqlc.arg(user_id)) < sqlc.arg(max_drafts)::bigint
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2;
+
+-- name: GetDraftForUpdate :one
+SELECT * FROM drafts WHERE id = $1 AND user_id = $2
+FOR UPDATE;
+
+-- name: ListDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC, id;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $3, parent_id = $4, quote_of_id = $5, media_ids = $6, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,11 @@
+-- name: CreateChirpURL :exec
+INSERT INTO chirp_urls (chirp_id, url, start_offset, end_offset)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteChirpURLs :exec
+DELETE FROM chirp_urls WHERE chirp_id = $1;
+
+-- name: ListURLsForChirps :many
+SELECT * FROM chirp_urls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
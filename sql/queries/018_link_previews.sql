@@ -0,0 +1,33 @@
+-- name: ListURLsToUnfurl :many
+-- URLs that have never been fetched, or whose cached preview went stale
+-- before a chirp used them again.
+SELECT DISTINCT chirp_urls.url FROM chirp_urls
+JOIN chirps ON chirps.id = chirp_urls.chirp_id
+LEFT JOIN link_previews ON link_previews.url = chirp_urls.url
+WHERE link_previews.url IS NULL
+OR (
+    link_previews.fetched_at < sqlc.arg(stale_before)
+    AND chirps.updated_at > link_previews.fetched_at
+)
+LIMIT sqlc.arg(batch_size);
+
+-- name: UpsertLinkPreview :exec
+INSERT INTO link_previews (url, fetched_at, resolved_url, title, description, image_url, site_name, error)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6, $7)
+ON CONFLICT (url) DO UPDATE SET
+    fetched_at = NOW(),
+    resolved_url = EXCLUDED.resolved_url,
+    title = EXCLUDED.title,
+    description = EXCLUDED.description,
+    image_url = EXCLUDED.image_url,
+    site_name = EXCLUDED.site_name,
+    error = EXCLUDED.error;
+
+-- name: ListLinkPreviewsForChirps :many
+-- The card for each chirp is the preview of its first URL that has one.
+SELECT DISTINCT ON (chirp_urls.chirp_id) chirp_urls.chirp_id, link_previews.url, link_previews.resolved_url, link_previews.title, link_previews.description, link_previews.image_url, link_previews.site_name
+FROM chirp_urls
+JOIN link_previews ON link_previews.url = chirp_urls.url
+WHERE chirp_urls.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+AND link_previews.error IS NULL
+ORDER BY chirp_urls.chirp_id, chirp_urls.start_offset;
@@ -0,0 +1,32 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES ($1, NOW(), $2);
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES ($1, $2, $3);
+
+-- name: GetPoll :one
+SELECT * FROM polls WHERE chirp_id = $1;
+
+-- name: CreatePollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: IncrementPollOptionVotes :exec
+UPDATE poll_options
+SET vote_count = vote_count + 1
+WHERE chirp_id = $1 AND position = $2;
+
+-- name: ListPollOptionsForChirps :many
+SELECT poll_options.chirp_id, poll_options.position, poll_options.text, poll_options.vote_count, polls.closes_at
+FROM poll_options
+JOIN polls ON polls.chirp_id = poll_options.chirp_id
+WHERE poll_options.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: ListPollVotesByUser :many
+SELECT chirp_id, position FROM poll_votes
+WHERE user_id = sqlc.arg(user_id)
+AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
@@ -0,0 +1,94 @@
+-- name: CreateActorKey :exec
+INSERT INTO actor_keys (user_id, created_at, public_key_pem, private_key_pem)
+VALUES ($1, NOW(), $2, $3)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetActorKey :one
+SELECT * FROM actor_keys WHERE user_id = $1;
+
+-- name: UpsertRemoteActor :exec
+INSERT INTO remote_actors (id, fetched_at, username, inbox, shared_inbox, key_id, public_key_pem)
+VALUES ($1, NOW(), $2, $3, $4, $5, $6)
+ON CONFLICT (id) DO UPDATE SET
+    fetched_at = NOW(),
+    username = EXCLUDED.username,
+    inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    key_id = EXCLUDED.key_id,
+    public_key_pem = EXCLUDED.public_key_pem;
+
+-- name: GetRemoteActorByKeyID :one
+SELECT * FROM remote_actors
+WHERE key_id = $1
+ORDER BY fetched_at DESC
+LIMIT 1;
+
+-- name: CreateRemoteFollow :exec
+INSERT INTO remote_follows (user_id, actor_id, activity_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, actor_id) DO UPDATE SET activity_id = EXCLUDED.activity_id;
+
+-- name: DeleteRemoteFollow :execrows
+DELETE FROM remote_follows WHERE actor_id = $1 AND activity_id = $2;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*) FROM remote_follows WHERE user_id = $1;
+
+-- name: ListRemoteFollowerInboxes :many
+-- Followers on the same server share an inbox when it has one, so each
+-- server gets a single delivery.
+SELECT DISTINCT COALESCE(remote_actors.shared_inbox, remote_actors.inbox)::text AS inbox
+FROM remote_follows
+JOIN remote_actors ON remote_actors.id = remote_follows.actor_id
+WHERE remote_follows.user_id = $1;
+
+-- name: CreateRemoteLike :execrows
+INSERT INTO remote_likes (chirp_id, actor_id, activity_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (chirp_id, actor_id) DO NOTHING;
+
+-- name: DeleteRemoteLike :one
+DELETE FROM remote_likes WHERE actor_id = $1 AND activity_id = $2
+RETURNING chirp_id;
+
+-- name: CreateRemoteReply :exec
+INSERT INTO remote_replies (id, chirp_id, actor_id, content, published_at, received_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: DeleteRemoteReply :execrows
+DELETE FROM remote_replies WHERE id = $1 AND actor_id = $2;
+
+-- name: ListRemoteReplies :many
+SELECT remote_replies.id, remote_replies.actor_id, remote_actors.username, remote_replies.content, remote_replies.published_at
+FROM remote_replies
+JOIN remote_actors ON remote_actors.id = remote_replies.actor_id
+WHERE remote_replies.chirp_id = $1
+ORDER BY remote_replies.published_at, remote_replies.id
+LIMIT $2 OFFSET $3;
+
+-- name: CreateFederationDelivery :exec
+INSERT INTO federation_deliveries (id, created_at, user_id, inbox, activity, next_attempt_at)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, NOW());
+
+-- name: ClaimFederationDeliveries :many
+-- Claimed rows are leased until lease_until, when they become due again if
+-- the delivery never finished.
+UPDATE federation_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM federation_deliveries
+    WHERE next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeleteFederationDelivery :exec
+DELETE FROM federation_deliveries WHERE id = $1;
+
+-- name: RetryFederationDelivery :exec
+UPDATE federation_deliveries
+SET attempts = attempts + 1, next_attempt_at = $2, last_error = $3
+WHERE id = $1;
@@ -0,0 +1,31 @@
+-- name: CreateChirpEvent :exec
+-- Nothing is recorded if the chirp doesn't exist, so deletions must be
+-- recorded before the chirp is removed. The advisory lock is held until the
+-- transaction ends, so events commit in ID order and a reader that has seen
+-- one event has seen every event before it.
+INSERT INTO chirp_events (type, chirp_id, user_id, hashtags, root_id, mentioned_user_ids)
+SELECT sqlc.arg(type)::text, chirps.id, chirps.user_id, ARRAY(
+    SELECT chirp_hashtags.tag FROM chirp_hashtags
+    WHERE chirp_hashtags.chirp_id = chirps.id
+    ORDER BY chirp_hashtags.start_offset
+)::text[], chirps.root_id, ARRAY(
+    SELECT chirp_mentions.user_id FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id
+)::uuid[]
+FROM chirps, pg_advisory_xact_lock(hashtext('chirp_events'))
+WHERE chirps.id = sqlc.arg(chirp_id);
+
+-- name: GetChirpEvent :one
+SELECT * FROM chirp_events WHERE id = $1;
+
+-- name: GetLatestChirpEventID :one
+SELECT COALESCE(MAX(id), 0)::bigint FROM chirp_events;
+
+-- name: ListChirpEventsAfter :many
+SELECT * FROM chirp_events
+WHERE id > sqlc.arg(after_id)
+ORDER BY id
+LIMIT sqlc.arg(batch_size);
+
+-- name: DeleteChirpEventsBefore :execrows
+DELETE FROM chirp_events WHERE created_at < $1;
@@ -0,0 +1,7 @@
+-- name: ListFolloweeIDs :many
+SELECT followee_id FROM follows WHERE follower_id = $1;
+
+-- name: NotifyRealtimeEvent :exec
+-- Ephemeral events such as typing are never stored; they are only announced
+-- to the server instances listening at the moment.
+SELECT pg_notify('realtime_events', sqlc.arg(payload)::text);
@@ -0,0 +1,78 @@
+-- name: CreateDataExport :one
+-- Inserts nothing, and so returns no row, if the user already has a pending
+-- export.
+INSERT INTO data_exports (id, created_at, user_id)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (user_id) WHERE status = 'pending' DO NOTHING
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = $1;
+
+-- name: GetLatestDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ClaimDataExports :many
+-- Claimed exports are leased until lease_until, when another worker may pick
+-- them up if the first never finished.
+UPDATE data_exports
+SET claimed_until = sqlc.arg(lease_until)
+WHERE id IN (
+    SELECT id FROM data_exports
+    WHERE status = 'pending'
+    AND (claimed_until IS NULL OR claimed_until < NOW())
+    ORDER BY created_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready', completed_at = NOW(), expires_at = $2, blob_key = $3, size_bytes = $4
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', completed_at = NOW(), expires_at = $2, error = $3
+WHERE id = $1;
+
+-- name: ListExpiredDataExports :many
+SELECT * FROM data_exports WHERE expires_at < NOW();
+
+-- name: DeleteDataExport :exec
+DELETE FROM data_exports WHERE id = $1;
+
+-- name: GetUser :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: ListAllChirpsByUser :many
+-- Every chirp the user still has, including hidden ones and ones awaiting
+-- purge, oldest first.
+SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at, id;
+
+-- name: ListAllChirpsByUserAfter :many
+-- A page of ListAllChirpsByUser, starting after the given chirp.
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id)
+AND (
+    sqlc.narg(after_created_at)::timestamptz IS NULL
+    OR (created_at, id) > (sqlc.narg(after_created_at)::timestamptz, sqlc.narg(after_id)::uuid)
+)
+ORDER BY created_at, id
+LIMIT sqlc.arg(page_size);
+
+-- name: ListLikesByUser :many
+SELECT * FROM likes WHERE user_id = $1 ORDER BY created_at;
+
+-- name: ListFollowsOfUser :many
+-- Follows in both directions: who the user follows and who follows them.
+SELECT * FROM follows
+WHERE follower_id = $1 OR followee_id = $1
+ORDER BY created_at;
+
+-- name: ListMediaFilesByUser :many
+SELECT * FROM media_files WHERE user_id = $1 ORDER BY created_at;
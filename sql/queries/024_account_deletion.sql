@@ -0,0 +1,58 @@
+-- name: ScheduleUserDeletion :exec
+UPDATE users
+SET delete_after = $2, tokens_revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: CancelUserDeletion :execrows
+UPDATE users
+SET delete_after = NULL, updated_at = NOW()
+WHERE id = $1 AND delete_after IS NOT NULL;
+
+-- name: GetUserDeletion :one
+SELECT delete_after FROM users WHERE id = $1;
+
+-- name: GetUserTokensRevokedAt :one
+SELECT tokens_revoked_at FROM users WHERE id = $1;
+
+-- name: RevokeAllRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: ClaimDueUserDeletion :one
+-- The row stays locked until the deleting transaction ends, so a user
+-- cancelling at the same moment waits and then finds nothing to cancel.
+SELECT id FROM users
+WHERE delete_after <= NOW()
+ORDER BY delete_after
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: DecrementFollowCountsOfUser :exec
+-- Undoes the counters the user's follows added to other users, before the
+-- follows cascade away with the user.
+UPDATE users
+SET follower_count = GREATEST(follower_count - CASE WHEN id IN (SELECT followee_id FROM follows WHERE follower_id = $1) THEN 1 ELSE 0 END, 0),
+    following_count = GREATEST(following_count - CASE WHEN id IN (SELECT follower_id FROM follows WHERE followee_id = $1) THEN 1 ELSE 0 END, 0)
+WHERE id IN (
+    SELECT followee_id FROM follows WHERE follower_id = $1
+    UNION
+    SELECT follower_id FROM follows WHERE followee_id = $1
+);
+
+-- name: DecrementLikeCountsOfUser :exec
+UPDATE chirps
+SET like_count = GREATEST(like_count - 1, 0)
+WHERE id IN (SELECT chirp_id FROM likes WHERE user_id = $1);
+
+-- name: DecrementPollVotesOfUser :exec
+UPDATE poll_options o
+SET vote_count = GREATEST(o.vote_count - 1, 0)
+FROM poll_votes v
+WHERE v.chirp_id = o.chirp_id AND v.position = o.position AND v.user_id = $1;
+
+-- name: ListDataExportsByUser :many
+SELECT * FROM data_exports WHERE user_id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Deleting a chirp orphans its replies: they stay in the conversation but
+-- lose their parent link, and become roots if the root itself is deleted.
+ALTER TABLE chirps
+    ADD COLUMN parent_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    ADD COLUMN root_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX chirps_parent_id_idx ON chirps (parent_id, created_at);
+CREATE INDEX chirps_root_id_idx ON chirps (root_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_root_id_idx;
+DROP INDEX chirps_parent_id_idx;
+
+ALTER TABLE chirps
+    DROP COLUMN reply_count,
+    DROP COLUMN root_id,
+    DROP COLUMN parent_id;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- A rechirp is a pure repost and disappears with the original. A quote
+-- chirp keeps its own body, so quote_of_id has no foreign key: the quote
+-- survives the original being deleted and renders it as unavailable.
+ALTER TABLE chirps
+    ADD COLUMN rechirp_of_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    ADD COLUMN quote_of_id UUID,
+    ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN quote_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_id_idx ON chirps (user_id, rechirp_of_id)
+    WHERE rechirp_of_id IS NOT NULL;
+CREATE INDEX chirps_rechirp_of_id_idx ON chirps (rechirp_of_id);
+
+-- +goose Down
+DROP INDEX chirps_rechirp_of_id_idx;
+DROP INDEX chirps_user_id_rechirp_of_id_idx;
+
+ALTER TABLE chirps
+    DROP COLUMN quote_count,
+    DROP COLUMN rechirp_count,
+    DROP COLUMN quote_of_id,
+    DROP COLUMN rechirp_of_id;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE follows (
+    follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_id_created_at_idx ON follows (followee_id, created_at DESC);
+CREATE INDEX follows_follower_id_created_at_idx ON follows (follower_id, created_at DESC);
+
+-- Serves both the home timeline and per-author listings newest first.
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+DROP TABLE follows;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- Follower counts are denormalized so the timeline fan-out can decide per
+-- author whether to push on write or pull on read without counting rows.
+ALTER TABLE users
+    ADD COLUMN follower_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN following_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users SET
+    follower_count = (SELECT COUNT(*) FROM follows WHERE followee_id = users.id),
+    following_count = (SELECT COUNT(*) FROM follows WHERE follower_id = users.id);
+
+-- Materialized home timelines, trimmed to a bounded length per user.
+CREATE TABLE timeline_entries (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX timeline_entries_user_id_created_at_idx ON timeline_entries (user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX timeline_entries_chirp_id_idx ON timeline_entries (chirp_id);
+
+-- +goose Down
+DROP TABLE timeline_entries;
+
+ALTER TABLE users
+    DROP COLUMN following_count,
+    DROP COLUMN follower_count;
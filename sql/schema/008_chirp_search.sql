@@ -0,0 +1,19 @@
+-- +goose Up
+-- Search documents live beside chirps rather than in a generated column on
+-- them. Every chirp query selects *, so a column on chirps would be read by
+-- lists, timelines and threads that never search. Documents are written in
+-- the same transaction as the chirp body whenever a chirp is created or
+-- edited, so they can't fall out of step with it.
+CREATE TABLE chirp_search (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX chirp_search_document_idx ON chirp_search USING GIN (document);
+
+INSERT INTO chirp_search (chirp_id, document)
+SELECT id, to_tsvector('english', body) FROM chirps
+WHERE rechirp_of_id IS NULL;
+
+-- +goose Down
+DROP TABLE chirp_search;
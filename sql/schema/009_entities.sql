@@ -0,0 +1,34 @@
+-- +goose Up
+-- Handles are stored lower case so mentions resolve case-insensitively.
+-- Existing users have no handle until they set one.
+ALTER TABLE users ADD COLUMN handle TEXT UNIQUE;
+
+-- Entities are extracted from chirp bodies when a chirp is created or edited.
+-- Offsets are in characters and cover the leading # or @.
+CREATE TABLE chirp_hashtags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset)
+);
+
+CREATE INDEX chirp_hashtags_tag_idx ON chirp_hashtags (tag);
+
+-- Only mentions of existing handles are recorded. A user who later renames
+-- keeps the mentions made under their old handle.
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    handle TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset)
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+DROP TABLE chirp_hashtags;
+ALTER TABLE users DROP COLUMN handle;
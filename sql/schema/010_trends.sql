@@ -0,0 +1,22 @@
+-- +goose Up
+-- Trends are recomputed on a schedule by the aggregator, which replaces every
+-- row for a window at once. Reads only ever touch this table.
+CREATE TABLE trends (
+    window_name TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('hashtag', 'chirp')),
+    rank INTEGER NOT NULL,
+    item TEXT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    activity_count INTEGER NOT NULL,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (window_name, kind, rank)
+);
+
+-- The aggregator scans recent activity by time.
+CREATE INDEX chirps_created_at_idx ON chirps (created_at);
+CREATE INDEX likes_created_at_idx ON likes (created_at);
+
+-- +goose Down
+DROP INDEX likes_created_at_idx;
+DROP INDEX chirps_created_at_idx;
+DROP TABLE trends;
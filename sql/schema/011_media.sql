@@ -0,0 +1,27 @@
+-- +goose Up
+-- The files themselves live in the blob store under keys derived from the
+-- media ID; this table holds what is needed to serve and describe them.
+CREATE TABLE media_files (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content_type TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    thumbnail_content_type TEXT NOT NULL
+);
+
+CREATE INDEX media_files_user_id_idx ON media_files (user_id);
+
+-- Each upload can be attached to one chirp. Deleting the chirp detaches it.
+CREATE TABLE chirp_attachments (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    media_id UUID NOT NULL UNIQUE REFERENCES media_files(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL CHECK (position BETWEEN 0 AND 3),
+    PRIMARY KEY (chirp_id, position)
+);
+
+-- +goose Down
+DROP TABLE chirp_attachments;
+DROP TABLE media_files;
@@ -0,0 +1,40 @@
+-- +goose Up
+-- Promote the first admin by hand:
+--   UPDATE users SET role = 'admin' WHERE email = '...';
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- Words are stored in their normalized form (see internal/filter), so
+-- spellings that would match the same text are rejected as duplicates.
+CREATE TABLE filter_words (
+    id UUID PRIMARY KEY,
+    word TEXT NOT NULL UNIQUE,
+    action TEXT NOT NULL CHECK (action IN ('replace', 'reject', 'flag')),
+    replacement TEXT NOT NULL DEFAULT '****',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- The words that used to be hard-coded in the chirp handlers.
+INSERT INTO filter_words (id, word, action)
+VALUES
+    (gen_random_uuid(), 'kerfuffle', 'replace'),
+    (gen_random_uuid(), 'sharbert', 'replace'),
+    (gen_random_uuid(), 'fornax', 'replace');
+
+-- Chirps that matched a flag word, waiting for a moderator.
+CREATE TABLE content_flags (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    words TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX content_flags_open_idx ON content_flags (created_at) WHERE resolved_at IS NULL;
+
+-- +goose Down
+DROP TABLE content_flags;
+DROP TABLE filter_words;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,62 @@
+-- +goose Up
+-- Hidden chirps stay in the database but are only shown to their author and
+-- to moderators.
+ALTER TABLE chirps ADD COLUMN hidden_at TIMESTAMP WITH TIME ZONE;
+
+-- Suspended users can sign in and read, but can't post until this passes.
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP WITH TIME ZONE;
+
+-- chirp_id deliberately has no foreign key: a report must outlive the chirp
+-- it is about, so chirp_body keeps a copy of what was reported.
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID,
+    chirp_body TEXT,
+    reason TEXT NOT NULL CHECK (reason IN (
+        'spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm',
+        'misinformation', 'impersonation', 'other'
+    )),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution TEXT
+);
+
+CREATE INDEX reports_status_created_at_idx ON reports (status, created_at);
+CREATE INDEX reports_chirp_id_idx ON reports (chirp_id) WHERE chirp_id IS NOT NULL;
+
+-- One open report per reporter for the same chirp, or for the same user
+-- when no chirp is involved.
+CREATE UNIQUE INDEX reports_open_chirp_idx ON reports (reporter_id, chirp_id)
+    WHERE status = 'open' AND chirp_id IS NOT NULL;
+CREATE UNIQUE INDEX reports_open_user_idx ON reports (reporter_id, user_id)
+    WHERE status = 'open' AND chirp_id IS NULL;
+
+-- The audit trail. Targets have no foreign keys so the history survives
+-- deleted chirps and users.
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+    action TEXT NOT NULL CHECK (action IN (
+        'dismiss', 'hide_chirp', 'delete_chirp', 'warn_user', 'suspend_user'
+    )),
+    target_user_id UUID NOT NULL,
+    target_chirp_id UUID,
+    note TEXT NOT NULL DEFAULT '',
+    suspended_until TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX moderation_actions_created_at_idx ON moderation_actions (created_at DESC);
+CREATE INDEX moderation_actions_target_user_id_idx ON moderation_actions (target_user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE chirps DROP COLUMN hidden_at;
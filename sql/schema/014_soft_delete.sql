@@ -0,0 +1,9 @@
+-- +goose Up
+-- Deleted chirps are kept until the restore window passes, then purged.
+ALTER TABLE chirps ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+ALTER TABLE chirps DROP COLUMN deleted_at;
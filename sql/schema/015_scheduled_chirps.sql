@@ -0,0 +1,25 @@
+-- +goose Up
+-- Chirps waiting to be published. They only become rows in chirps when the
+-- publisher picks them up, so nothing else has to know they exist. A chirp
+-- that fails unexpectedly is retried at next_attempt_at, which starts out as
+-- publish_at.
+CREATE TABLE scheduled_chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    parent_id UUID,
+    quote_of_id UUID,
+    media_ids UUID[] NOT NULL DEFAULT '{}',
+    publish_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    failed_at TIMESTAMP WITH TIME ZONE,
+    failure TEXT,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX scheduled_chirps_due_idx ON scheduled_chirps (next_attempt_at) WHERE failed_at IS NULL;
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
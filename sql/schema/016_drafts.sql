@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE drafts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL DEFAULT '',
+    parent_id UUID,
+    quote_of_id UUID,
+    media_ids UUID[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX drafts_user_id_updated_at_idx ON drafts (user_id, updated_at DESC);
+
+-- +goose Down
+DROP TABLE drafts;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- URLs are extracted alongside hashtags and mentions. Offsets are in
+-- characters, like the other entity tables.
+CREATE TABLE chirp_urls (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    start_offset INTEGER NOT NULL,
+    end_offset INTEGER NOT NULL,
+    PRIMARY KEY (chirp_id, start_offset)
+);
+
+-- +goose Down
+DROP TABLE chirp_urls;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Link previews are cached per URL, keyed by the URL as written in chirps.
+-- A failed fetch is cached too, with the reason in error, so broken links
+-- aren't fetched again for every chirp that uses them.
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    resolved_url TEXT,
+    title TEXT,
+    description TEXT,
+    image_url TEXT,
+    site_name TEXT,
+    error TEXT
+);
+
+CREATE INDEX chirp_urls_url_idx ON chirp_urls (url);
+
+-- +goose Down
+DROP INDEX chirp_urls_url_idx;
+DROP TABLE link_previews;
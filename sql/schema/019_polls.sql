@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE polls (
+    chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    closes_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- vote_count is kept in step with poll_votes, like the counters on chirps.
+CREATE TABLE poll_options (
+    chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    vote_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (chirp_id, position)
+);
+
+-- One vote per user per poll; votes can't be changed.
+CREATE TABLE poll_votes (
+    chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chirp_id, user_id),
+    FOREIGN KEY (chirp_id, position) REFERENCES poll_options(chirp_id, position) ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_user_id_idx ON poll_votes (user_id);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
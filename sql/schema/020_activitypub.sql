@@ -0,0 +1,79 @@
+-- +goose Up
+-- The key each user signs federated requests with. It is created the first
+-- time the user's actor is needed.
+CREATE TABLE actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL
+);
+
+-- Actors on other servers, keyed by their URI. They are cached so signatures
+-- can be checked and activities delivered without fetching them every time.
+CREATE TABLE remote_actors (
+    id TEXT PRIMARY KEY,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    username TEXT,
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT,
+    key_id TEXT NOT NULL,
+    public_key_pem TEXT NOT NULL
+);
+
+CREATE INDEX remote_actors_key_id_idx ON remote_actors (key_id);
+
+-- Follows and likes from other servers keep the ID of the activity that
+-- created them, which is what an Undo refers to.
+CREATE TABLE remote_follows (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    activity_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, actor_id)
+);
+
+-- Remote likes count towards chirps.like_count, like local ones.
+CREATE TABLE remote_likes (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    activity_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chirp_id, actor_id)
+);
+
+-- Replies to local chirps posted on other servers, keyed by the note's URI.
+-- content is plain text; the note's HTML is never stored.
+CREATE TABLE remote_replies (
+    id TEXT PRIMARY KEY,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    actor_id TEXT NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    published_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX remote_replies_chirp_id_idx ON remote_replies (chirp_id, published_at);
+
+-- Activities waiting to be delivered, one row per inbox. A row is leased by
+-- pushing next_attempt_at forward while it is being delivered, so a server
+-- that dies mid-delivery leaves it to be retried.
+CREATE TABLE federation_deliveries (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    inbox TEXT NOT NULL,
+    activity TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT
+);
+
+CREATE INDEX federation_deliveries_due_idx ON federation_deliveries (next_attempt_at);
+
+-- +goose Down
+DROP TABLE federation_deliveries;
+DROP TABLE remote_replies;
+DROP TABLE remote_likes;
+DROP TABLE remote_follows;
+DROP TABLE remote_actors;
+DROP TABLE actor_keys;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Chirp events feed the live stream. They are written in the same transaction
+-- as the change they describe, and the trigger below announces each one on
+-- the chirp_events channel once that transaction commits, so every server
+-- instance listening there sees every event. The author and hashtags are
+-- copied in so events can be filtered after the chirp itself is gone.
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    type TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    hashtags TEXT[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_chirp_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('chirp_events', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_events_notify
+AFTER INSERT ON chirp_events
+FOR EACH ROW EXECUTE FUNCTION notify_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirp_events_notify ON chirp_events;
+DROP FUNCTION notify_chirp_event();
+DROP TABLE chirp_events;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Chirp events also record the thread a chirp belongs to and who it
+-- mentions, so WebSocket clients can follow a thread or their mentions.
+ALTER TABLE chirp_events ADD COLUMN root_id UUID;
+ALTER TABLE chirp_events ADD COLUMN mentioned_user_ids UUID[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE chirp_events DROP COLUMN mentioned_user_ids;
+ALTER TABLE chirp_events DROP COLUMN root_id;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Data exports are built in the background. The archive lives in the blob
+-- store under blob_key once the export is ready, and both are removed after
+-- expires_at. Failed exports expire the same way, so users can see why.
+CREATE TABLE data_exports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    claimed_until TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    blob_key TEXT,
+    size_bytes BIGINT,
+    error TEXT
+);
+
+CREATE INDEX data_exports_user_id_idx ON data_exports (user_id, created_at);
+CREATE INDEX data_exports_pending_idx ON data_exports (created_at) WHERE status = 'pending';
+-- A user has at most one export waiting to be built.
+CREATE UNIQUE INDEX data_exports_pending_user_id_idx ON data_exports (user_id) WHERE status = 'pending';
+CREATE INDEX data_exports_expires_at_idx ON data_exports (expires_at);
+
+-- +goose Down
+DROP TABLE data_exports;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- A user who deletes their account is kept until delete_after, and can cancel
+-- by signing in before then. Access tokens issued at or before
+-- tokens_revoked_at are refused, as the tokens themselves can't be revoked.
+ALTER TABLE users
+    ADD COLUMN delete_after TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN tokens_revoked_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX users_delete_after_idx ON users (delete_after) WHERE delete_after IS NOT NULL;
+
+-- +goose Down
+DROP INDEX users_delete_after_idx;
+
+ALTER TABLE users
+    DROP COLUMN tokens_revoked_at,
+    DROP COLUMN delete_after;
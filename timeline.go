@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/auth"
+	"github.com/mjayio/server/internal/cursor"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/timeline"
+)
+
+type timelineResponse struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// fanOutChirp pushes a new chirp onto its author's materialized timeline and,
+// unless the author has more than timelineFanoutThreshold followers, onto
+// every follower's timeline as well. Followers of larger accounts pull those
+// chirps when they read their timeline instead.
+func (cfg *apiConfig) fanOutChirp(ctx context.Context, chirp database.Chirp) {
+	counts, err := cfg.database.GetFollowCounts(ctx, chirp.UserID)
+	if err != nil {
+		log.Printf("Error fanning out chirp %s: %v", chirp.ID, err)
+		return
+	}
+
+	recipients := []uuid.UUID{chirp.UserID}
+	if counts.FollowerCount <= cfg.timelineFanoutThreshold {
+		followers, err := cfg.database.ListFollowerIDs(ctx, chirp.UserID)
+		if err != nil {
+			log.Printf("Error fanning out chirp %s: %v", chirp.ID, err)
+			return
+		}
+		recipients = append(recipients, followers...)
+	}
+
+	err = cfg.timelines.Add(ctx, timeline.Entry{
+		ChirpID:   chirp.ID,
+		AuthorID:  chirp.UserID,
+		CreatedAt: chirp.CreatedAt,
+	}, recipients)
+	if err != nil {
+		log.Printf("Error fanning out chirp %s: %v", chirp.ID, err)
+	}
+}
+
+func (cfg *apiConfig) handlerTimeline(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.secret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	limit, _, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid pagination parameters", err)
+		return
+	}
+
+	var position timeline.Position
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		createdAt, id, err := cursor.Decode(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid cursor", err)
+			return
+		}
+		position = timeline.Position{CreatedAt: createdAt, ChirpID: id}
+	}
+
+	// Fetch one extra chirp to find out whether there is another page.
+	chirps, err := cfg.timelineFromCache(r.Context(), userID, position, limit+1)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load timeline", err)
+		return
+	}
+	if chirps == nil {
+		chirps, err = cfg.timelineFromChirps(r.Context(), userID, position, limit+1)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load timeline", err)
+			return
+		}
+	}
+
+	chirps, nextCursor := chirpPage(chirps, limit)
+
+	visibility, err := cfg.chirpVisibilityFor(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load timeline", err)
+		return
+	}
+	chirps = visibility.filter(chirps)
+
+	response, err := cfg.chirpResponses(r.Context(), chirps, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load timeline", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, timelineResponse{
+		Chirps:     response,
+		NextCursor: nextCursor,
+	})
+}
+
+// timelineFromCache builds a page from the materialized timeline, merging in
+// chirps from followed accounts that are too large to fan out on write. It
+// returns nil when the cache cannot fill the page, either because it is cold
+// or because the page reaches past the trimmed end of the cached timeline.
+func (cfg *apiConfig) timelineFromCache(ctx context.Context, userID uuid.UUID, position timeline.Position, pageSize int32) ([]database.Chirp, error) {
+	cached, err := cfg.timelines.Page(ctx, userID, position, int(pageSize))
+	if err != nil {
+		return nil, err
+	}
+	if int32(len(cached)) < pageSize {
+		return nil, nil
+	}
+
+	largeAuthors, err := cfg.database.ListFollowedAuthorsOverThreshold(ctx, database.ListFollowedAuthorsOverThresholdParams{
+		FollowerID:    userID,
+		FollowerCount: cfg.timelineFanoutThreshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]database.Chirp)
+	var pulled []timeline.Entry
+	if len(largeAuthors) > 0 {
+		params := database.ListChirpsByAuthorsBeforeParams{
+			AuthorIds: largeAuthors,
+			PageSize:  pageSize,
+		}
+		if !position.IsZero() {
+			params.BeforeCreatedAt = sql.NullTime{Time: position.CreatedAt, Valid: true}
+			params.BeforeID = uuid.NullUUID{UUID: position.ChirpID, Valid: true}
+		}
+		chirps, err := cfg.database.ListChirpsByAuthorsBefore(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, chirp := range chirps {
+			byID[chirp.ID] = chirp
+			pulled = append(pulled, timeline.Entry{
+				ChirpID:   chirp.ID,
+				AuthorID:  chirp.UserID,
+				CreatedAt: chirp.CreatedAt,
+			})
+		}
+	}
+
+	merged := timeline.Merge(int(pageSize), cached, pulled)
+
+	var missing []uuid.UUID
+	for _, entry := range merged {
+		if _, ok := byID[entry.ChirpID]; !ok {
+			missing = append(missing, entry.ChirpID)
+		}
+	}
+	if len(missing) > 0 {
+		chirps, err := cfg.database.GetChirpsByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, chirp := range chirps {
+			byID[chirp.ID] = chirp
+		}
+	}
+
+	// Entries whose chirp has since been deleted are skipped, and if that
+	// leaves the page short the cache can't fill it after all.
+	chirps := make([]database.Chirp, 0, len(merged))
+	for _, entry := range merged {
+		if chirp, ok := byID[entry.ChirpID]; ok && !chirp.DeletedAt.Valid {
+			chirps = append(chirps, chirp)
+		}
+	}
+	if int32(len(chirps)) < pageSize {
+		return nil, nil
+	}
+	return chirps, nil
+}
+
+// timelineFromChirps builds a page by joining follows against chirps.
+func (cfg *apiConfig) timelineFromChirps(ctx context.Context, userID uuid.UUID, position timeline.Position, pageSize int32) ([]database.Chirp, error) {
+	params := database.ListTimelineParams{
+		UserID:   userID,
+		PageSize: pageSize,
+	}
+	if !position.IsZero() {
+		params.BeforeCreatedAt = sql.NullTime{Time: position.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: position.ChirpID, Valid: true}
+	}
+	return cfg.database.ListTimeline(ctx, params)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/trends"
+)
+
+// chirpActivityWeights sets how much each kind of interaction counts towards
+// a chirp trending. Sharing a chirp spreads it further than liking it.
+var chirpActivityWeights = map[string]float64{
+	"like":    1,
+	"reply":   2,
+	"quote":   3,
+	"rechirp": 3,
+}
+
+// runTrendsAggregator recomputes trends every interval. Each run replaces
+// the stored trends wholesale under an advisory lock, so when several
+// servers run it at once only one of them refreshes at a time and the others
+// skip.
+func (cfg *apiConfig) runTrendsAggregator(ctx context.Context, interval time.Duration) {
+	runEvery(ctx, interval, func(ctx context.Context) {
+		if err := cfg.refreshTrends(ctx); err != nil {
+			log.Printf("Error refreshing trends: %v", err)
+		}
+	})
+}
+
+// refreshTrends scores recent activity once for the longest window and ranks
+// every configured window from it. It does nothing if another server is
+// already refreshing.
+func (cfg *apiConfig) refreshTrends(ctx context.Context) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	locked, err := qtx.TryLockTrends(ctx)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	since := now.Add(-trends.Longest(cfg.trendWindows))
+
+	hashtagRows, err := qtx.ListHashtagActivity(ctx, since)
+	if err != nil {
+		return err
+	}
+	hashtagEvents := make([]trends.Event, len(hashtagRows))
+	for i, row := range hashtagRows {
+		hashtagEvents[i] = trends.Event{Key: row.Tag, At: row.CreatedAt, Weight: 1}
+	}
+
+	chirpRows, err := qtx.ListChirpActivity(ctx, since)
+	if err != nil {
+		return err
+	}
+	chirpEvents := make([]trends.Event, len(chirpRows))
+	for i, row := range chirpRows {
+		chirpEvents[i] = trends.Event{
+			Key:    row.ChirpID.String(),
+			At:     row.CreatedAt,
+			Weight: chirpActivityWeights[row.Kind],
+		}
+	}
+
+	for _, window := range cfg.trendWindows {
+		if err := qtx.DeleteTrends(ctx, window.Name); err != nil {
+			return err
+		}
+
+		ranked := map[string][]trends.Trend{
+			"hashtag": trends.Rank(hashtagEvents, window, now, int(cfg.trendLimit)),
+			"chirp":   trends.Rank(chirpEvents, window, now, int(cfg.trendLimit)),
+		}
+		for kind, list := range ranked {
+			for i, trend := range list {
+				err := qtx.CreateTrend(ctx, database.CreateTrendParams{
+					WindowName:    window.Name,
+					Kind:          kind,
+					Rank:          int32(i + 1),
+					Item:          trend.Key,
+					Score:         trend.Score,
+					ActivityCount: int32(trend.Count),
+					ComputedAt:    now,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *apiConfig) handlerTrends(w http.ResponseWriter, r *http.Request) {
+	type hashtagTrend struct {
+		Tag   string  `json:"tag"`
+		Score float64 `json:"score"`
+		Count int32   `json:"count"`
+	}
+
+	type chirpTrend struct {
+		Chirp chirpResponse `json:"chirp"`
+		Score float64       `json:"score"`
+		Count int32         `json:"count"`
+	}
+
+	type returnVals struct {
+		Window     string         `json:"window"`
+		ComputedAt *string        `json:"computed_at"`
+		Hashtags   []hashtagTrend `json:"hashtags"`
+		Chirps     []chirpTrend   `json:"chirps"`
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Unauthorized", err)
+		return
+	}
+
+	window := cfg.trendWindows[0]
+	if name := r.URL.Query().Get("window"); name != "" {
+		found := false
+		for _, candidate := range cfg.trendWindows {
+			if candidate.Name == name {
+				window, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			respondWithError(w, http.StatusBadRequest, "Unknown trends window", nil)
+			return
+		}
+	}
+
+	rows, err := cfg.database.ListTrends(r.Context(), window.Name)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trends", err)
+		return
+	}
+
+	response := returnVals{
+		Window:   window.Name,
+		Hashtags: []hashtagTrend{},
+		Chirps:   []chirpTrend{},
+	}
+
+	var chirpRows []database.Trend
+	var chirpIDs []uuid.UUID
+	for _, row := range rows {
+		if response.ComputedAt == nil {
+			computedAt := row.ComputedAt.String()
+			response.ComputedAt = &computedAt
+		}
+
+		switch row.Kind {
+		case "hashtag":
+			response.Hashtags = append(response.Hashtags, hashtagTrend{
+				Tag:   row.Item,
+				Score: row.Score,
+				Count: row.ActivityCount,
+			})
+		case "chirp":
+			id, err := uuid.Parse(row.Item)
+			if err != nil {
+				continue
+			}
+			chirpRows = append(chirpRows, row)
+			chirpIDs = append(chirpIDs, id)
+		}
+	}
+
+	if len(chirpIDs) > 0 {
+		chirps, err := cfg.database.GetChirpsByIDs(r.Context(), chirpIDs)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trends", err)
+			return
+		}
+
+		visibility, err := cfg.chirpVisibilityFor(r.Context(), viewerID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trends", err)
+			return
+		}
+
+		// Keep the trend order, skipping chirps deleted or hidden since the
+		// last refresh.
+		byID := make(map[uuid.UUID]database.Chirp, len(chirps))
+		for _, chirp := range chirps {
+			byID[chirp.ID] = chirp
+		}
+		var ordered []database.Chirp
+		var orderedRows []database.Trend
+		for i, id := range chirpIDs {
+			if chirp, ok := byID[id]; ok && visibility.canSee(chirp) {
+				ordered = append(ordered, chirp)
+				orderedRows = append(orderedRows, chirpRows[i])
+			}
+		}
+
+		chirpResponses, err := cfg.chirpResponses(r.Context(), ordered, viewerID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve trends", err)
+			return
+		}
+		for i, chirp := range chirpResponses {
+			response.Chirps = append(response.Chirps, chirpTrend{
+				Chirp: chirp,
+				Score: orderedRows[i].Score,
+				Count: orderedRows[i].ActivityCount,
+			})
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
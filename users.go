@@ -1,27 +1,34 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/mjayio/server/internal/auth"
 	"github.com/mjayio/server/internal/database"
+	"github.com/mjayio/server/internal/entities"
 )
 
 func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	type returnVals struct {
-		ID          string `json:"id"`
-		Email       string `json:"email"`
-		CreatedAt   string `json:"created_at"`
-		UpdatedAt   string `json:"updated_at"`
-		IsChirpyRed bool   `json:"is_chirpy_red"`
+		ID          string  `json:"id"`
+		Email       string  `json:"email"`
+		Handle      *string `json:"handle"`
+		CreatedAt   string  `json:"created_at"`
+		UpdatedAt   string  `json:"updated_at"`
+		IsChirpyRed bool    `json:"is_chirpy_red"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -32,6 +39,15 @@ func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var handle sql.NullString
+	if params.Handle != "" {
+		handle, err = parseHandle(params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid handle", err)
+			return
+		}
+	}
+
 	hashedPassword, err := auth.HashedPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
@@ -50,8 +66,14 @@ func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request)
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
 		Token:          token,
+		Handle:         handle,
 	})
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			respondWithError(w, http.StatusConflict, "Handle is already taken", err)
+			return
+		}
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
@@ -59,6 +81,7 @@ func (cfg *apiConfig) handlerUserCreate(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusCreated, returnVals{
 		ID:          user.ID.String(),
 		Email:       user.Email,
+		Handle:      nullStringPtr(user.Handle),
 		CreatedAt:   user.CreatedAt.String(),
 		UpdatedAt:   user.UpdatedAt.String(),
 		IsChirpyRed: user.IsChirpyRed,
@@ -72,13 +95,15 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type returnVals struct {
-		ID           string `json:"id"`
-		CreatedAt    string `json:"created_at"`
-		UpdatedAt    string `json:"updated_at"`
-		Email        string `json:"email"`
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-		IsChirpyRed  bool   `json:"is_chirpy_red"`
+		ID           string  `json:"id"`
+		CreatedAt    string  `json:"created_at"`
+		UpdatedAt    string  `json:"updated_at"`
+		Email        string  `json:"email"`
+		Handle       *string `json:"handle"`
+		Token        string  `json:"token"`
+		RefreshToken string  `json:"refresh_token"`
+		IsChirpyRed  bool    `json:"is_chirpy_red"`
+		DeleteAfter  *string `json:"delete_after"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -127,9 +152,11 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:    user.CreatedAt.String(),
 		UpdatedAt:    user.UpdatedAt.String(),
 		Email:        user.Email,
+		Handle:       nullStringPtr(user.Handle),
 		Token:        token,
 		RefreshToken: refreshToken,
 		IsChirpyRed:  user.IsChirpyRed,
+		DeleteAfter:  nullTimeString(user.DeleteAfter),
 	})
 }
 
@@ -183,16 +210,18 @@ func (cfg *apiConfig) handlerRevokeToken(w http.ResponseWriter, r *http.Request)
 
 func (cfg *apiConfig) handlerUserUpdateEmailPassword(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string  `json:"email"`
+		Password string  `json:"password"`
+		Handle   *string `json:"handle"`
 	}
 
 	type returnVals struct {
-		ID          string `json:"id"`
-		Email       string `json:"email"`
-		CreatedAt   string `json:"created_at"`
-		UpdatedAt   string `json:"updated_at"`
-		IsChirpyRed bool   `json:"is_chirpy_red"`
+		ID          string  `json:"id"`
+		Email       string  `json:"email"`
+		Handle      *string `json:"handle"`
+		CreatedAt   string  `json:"created_at"`
+		UpdatedAt   string  `json:"updated_at"`
+		IsChirpyRed bool    `json:"is_chirpy_red"`
 	}
 
 	token, err := auth.GetBearerToken(r.Header)
@@ -215,13 +244,32 @@ func (cfg *apiConfig) handlerUserUpdateEmailPassword(w http.ResponseWriter, r *h
 		return
 	}
 
+	// Handles are optional here: leaving the field out keeps the current one,
+	// and an empty string clears it.
+	var handle sql.NullString
+	if params.Handle != nil && *params.Handle != "" {
+		handle, err = parseHandle(*params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid handle", err)
+			return
+		}
+	}
+
 	hashedPassword, err := auth.HashedPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
 		return
 	}
 
-	user, err := cfg.database.UpdateUserEmailPassword(r.Context(), database.UpdateUserEmailPasswordParams{
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.database.WithTx(tx)
+
+	user, err := qtx.UpdateUserEmailPassword(r.Context(), database.UpdateUserEmailPasswordParams{
 		ID:             userID,
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
@@ -231,11 +279,52 @@ func (cfg *apiConfig) handlerUserUpdateEmailPassword(w http.ResponseWriter, r *h
 		return
 	}
 
+	if params.Handle != nil {
+		user, err = qtx.SetUserHandle(r.Context(), database.SetUserHandleParams{
+			Handle: handle,
+			ID:     userID,
+		})
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				respondWithError(w, http.StatusConflict, "Handle is already taken", err)
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
 	respondWithJSON(w, http.StatusOK, returnVals{
 		ID:          user.ID.String(),
 		Email:       user.Email,
+		Handle:      nullStringPtr(user.Handle),
 		CreatedAt:   user.CreatedAt.String(),
 		UpdatedAt:   user.UpdatedAt.String(),
 		IsChirpyRed: user.IsChirpyRed,
 	})
 }
+
+var errInvalidHandle = errors.New("handles are 1-15 letters, digits or underscores")
+
+// parseHandle validates a handle from a request and returns it in its stored
+// form.
+func parseHandle(handle string) (sql.NullString, error) {
+	handle = strings.TrimPrefix(handle, "@")
+	if !entities.ValidHandle(handle) {
+		return sql.NullString{}, errInvalidHandle
+	}
+	return sql.NullString{String: entities.NormalizeHandle(handle), Valid: true}, nil
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runEvery calls fn immediately and then once every interval until ctx is
+// cancelled. A call that overruns the interval delays the next one rather
+// than overlapping it.
+func runEvery(ctx context.Context, interval time.Duration, fn func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}